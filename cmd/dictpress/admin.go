@@ -2,12 +2,12 @@ package main
 
 import (
 	"bytes"
-	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -56,12 +56,24 @@ func (a *App) HandleInsertEntry(c echo.Context) error {
 		e.Meta = map[string]interface{}{}
 	}
 
+	e = a.renderEntryNotes(e)
+
 	id, err := a.data.InsertEntry(e)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			fmt.Sprintf("error inserting entry: %v", err))
 	}
 
+	e.ID = id
+	a.recordAudit(c, auditActionEntryInsert, auditEntityEntry, id, nil, e)
+
+	a.invalidateEntryCache(e.Lang, id)
+	a.publishEntryChange(id)
+
+	if err := a.search.Index(e); err != nil {
+		a.lo.Printf("error indexing entry %d in search backend: %v", id, err)
+	}
+
 	// Proxy to the get request to respond with the newly inserted entry.
 	c.SetParamNames("id")
 	c.SetParamValues(fmt.Sprintf("%d", id))
@@ -70,7 +82,7 @@ func (a *App) HandleInsertEntry(c echo.Context) error {
 
 // HandleGetPendingEntries returns the pending entries for moderation.
 func (a *App) HandleGetPendingEntries(c echo.Context) error {
-	pg := a.pgSite.NewFromURL(c.Request().URL.Query())
+	pg := a.resultsPg.NewFromURL(c.Request().URL.Query())
 
 	// Search and compose results.
 	out := &results{
@@ -91,11 +103,17 @@ func (a *App) HandleGetPendingEntries(c echo.Context) error {
 	}
 
 	// Load relations into the matches.
-	if err := a.data.SearchAndLoadRelations(res, data.Query{}); err != nil {
+	if err := a.data.SearchAndLoadRelations(c.Request().Context(), res, data.Query{}); err != nil {
 		a.lo.Printf("error querying db for defs: %v", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	// Moderators can filter out and sort by spam_score on the current page
+	// of results. This happens in-process on the page already fetched, not
+	// as a DB-level filter, so it composes with pagination rather than
+	// replacing it.
+	res = filterAndSortBySpamScore(res, c.QueryParam("min_spam_score"), c.QueryParam("sort"))
+
 	pg.SetTotal(total)
 
 	out.Entries = res
@@ -123,7 +141,7 @@ func (a *App) HandleGetEntry(c echo.Context) error {
 	e.Relations = make([]data.Entry, 0)
 
 	entries := []data.Entry{e}
-	if err := a.data.SearchAndLoadRelations(entries, data.Query{}); err != nil {
+	if err := a.data.SearchAndLoadRelations(c.Request().Context(), entries, data.Query{}); err != nil {
 		a.lo.Printf("error loading relations: %v", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "error loading relations")
 	}
@@ -166,11 +184,27 @@ func (a *App) HandleUpdateEntry(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	e = a.renderEntryNotes(e)
+
+	before, beforeErr := a.data.GetEntry(id, "")
+
 	if err := a.data.UpdateEntry(id, e); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			fmt.Sprintf("error updating entry: %v", err))
 	}
 
+	e.ID = id
+	if beforeErr == nil {
+		a.recordAudit(c, auditActionEntryUpdate, auditEntityEntry, id, before, e)
+	}
+
+	a.invalidateEntryCache(e.Lang, id)
+	a.publishEntryChange(id)
+
+	if err := a.search.Index(e); err != nil {
+		a.lo.Printf("error indexing entry %d in search backend: %v", id, err)
+	}
+
 	// Proxy to the get request to respond with the newly inserted entry.
 	c.SetParamNames("id")
 	c.SetParamValues(fmt.Sprintf("%d", id))
@@ -190,6 +224,9 @@ func (a *App) HandleApproveSubmission(c echo.Context) error {
 			fmt.Sprintf("error approving submission: %v", err))
 	}
 
+	a.invalidateEntryCacheByID(id)
+	a.publishEntryChange(id)
+
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
@@ -213,11 +250,24 @@ func (a *App) HandleRejectSubmission(c echo.Context) error {
 func (a *App) HandleDeleteEntry(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
 
+	before, beforeErr := a.data.GetEntry(id, "")
+
 	if err := a.data.DeleteEntry(id); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			fmt.Sprintf("error deleting entry: %v", err))
 	}
 
+	if beforeErr == nil {
+		a.recordAudit(c, auditActionEntryDelete, auditEntityEntry, id, before, nil)
+		a.invalidateEntryCache(before.Lang, id)
+	} else {
+		a.invalidateSearchCache()
+	}
+
+	if err := a.search.Delete(id); err != nil {
+		a.lo.Printf("error deleting entry %d from search backend: %v", id, err)
+	}
+
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
@@ -236,11 +286,18 @@ func (a *App) HandleAddRelation(c echo.Context) error {
 			fmt.Sprintf("error parsing request: %v", err))
 	}
 
-	if _, err := a.data.InsertRelation(fromID, toID, rel); err != nil {
+	relID, err := a.data.InsertRelation(fromID, toID, rel)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			fmt.Sprintf("error inserting relation: %v", err))
 	}
 
+	rel.ID = relID
+	a.recordAudit(c, auditActionRelationAdd, auditEntityRelation, relID, nil,
+		relationSnapshot{ID: relID, FromID: fromID, ToID: toID, Types: rel.Types, Tags: rel.Tags, Notes: rel.Notes, Weight: rel.Weight, Status: rel.Status})
+
+	a.invalidateEntryCacheByID(fromID)
+
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
@@ -258,11 +315,22 @@ func (a *App) HandleUpdateRelation(c echo.Context) error {
 			fmt.Sprintf("error parsing request: %v", err))
 	}
 
+	before, beforeErr := a.getRelationSnapshot(relID)
+
 	if err := a.data.UpdateRelation(relID, rel); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			fmt.Sprintf("error updating relation: %v", err))
 	}
 
+	if beforeErr == nil {
+		after := relationSnapshot{ID: relID, FromID: before.FromID, ToID: before.ToID,
+			Types: rel.Types, Tags: rel.Tags, Notes: rel.Notes, Weight: rel.Weight, Status: rel.Status}
+		a.recordAudit(c, auditActionRelationEdit, auditEntityRelation, relID, before, after)
+		a.invalidateEntryCacheByID(before.FromID)
+	} else {
+		a.invalidateSearchCache()
+	}
+
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
@@ -277,11 +345,27 @@ func (a *App) HandleReorderRelations(c echo.Context) error {
 			fmt.Sprintf("error parsing request: %v", err))
 	}
 
+	before, beforeErr := a.getRelationOrder(req.IDs)
+
 	if err := a.data.ReorderRelations(req.IDs); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			fmt.Sprintf("error updating relation: %v", err))
 	}
 
+	if beforeErr == nil {
+		a.recordAudit(c, auditActionRelationOrder, auditEntityRelation, 0, before, req.IDs)
+	}
+
+	// Reordering doesn't change from_id/to_id, so any one of the reordered
+	// relations' from_id identifies the cached search results to evict.
+	if len(req.IDs) > 0 {
+		if rel, err := a.getRelationSnapshot(req.IDs[0]); err == nil {
+			a.invalidateEntryCacheByID(rel.FromID)
+		} else {
+			a.invalidateSearchCache()
+		}
+	}
+
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
@@ -294,11 +378,20 @@ func (a *App) HandleDeleteRelation(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid IDs.")
 	}
 
+	before, beforeErr := a.getRelationSnapshot(relID)
+
 	if err := a.data.DeleteRelation(fromID, relID); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			fmt.Sprintf("error deleting relation: %v", err))
 	}
 
+	if beforeErr == nil {
+		a.recordAudit(c, auditActionRelationDrop, auditEntityRelation, relID, before, nil)
+		a.invalidateEntryCacheByID(before.FromID)
+	} else {
+		a.invalidateSearchCache()
+	}
+
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
@@ -340,12 +433,33 @@ func (a *App) HandleDeletePending(c echo.Context) error {
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
-func (a *App) validateEntry(e data.Entry) (data.Entry, error) {
-	for i, v := range e.Content {
-		e.Content[i] = strings.TrimSpace(v)
+// filterAndSortBySpamScore drops entries below minScoreStr (if it parses as
+// a float) and, if sortDir is "spam_score_asc" or "spam_score_desc", orders
+// the remaining entries by their spam_score.
+func filterAndSortBySpamScore(entries []data.Entry, minScoreStr, sortDir string) []data.Entry {
+	if min, err := strconv.ParseFloat(minScoreStr, 64); err == nil {
+		filtered := entries[:0]
+		for _, e := range entries {
+			if e.SpamScore >= min {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+
+	switch sortDir {
+	case "spam_score_asc":
+		sort.Slice(entries, func(i, j int) bool { return entries[i].SpamScore < entries[j].SpamScore })
+	case "spam_score_desc":
+		sort.Slice(entries, func(i, j int) bool { return entries[i].SpamScore > entries[j].SpamScore })
 	}
 
-	if len(e.Content) == 0 || strings.TrimSpace(e.Content[0]) == "" {
+	return entries
+}
+
+func (a *App) validateEntry(e data.Entry) (data.Entry, error) {
+	e.Content = strings.TrimSpace(e.Content)
+	if e.Content == "" {
 		return data.Entry{}, errors.New("invalid `content`")
 	}
 
@@ -383,7 +497,7 @@ func (a *App) adminPage(tpl string) func(c echo.Context) error {
 		}
 
 		b := &bytes.Buffer{}
-		err := a.adminTpl.ExecuteTemplate(b, tpl, struct {
+		err := a.adminTpl.Load().ExecuteTemplate(b, tpl, struct {
 			Title    string
 			AssetVer string
 			Consts   Consts
@@ -396,14 +510,3 @@ func (a *App) adminPage(tpl string) func(c echo.Context) error {
 		return c.HTMLBlob(http.StatusOK, b.Bytes())
 	}
 }
-
-// basicAuth middleware does an HTTP BasicAuth authentication for admin handlers.
-func (a *App) basicAuth(username, password string, c echo.Context) (bool, error) {
-	if subtle.ConstantTimeCompare([]byte(username), a.consts.AdminUsername) == 1 &&
-		subtle.ConstantTimeCompare([]byte(password), a.consts.AdminPassword) == 1 {
-		c.Set(isAuthed, true)
-		return true, nil
-	}
-
-	return false, nil
-}
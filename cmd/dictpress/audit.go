@@ -0,0 +1,305 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/knadh/dictpress/internal/data"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// Audit log actions. Each pairs an entity_type with the operation performed,
+// so HandleRevertAudit knows which inverse operation to apply.
+const (
+	auditActionEntryInsert   = "entry.insert"
+	auditActionEntryUpdate   = "entry.update"
+	auditActionEntryDelete   = "entry.delete"
+	auditActionRelationAdd   = "relation.insert"
+	auditActionRelationEdit  = "relation.update"
+	auditActionRelationOrder = "relation.reorder"
+	auditActionRelationDrop  = "relation.delete"
+
+	auditEntityEntry    = "entry"
+	auditEntityRelation = "relation"
+)
+
+// auditLogEntry is a single row of the audit_log table.
+type auditLogEntry struct {
+	ID         int             `json:"id" db:"id"`
+	Actor      string          `json:"actor" db:"actor"`
+	Action     string          `json:"action" db:"action"`
+	EntityType string          `json:"entity_type" db:"entity_type"`
+	EntityID   int             `json:"entity_id" db:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty" db:"before"`
+	After      json.RawMessage `json:"after,omitempty" db:"after"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+
+	// Diff is computed (not a DB column) for HandleGetAuditLog, so a client
+	// doesn't have to diff Before/After itself.
+	Diff map[string]fieldDiff `json:"diff,omitempty" db:"-"`
+}
+
+// fieldDiff is the before/after value of a single changed top-level field.
+type fieldDiff struct {
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// relationSnapshot is the audited, flattened shape of a relation row,
+// including the from_id/to_id a data.Relation doesn't itself carry, so a
+// "relation.delete" audit entry has everything InsertRelation needs to
+// restore it.
+type relationSnapshot struct {
+	ID     int            `json:"id" db:"id"`
+	FromID int            `json:"from_id" db:"from_id"`
+	ToID   int            `json:"to_id" db:"to_id"`
+	Types  pq.StringArray `json:"types" db:"types"`
+	Tags   pq.StringArray `json:"tags" db:"tags"`
+	Notes  string         `json:"notes" db:"notes"`
+	Weight float64        `json:"weight" db:"weight"`
+	Status string         `json:"status" db:"status"`
+}
+
+// getRelationSnapshot fetches a relation row by id, for use as an audit
+// log's before-snapshot ahead of an update or delete.
+func (a *App) getRelationSnapshot(id int) (relationSnapshot, error) {
+	var r relationSnapshot
+	err := a.db.Get(&r, `SELECT id, from_id, to_id, types, tags, notes, weight, status FROM relations WHERE id = $1`, id)
+	return r, err
+}
+
+// getRelationOrder returns ids as they're currently ordered by weight, for
+// use as an audit log's before-snapshot ahead of a reorder.
+func (a *App) getRelationOrder(ids []int) ([]int, error) {
+	var out []int
+	err := a.db.Select(&out, `SELECT id FROM relations WHERE id = ANY($1) ORDER BY weight`, pq.Array(ids))
+	return out, err
+}
+
+// auditPruneEvery is how often the audit log is swept for rows past
+// consts.AuditRetentionDays.
+const auditPruneEvery = time.Hour
+
+// auditPruneLoop periodically deletes audit_log rows older than
+// consts.AuditRetentionDays. It's a no-op loop if retention is unset (0),
+// checked on every tick so the config can be changed without a restart.
+func (a *App) auditPruneLoop() {
+	for range time.Tick(auditPruneEvery) {
+		if a.consts.AuditRetentionDays <= 0 {
+			continue
+		}
+
+		if _, err := a.db.Exec(
+			`DELETE FROM audit_log WHERE created_at < NOW() - ($1 || ' days')::INTERVAL`,
+			a.consts.AuditRetentionDays); err != nil {
+			a.lo.Printf("error pruning audit log: %v", err)
+		}
+	}
+}
+
+// recordAudit appends an audit_log row for a single admin mutation. before
+// and after are marshalled as-is (nil is stored as SQL NULL); failures are
+// logged but don't fail the mutation they're recording, the same tradeoff
+// invalidateSearchCache makes for cache purges.
+func (a *App) recordAudit(c echo.Context, action, entityType string, entityID int, before, after interface{}) {
+	b, err := marshalAuditSnapshot(before)
+	if err != nil {
+		a.lo.Printf("error marshalling audit before-snapshot: %v", err)
+		return
+	}
+
+	af, err := marshalAuditSnapshot(after)
+	if err != nil {
+		a.lo.Printf("error marshalling audit after-snapshot: %v", err)
+		return
+	}
+
+	if _, err := a.db.Exec(`
+		INSERT INTO audit_log (actor, action, entity_type, entity_id, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		actor(c), action, entityType, entityID, b, af); err != nil {
+		a.lo.Printf("error recording audit log: %v", err)
+	}
+}
+
+// marshalAuditSnapshot marshals v to JSON for storage in audit_log, except a
+// nil v (eg: the "before" of an insert) which is left as a nil []byte so it's
+// stored as SQL NULL instead of the JSON literal "null".
+func marshalAuditSnapshot(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// HandleGetAuditLog returns the audit log, optionally filtered to a single
+// entity (?entity_id=&entity_type=), newest first, with a computed
+// field-level diff between each entry's before/after snapshots.
+func (a *App) HandleGetAuditLog(c echo.Context) error {
+	var (
+		entityID, _ = strconv.Atoi(c.QueryParam("entity_id"))
+		entityType  = c.QueryParam("entity_type")
+	)
+
+	var out []auditLogEntry
+	if err := a.db.Select(&out, `
+		SELECT id, actor, action, entity_type, entity_id, before, after, created_at
+		FROM audit_log
+		WHERE ($1 = 0 OR entity_id = $1) AND ($2 = '' OR entity_type = $2)
+		ORDER BY id DESC`, entityID, entityType); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Sprintf("error fetching audit log: %v", err))
+	}
+
+	for i := range out {
+		out[i].Diff = diffSnapshots(out[i].Before, out[i].After)
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// diffSnapshots returns the set of top-level fields that differ between
+// before and after, omitting fields that compare equal after a JSON
+// round-trip so unrelated key ordering/whitespace doesn't show up as a
+// change.
+func diffSnapshots(before, after json.RawMessage) map[string]fieldDiff {
+	var b, a map[string]interface{}
+	json.Unmarshal(before, &b)
+	json.Unmarshal(after, &a)
+
+	diff := map[string]fieldDiff{}
+	seen := map[string]bool{}
+	for k := range b {
+		seen[k] = true
+	}
+	for k := range a {
+		seen[k] = true
+	}
+
+	for k := range seen {
+		bv, bOk := b[k]
+		av, aOk := a[k]
+		if !bOk {
+			diff[k] = fieldDiff{To: av}
+			continue
+		}
+		if !aOk {
+			diff[k] = fieldDiff{From: bv}
+			continue
+		}
+
+		bj, _ := json.Marshal(bv)
+		aj, _ := json.Marshal(av)
+		if string(bj) != string(aj) {
+			diff[k] = fieldDiff{From: bv, To: av}
+		}
+	}
+
+	return diff
+}
+
+// HandleRevertAudit re-applies the inverse of a single audit log entry:
+// re-inserting a deleted entry/relation, restoring an entry/relation's prior
+// field values, or restoring a relation reordering. Reverting an
+// `entry.insert` or `relation.insert` simply deletes what was inserted.
+func (a *App) HandleRevertAudit(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var entry auditLogEntry
+	if err := a.db.Get(&entry, `
+		SELECT id, actor, action, entity_type, entity_id, before, after, created_at
+		FROM audit_log WHERE id = $1`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "audit log entry not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if err := a.revertAudit(entry); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Sprintf("error reverting: %v", err))
+	}
+
+	a.invalidateSearchCache()
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// revertAudit applies the inverse of a single audit log entry.
+func (a *App) revertAudit(l auditLogEntry) error {
+	switch l.Action {
+	case auditActionEntryInsert:
+		return a.data.DeleteEntry(l.EntityID)
+
+	case auditActionEntryUpdate, auditActionEntryDelete:
+		var e data.Entry
+		if err := json.Unmarshal(l.Before, &e); err != nil {
+			return fmt.Errorf("error parsing before-snapshot: %v", err)
+		}
+
+		if l.Action == auditActionEntryUpdate {
+			return a.data.UpdateEntry(l.EntityID, e)
+		}
+
+		// entry.delete: the entry no longer exists, so UpdateEntry has
+		// nothing to update -- re-insert it under its original id and GUID
+		// instead of letting InsertEntry mint a new id. Any relation,
+		// audit-log row or external link keyed to the original entry.id
+		// would otherwise be left pointing at an id nothing restores.
+		res, err := a.db.Exec(`
+			INSERT INTO entries (id, guid, content, initial, weight, tokens, lang, tags, phones, notes, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (id) DO NOTHING`,
+			l.EntityID, e.GUID, e.Content, e.Initial, e.Weight, e.Tokens, e.Lang, e.Tags, e.Phones, e.Notes, e.Status)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("entry id %d is already in use by another entry; cannot restore %q under its original id",
+				l.EntityID, e.GUID)
+		}
+		return nil
+
+	case auditActionRelationAdd:
+		_, err := a.db.Exec(`DELETE FROM relations WHERE id = $1`, l.EntityID)
+		return err
+
+	case auditActionRelationEdit, auditActionRelationDrop:
+		var r data.Relation
+		if err := json.Unmarshal(l.Before, &r); err != nil {
+			return fmt.Errorf("error parsing before-snapshot: %v", err)
+		}
+
+		if l.Action == auditActionRelationEdit {
+			return a.data.UpdateRelation(l.EntityID, r)
+		}
+
+		var rel relationSnapshot
+		if err := json.Unmarshal(l.Before, &rel); err != nil {
+			return fmt.Errorf("error parsing before-snapshot: %v", err)
+		}
+		_, err := a.data.InsertRelation(rel.FromID, rel.ToID, data.Relation{
+			Types: rel.Types, Tags: rel.Tags, Notes: rel.Notes, Weight: rel.Weight, Status: rel.Status,
+		})
+		return err
+
+	case auditActionRelationOrder:
+		var ids []int
+		if err := json.Unmarshal(l.Before, &ids); err != nil {
+			return fmt.Errorf("error parsing before-snapshot: %v", err)
+		}
+		return a.data.ReorderRelations(ids)
+	}
+
+	return fmt.Errorf("don't know how to revert action %q", l.Action)
+}
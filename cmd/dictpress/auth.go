@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+const (
+	authTypeBasic = "basic"
+	authTypeToken = "token"
+
+	// actorCtxKey is the echo.Context key the authenticated request's actor
+	// (the BasicAuth username, or the API token's name) is stored under, for
+	// HandleX handlers to attribute audit log entries to.
+	actorCtxKey = "actor"
+)
+
+// APIToken represents a revocable, scoped API token that can be used to
+// authenticate admin API requests as an alternative to HTTP BasicAuth.
+type APIToken struct {
+	ID         int            `json:"id" db:"id"`
+	Name       string         `json:"name" db:"name"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes"`
+	Enabled    bool           `json:"enabled" db:"enabled"`
+	LastUsedAt *time.Time     `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+}
+
+// generateRandomString returns a random hex-encoded string of n bytes.
+func generateRandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 hex digest of a token. Only the hash is
+// ever persisted; the plaintext token is shown to the user exactly once,
+// at creation time.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authMiddleware authenticates admin requests either via a scoped
+// `Authorization: Bearer <token>` API token, or by falling back to HTTP
+// BasicAuth. It's used in place of a plain middleware.BasicAuth() so that
+// third-party clients and bots can call write endpoints with scoped tokens
+// instead of the shared admin username/password.
+func (a *App) authMiddleware(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			h := c.Request().Header.Get("Authorization")
+
+			if tok, ok := strings.CutPrefix(h, "Bearer "); ok {
+				if err := a.authToken(c, tok, scope); err != nil {
+					return err
+				}
+				return next(c)
+			}
+
+			// Fall back to BasicAuth.
+			u, p, ok := c.Request().BasicAuth()
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing credentials")
+			}
+			if subtle.ConstantTimeCompare([]byte(u), a.consts.AdminUsername) != 1 ||
+				subtle.ConstantTimeCompare([]byte(p), a.consts.AdminPassword) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid credentials")
+			}
+
+			c.Set(isAuthed, true)
+			c.Set("auth_type", authTypeBasic)
+			c.Set(actorCtxKey, u)
+			return next(c)
+		}
+	}
+}
+
+// authToken validates a bearer token against the api_tokens table and checks
+// that it carries the required scope.
+func (a *App) authToken(c echo.Context, token, scope string) error {
+	var t APIToken
+	if err := a.db.Get(&t, `SELECT id, name, scopes, enabled FROM api_tokens WHERE token_hash = $1`, hashToken(token)); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
+	}
+
+	if !t.Enabled {
+		return echo.NewHTTPError(http.StatusUnauthorized, "token disabled")
+	}
+
+	if scope != "" && !hasScope(t.Scopes, scope) {
+		return echo.NewHTTPError(http.StatusForbidden, "token does not have the required scope")
+	}
+
+	// Best-effort last-used bump. Not fatal if it fails.
+	go a.db.Exec(`UPDATE api_tokens SET last_used_at = NOW() WHERE id = $1`, t.ID)
+
+	c.Set(isAuthed, true)
+	c.Set("auth_type", authTypeToken)
+	c.Set("token_id", t.ID)
+	c.Set(actorCtxKey, "token:"+t.Name)
+	return nil
+}
+
+// actor returns the authenticated request's actor (a BasicAuth username or
+// "token:<name>" for an API token), or "" if the request was never
+// authenticated through authMiddleware (eg: a public endpoint).
+func actor(c echo.Context) string {
+	a, _ := c.Get(actorCtxKey).(string)
+	return a
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleCreateAPIToken creates a new API token and returns the plaintext
+// token exactly once. Only its SHA-256 hash is stored thereafter.
+func (a *App) HandleCreateAPIToken(c echo.Context) error {
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "error parsing request")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid `name`")
+	}
+
+	token, err := generateRandomString(40)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error generating token")
+	}
+
+	var id int
+	if err := a.db.Get(&id,
+		`INSERT INTO api_tokens (name, token_hash, scopes) VALUES ($1, $2, $3) RETURNING id`,
+		req.Name, hashToken(token), pq.StringArray(req.Scopes)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error creating token")
+	}
+
+	return c.JSON(http.StatusOK, okResp{struct {
+		ID    int    `json:"id"`
+		Token string `json:"token"`
+	}{id, token}})
+}
+
+// HandleGetAPITokens lists all API tokens (without their hashes).
+func (a *App) HandleGetAPITokens(c echo.Context) error {
+	var out []APIToken
+	if err := a.db.Select(&out, `SELECT id, name, scopes, enabled, last_used_at, created_at FROM api_tokens ORDER BY id`); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error fetching tokens")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// HandleRevokeAPIToken disables an API token so it can no longer authenticate.
+func (a *App) HandleRevokeAPIToken(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid `id`")
+	}
+
+	if _, err := a.db.Exec(`UPDATE api_tokens SET enabled = FALSE WHERE id = $1`, id); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "error revoking token")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
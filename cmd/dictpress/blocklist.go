@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// compileBlocklist compiles a list of regular expression patterns used to
+// reject public submissions whose content matches any of them (eg: known
+// spam phrases, link shorteners).
+func compileBlocklist(patterns []string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid moderation.blocklist pattern %q: %v", p, err)
+		}
+		out = append(out, re)
+	}
+
+	return out, nil
+}
+
+// matchesBlocklist reports whether s matches any of the given patterns.
+func matchesBlocklist(s string, patterns []*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,752 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/dictpress/internal/data"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+const (
+	// bulkImportBatchSize is the number of rows inserted per DB transaction
+	// during a bulk import.
+	bulkImportBatchSize = 500
+
+	// bulkExportFlushEvery is the number of rows written to the response
+	// before the connection is flushed to the client.
+	bulkExportFlushEvery = 200
+
+	// idempotencyKeyHeader is the optional request header a client sends
+	// on a bulk import so that retrying the exact same request (eg: after
+	// a dropped connection) doesn't double-insert rows.
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotencyCachePrefix namespaces idempotency replay entries in
+	// a.cache away from cached search results.
+	idempotencyCachePrefix = "bulk-import-idempotency:"
+
+	// idempotencyInProgressTTL bounds how long an Idempotency-Key stays
+	// reserved for an import that's still running, so a crashed or
+	// abandoned request doesn't wedge the key forever.
+	idempotencyInProgressTTL = 10 * time.Minute
+)
+
+// idempotencyInProgress is the sentinel value reserved against an
+// Idempotency-Key the moment it's first seen, before the import itself
+// starts. It's distinct from any real cached response, which is always
+// valid NDJSON.
+var idempotencyInProgress = []byte("in-progress")
+
+// bulkImportRelation links the entry in a bulkImportRow to an already
+// existing entry, addressed either by ID (to_id) or, when the target's ID
+// isn't known up-front (eg: migrating a dictionary where entries are
+// addressed by their own GUIDs), by GUID (to_guid/from_guid). If from_guid
+// is omitted, the relation is assumed to originate from the entry being
+// imported in this row.
+type bulkImportRelation struct {
+	ToID     int    `json:"to_id" csv:"to_id"`
+	ToGUID   string `json:"to_guid,omitempty" csv:"to_guid"`
+	FromGUID string `json:"from_guid,omitempty" csv:"from_guid"`
+	data.Relation
+}
+
+// bulkImportRow is a single unit of work read off the NDJSON, JSON array, or
+// CSV/TSV stream given to HandleBulkImportEntries.
+type bulkImportRow struct {
+	data.Entry
+	Relations []bulkImportRelation `json:"relations,omitempty"`
+}
+
+// bulkImportResult reports the outcome of importing a single row.
+type bulkImportResult struct {
+	Line  int    `json:"line"`
+	ID    int    `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// bulkImportFormat negotiates the request body's row format: the `format`
+// query param ("json", "ndjson", "csv", or "tsv") takes precedence, falling
+// back to sniffing the `Content-Type` header (`text/csv` for CSV) so that
+// existing NDJSON/CSV callers keep working unchanged.
+func bulkImportFormat(c echo.Context) string {
+	switch strings.ToLower(c.QueryParam("format")) {
+	case "csv":
+		return "csv"
+	case "tsv":
+		return "tsv"
+	case "json", "ndjson", "jsonl":
+		return "json"
+	}
+
+	if strings.Contains(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		return "csv"
+	}
+
+	return "json"
+}
+
+// HandleBulkImportEntries bulk inserts entries, and optionally their
+// relations to already-existing entries, from a request body of JSON (a
+// single array, or newline-delimited), or CSV/TSV, negotiated by
+// bulkImportFormat.
+//
+// Rows are read off the request body and inserted in batches of
+// bulkImportBatchSize, each batch in its own DB transaction with the row
+// insert wrapped in a savepoint so that one bad row doesn't abort its
+// siblings. The outcome of every row -- its new entry ID, or an error -- is
+// streamed back to the client as NDJSON as it happens, so a client can drive
+// large imports (e.g. StarDict or Wiktionary dumps) without either side
+// buffering the whole request or response in memory.
+//
+// If the request carries an Idempotency-Key header, the key is reserved
+// against idempotencyInProgress the moment it's first seen (atomically, via
+// a.cache.Reserve), the full response is cached against it on completion,
+// and it's replayed verbatim -- without touching the DB -- on a retry with
+// the same key. A retry that arrives while the original import is still
+// running finds the in-progress reservation and gets a 409, rather than
+// racing the original to also import the same rows.
+func (a *App) HandleBulkImportEntries(c echo.Context) error {
+	idemKey := c.Request().Header.Get(idempotencyKeyHeader)
+	if idemKey != "" && a.cache != nil {
+		cacheKey := idempotencyCachePrefix + idemKey
+
+		inProgressTTL := idempotencyInProgressTTL
+		won, err := a.cache.Reserve(cacheKey, idempotencyInProgress, &inProgressTTL)
+		if err != nil {
+			a.lo.Printf("error reserving idempotency key: %v", err)
+		} else if !won {
+			cached, _ := a.cache.Get(cacheKey)
+			if bytes.Equal(cached, idempotencyInProgress) {
+				return echo.NewHTTPError(http.StatusConflict, "an import with this Idempotency-Key is already in progress")
+			}
+			if cached != nil {
+				c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+				c.Response().WriteHeader(http.StatusOK)
+				_, err := c.Response().Write(cached)
+				return err
+			}
+			// The reservation lost the race but the winner's key has since
+			// expired or been evicted. Fall through and import normally
+			// without caching the result, rather than serving nothing.
+		}
+	}
+
+	src := newBulkRowSource(c.Request().Body, bulkImportFormat(c))
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	c.Response().WriteHeader(http.StatusOK)
+
+	// When replaying for an Idempotency-Key, tee everything written to the
+	// client into a buffer that's cached once the import completes.
+	var (
+		out io.Writer = c.Response()
+		buf *bytes.Buffer
+	)
+	if idemKey != "" && a.cache != nil {
+		buf = &bytes.Buffer{}
+		out = io.MultiWriter(c.Response(), buf)
+	}
+
+	var (
+		enc     = json.NewEncoder(out)
+		flusher = c.Response().Writer.(http.Flusher)
+		line    = 0
+		done    = false
+	)
+	for !done {
+		batch := make([]bulkImportRow, 0, bulkImportBatchSize)
+		lines := make([]int, 0, bulkImportBatchSize)
+
+		for len(batch) < bulkImportBatchSize {
+			row, err := src.next()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			line++
+
+			if err != nil {
+				enc.Encode(bulkImportResult{Line: line, Error: err.Error()})
+				continue
+			}
+
+			batch = append(batch, row)
+			lines = append(lines, line)
+		}
+
+		if len(batch) == 0 {
+			break
+		}
+
+		res, err := a.bulkInsertEntries(batch, lines)
+		if err != nil {
+			// The batch's transaction itself failed (eg: DB connection
+			// dropped). Report it against every row in the batch.
+			for _, ln := range lines {
+				enc.Encode(bulkImportResult{Line: ln, Error: err.Error()})
+			}
+		} else {
+			for _, r := range res {
+				enc.Encode(r)
+			}
+		}
+
+		flusher.Flush()
+	}
+
+	if buf != nil {
+		if err := a.cache.Put(idempotencyCachePrefix+idemKey, buf.Bytes(), nil); err != nil {
+			a.lo.Printf("error caching bulk import result for idempotency key: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// bulkRowSource reads bulkImportRows one at a time off a CSV/TSV, JSON
+// array, or NDJSON request body, so that both the synchronous streaming
+// import handler and the asynchronous bulk_import job (cmd/dictpress/jobs.go)
+// share one row-parsing implementation.
+type bulkRowSource struct {
+	isCSV   bool
+	csvRd   *csv.Reader
+	dec     *json.Decoder
+	jsonArr bool
+	started bool
+}
+
+// newBulkRowSource negotiates format ("csv", "tsv", or "json"/"ndjson") and
+// returns a bulkRowSource reading rows off body.
+func newBulkRowSource(body io.Reader, format string) *bulkRowSource {
+	s := &bulkRowSource{isCSV: format == "csv" || format == "tsv"}
+	if s.isCSV {
+		s.csvRd = csv.NewReader(body)
+		s.csvRd.FieldsPerRecord = -1
+		if format == "tsv" {
+			s.csvRd.Comma = '\t'
+		}
+		return s
+	}
+
+	br := bufio.NewReader(body)
+	s.jsonArr = peekJSONArray(br)
+	s.dec = json.NewDecoder(br)
+
+	return s
+}
+
+// next returns the next row off the source, or io.EOF once exhausted.
+func (s *bulkRowSource) next() (bulkImportRow, error) {
+	if s.isCSV {
+		return readBulkImportCSVRow(s.csvRd)
+	}
+
+	if s.jsonArr {
+		if !s.started {
+			s.started = true
+			// Consume the opening `[` so that Decode() below reads one
+			// array element at a time instead of buffering the array.
+			if _, err := s.dec.Token(); err != nil {
+				return bulkImportRow{}, err
+			}
+		}
+		if !s.dec.More() {
+			return bulkImportRow{}, io.EOF
+		}
+	}
+
+	var row bulkImportRow
+	err := s.dec.Decode(&row)
+	return row, err
+}
+
+// peekJSONArray looks ahead (without consuming, beyond leading whitespace)
+// to tell apart a request body that's a single JSON array from one that's
+// newline-delimited JSON.
+func peekJSONArray(br *bufio.Reader) bool {
+	for {
+		b, err := br.Peek(1)
+		if err != nil || len(b) == 0 {
+			return false
+		}
+
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.Discard(1)
+			continue
+		}
+
+		return b[0] == '['
+	}
+}
+
+// bulkInsertEntries inserts a batch of rows (and their relations) in a
+// single DB transaction, using a savepoint per row so that a failing row is
+// rolled back on its own without discarding the rest of the batch.
+func (a *App) bulkInsertEntries(batch []bulkImportRow, lines []int) ([]bulkImportResult, error) {
+	tx, err := a.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		stmtEntry = tx.Stmtx(a.queries.InsertEntry)
+		stmtRel   = tx.Stmtx(a.queries.InsertRelation)
+		out       = make([]bulkImportResult, len(batch))
+	)
+	for i, row := range batch {
+		res := bulkImportResult{Line: lines[i]}
+
+		if _, err := tx.Exec("SAVEPOINT bulk_import_row"); err != nil {
+			return nil, fmt.Errorf("error creating savepoint: %v", err)
+		}
+
+		id, err := a.insertBulkEntry(tx, row, stmtEntry, stmtRel)
+		if err != nil {
+			res.Error = err.Error()
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT bulk_import_row"); err != nil {
+				return nil, fmt.Errorf("error rolling back to savepoint: %v", err)
+			}
+		} else {
+			res.ID = id
+			if _, err := tx.Exec("RELEASE SAVEPOINT bulk_import_row"); err != nil {
+				return nil, fmt.Errorf("error releasing savepoint: %v", err)
+			}
+		}
+
+		out[i] = res
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing batch: %v", err)
+	}
+
+	return out, nil
+}
+
+// insertBulkEntry validates, tokenizes, and inserts a single bulk import row
+// and its relations using the given transaction-bound statements.
+func (a *App) insertBulkEntry(tx *sqlx.Tx, row bulkImportRow, stmtEntry, stmtRel *sqlx.Stmt) (int, error) {
+	e, err := a.validateEntry(row.Entry)
+	if err != nil {
+		return 0, err
+	}
+
+	lang, ok := a.data.Langs[e.Lang]
+	if !ok {
+		return 0, fmt.Errorf("unknown `lang`")
+	}
+
+	var (
+		tsVectorLang = ""
+		tokens       = e.Tokens
+	)
+	if tokens == "" {
+		if lang.Tokenizer == nil {
+			tsVectorLang = lang.TokenizerName
+		} else {
+			toks, err := lang.Tokenizer.ToTokens(e.Content, e.Lang)
+			if err != nil {
+				return 0, fmt.Errorf("error tokenizing content: %v", err)
+			}
+			tokens = strings.Join(toks, " ")
+		}
+	}
+
+	if e.Status == "" {
+		e.Status = data.StatusEnabled
+	}
+
+	var id int
+	if err := stmtEntry.Get(&id, e.Content, e.Initial, e.Weight, tokens, tsVectorLang,
+		e.Lang, e.Tags, e.Phones, e.Notes, e.Status); err != nil {
+		return 0, fmt.Errorf("error inserting entry: %v", err)
+	}
+
+	for _, r := range row.Relations {
+		fromID := id
+		if r.FromGUID != "" {
+			if err := tx.Get(&fromID, `SELECT id FROM entries WHERE guid = $1`, r.FromGUID); err != nil {
+				return 0, fmt.Errorf("error resolving from_guid %q: %v", r.FromGUID, err)
+			}
+		}
+
+		toID := r.ToID
+		if toID < 1 {
+			if r.ToGUID == "" {
+				return 0, fmt.Errorf("invalid `to_id`/`to_guid` in relation")
+			}
+			if err := tx.Get(&toID, `SELECT id FROM entries WHERE guid = $1`, r.ToGUID); err != nil {
+				return 0, fmt.Errorf("error resolving to_guid %q: %v", r.ToGUID, err)
+			}
+		}
+
+		if r.Status == "" {
+			r.Status = data.StatusEnabled
+		}
+
+		var relID int
+		if err := stmtRel.Get(&relID, fromID, toID, r.Types, r.Tags, r.Notes, r.Weight, r.Status); err != nil {
+			return 0, fmt.Errorf("error inserting relation to #%d: %v", toID, err)
+		}
+	}
+
+	return id, nil
+}
+
+// readBulkImportCSVRow reads and parses one CSV/TSV row of the form:
+// content,initial,lang,tags,phones,notes,weight
+// where tags and phones are pipe (|) separated, mirroring the CSV convention
+// used by the internal/importer package. CSV/TSV rows cannot describe
+// relations; use JSON for that.
+func readBulkImportCSVRow(rd *csv.Reader) (bulkImportRow, error) {
+	rec, err := rd.Read()
+	if err != nil {
+		return bulkImportRow{}, err
+	}
+
+	if len(rec) < 6 {
+		return bulkImportRow{}, fmt.Errorf("expected at least 6 columns (content,initial,lang,tags,phones,notes[,weight]), found %d", len(rec))
+	}
+
+	row := bulkImportRow{Entry: data.Entry{
+		Content: strings.TrimSpace(rec[0]),
+		Initial: strings.TrimSpace(rec[1]),
+		Lang:    strings.TrimSpace(rec[2]),
+		Tags:    splitBulkPipe(rec[3]),
+		Phones:  splitBulkPipe(rec[4]),
+		Notes:   strings.TrimSpace(rec[5]),
+	}}
+
+	if len(rec) > 6 && strings.TrimSpace(rec[6]) != "" {
+		w, err := strconv.ParseFloat(strings.TrimSpace(rec[6]), 64)
+		if err != nil {
+			return bulkImportRow{}, fmt.Errorf("invalid weight: %v", err)
+		}
+		row.Weight = w
+	}
+
+	return row, nil
+}
+
+// splitBulkPipe splits a pipe (|) separated CSV field into a string array.
+func splitBulkPipe(s string) pq.StringArray {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return pq.StringArray{}
+	}
+
+	parts := strings.Split(s, "|")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	return pq.StringArray(parts)
+}
+
+// bulkDeleteResult reports the outcome of deleting a single GUID.
+type bulkDeleteResult struct {
+	GUID  string `json:"guid"`
+	Error string `json:"error,omitempty"`
+}
+
+// HandleBulkDeleteEntries deletes a batch of entries identified by GUID,
+// given as a JSON array in the request body. As with bulk import, deletes
+// run in batches of bulkImportBatchSize, each in its own transaction with a
+// savepoint per row, so one missing/bad GUID doesn't abort the rest of the
+// batch. Per-GUID outcomes are streamed back as NDJSON.
+func (a *App) HandleBulkDeleteEntries(c echo.Context) error {
+	var guids []string
+	if err := json.NewDecoder(c.Request().Body).Decode(&guids); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("error parsing request: %v", err))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	c.Response().WriteHeader(http.StatusOK)
+
+	var (
+		enc     = json.NewEncoder(c.Response())
+		flusher = c.Response().Writer.(http.Flusher)
+	)
+	for i := 0; i < len(guids); i += bulkImportBatchSize {
+		batch := guids[i:min(i+bulkImportBatchSize, len(guids))]
+
+		res, err := a.bulkDeleteEntries(batch)
+		if err != nil {
+			for _, guid := range batch {
+				enc.Encode(bulkDeleteResult{GUID: guid, Error: err.Error()})
+			}
+		} else {
+			for _, r := range res {
+				enc.Encode(r)
+			}
+		}
+
+		flusher.Flush()
+	}
+
+	if len(guids) > 0 {
+		a.invalidateSearchCache()
+	}
+
+	return nil
+}
+
+// bulkDeleteEntries deletes a batch of entries by GUID in a single DB
+// transaction, using a savepoint per row so that one missing GUID doesn't
+// roll back its siblings.
+func (a *App) bulkDeleteEntries(guids []string) ([]bulkDeleteResult, error) {
+	tx, err := a.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	out := make([]bulkDeleteResult, len(guids))
+	for i, guid := range guids {
+		res := bulkDeleteResult{GUID: guid}
+
+		if _, err := tx.Exec("SAVEPOINT bulk_delete_row"); err != nil {
+			return nil, fmt.Errorf("error creating savepoint: %v", err)
+		}
+
+		r, err := tx.Exec(`DELETE FROM entries WHERE guid = $1`, guid)
+		if err == nil {
+			if n, _ := r.RowsAffected(); n == 0 {
+				err = fmt.Errorf("no entry found for guid")
+			}
+		}
+
+		if err != nil {
+			res.Error = err.Error()
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT bulk_delete_row"); err != nil {
+				return nil, fmt.Errorf("error rolling back to savepoint: %v", err)
+			}
+		} else if _, err := tx.Exec("RELEASE SAVEPOINT bulk_delete_row"); err != nil {
+			return nil, fmt.Errorf("error releasing savepoint: %v", err)
+		}
+
+		out[i] = res
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing batch: %v", err)
+	}
+
+	return out, nil
+}
+
+// bulkExportEntry is the flattened, exported shape of a data.Entry, used for
+// CSV and XML export where relations aren't walked.
+type bulkExportEntry struct {
+	XMLName xml.Name `json:"-" xml:"entry"`
+
+	ID      int            `json:"id,omitempty" xml:"id,attr" db:"id"`
+	GUID    string         `json:"guid" xml:"guid,attr" db:"guid"`
+	Weight  float64        `json:"weight" xml:"weight" db:"weight"`
+	Initial string         `json:"initial" xml:"initial" db:"initial"`
+	Lang    string         `json:"lang" xml:"lang" db:"lang"`
+	Content string         `json:"content" xml:"content" db:"content"`
+	Tags    pq.StringArray `json:"tags" xml:"-" db:"tags"`
+	Phones  pq.StringArray `json:"phones" xml:"-" db:"phones"`
+	Notes   string         `json:"notes" xml:"notes,omitempty" db:"notes"`
+	Status  string         `json:"status" xml:"status,attr" db:"status"`
+}
+
+// HandleBulkExportEntries streams every entry, optionally filtered by
+// language, initial letter, and/or a lower bound on `created_at`, as NDJSON
+// (format=jsonl, the default), CSV (format=csv), or XML (format=xml).
+// Rows are read off a `sqlx` cursor and written to the response one at a
+// time so the full result set is never buffered in memory, making this
+// usable to export dictionaries with millions of entries.
+func (a *App) HandleBulkExportEntries(c echo.Context) error {
+	var (
+		lang    = firstNonEmpty(c.QueryParam("fromLang"), c.QueryParam("lang"))
+		initial = c.QueryParam("initial")
+		fromStr = c.QueryParam("from")
+		format  = strings.ToLower(c.QueryParam("format"))
+	)
+	if format == "" {
+		format = "jsonl"
+	}
+
+	if lang != "" {
+		if _, ok := a.data.Langs[lang]; !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "unknown `lang`")
+		}
+	}
+
+	var from sql.NullTime
+	if fromStr != "" {
+		t, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid `from`, expecting an RFC3339 timestamp")
+		}
+		from = sql.NullTime{Time: t, Valid: true}
+	}
+
+	rows, err := a.db.Queryx(`
+		SELECT id, guid, weight, initial, lang, content, tokens, tags, phones, notes, status, created_at, updated_at
+		FROM entries
+		WHERE ($1 = '' OR lang = $1)
+			AND ($2 = '' OR initial = $2)
+			AND ($3::TIMESTAMPTZ IS NULL OR created_at >= $3)
+		ORDER BY id`, lang, initial, from)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Sprintf("error querying entries: %v", err))
+	}
+	defer rows.Close()
+
+	switch format {
+	case "csv":
+		return a.streamExportCSV(c, rows)
+	case "xml":
+		return a.streamExportXML(c, rows)
+	default:
+		return a.streamExportJSONL(c, rows)
+	}
+}
+
+// streamExportJSONL writes the given entry rows as newline-delimited JSON.
+func (a *App) streamExportJSONL(c echo.Context, rows *sqlx.Rows) error {
+	c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	c.Response().WriteHeader(http.StatusOK)
+
+	var (
+		enc     = json.NewEncoder(c.Response())
+		flusher = c.Response().Writer.(http.Flusher)
+		n       = 0
+	)
+	for rows.Next() {
+		var e data.Entry
+		if err := rows.StructScan(&e); err != nil {
+			return err
+		}
+
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+
+		n++
+		if n%bulkExportFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+	flusher.Flush()
+
+	return rows.Err()
+}
+
+// streamExportCSV writes the given entry rows as CSV, with tags and phones
+// pipe (|) separated, mirroring the bulk import CSV convention.
+func (a *App) streamExportCSV(c echo.Context, rows *sqlx.Rows) error {
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	c.Response().WriteHeader(http.StatusOK)
+
+	var (
+		w       = csv.NewWriter(c.Response())
+		flusher = c.Response().Writer.(http.Flusher)
+		n       = 0
+	)
+	if err := w.Write([]string{"guid", "content", "initial", "lang", "tags", "phones", "notes", "weight", "status"}); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var e data.Entry
+		if err := rows.StructScan(&e); err != nil {
+			return err
+		}
+
+		if err := w.Write([]string{
+			e.GUID, e.Content, e.Initial, e.Lang,
+			strings.Join(e.Tags, "|"), strings.Join(e.Phones, "|"), e.Notes,
+			strconv.FormatFloat(e.Weight, 'f', -1, 64), e.Status,
+		}); err != nil {
+			return err
+		}
+
+		n++
+		if n%bulkExportFlushEvery == 0 {
+			w.Flush()
+			flusher.Flush()
+		}
+	}
+	w.Flush()
+	flusher.Flush()
+
+	return rows.Err()
+}
+
+// streamExportXML writes the given entry rows as XML, one <entry> element
+// flushed to the client at a time.
+func (a *App) streamExportXML(c echo.Context, rows *sqlx.Rows) error {
+	c.Response().Header().Set(echo.HeaderContentType, "application/xml")
+	c.Response().WriteHeader(http.StatusOK)
+
+	var (
+		flusher = c.Response().Writer.(http.Flusher)
+		n       = 0
+	)
+	if _, err := c.Response().Write([]byte(xml.Header + "<entries>\n")); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(c.Response())
+	for rows.Next() {
+		var e data.Entry
+		if err := rows.StructScan(&e); err != nil {
+			return err
+		}
+
+		ee := bulkExportEntry{
+			ID: e.ID, GUID: e.GUID, Weight: e.Weight, Initial: e.Initial,
+			Lang: e.Lang, Content: e.Content, Tags: e.Tags, Phones: e.Phones,
+			Notes: e.Notes, Status: e.Status,
+		}
+		if err := enc.Encode(ee); err != nil {
+			return err
+		}
+		if _, err := c.Response().Write([]byte("\n")); err != nil {
+			return err
+		}
+
+		n++
+		if n%bulkExportFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+
+	if _, err := c.Response().Write([]byte("</entries>\n")); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return rows.Err()
+}
+
+// firstNonEmpty returns the first non-empty string among vals.
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
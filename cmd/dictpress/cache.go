@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/knadh/dictpress/internal/cache"
+	"github.com/knadh/dictpress/internal/data"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// searchQueryLogRetentionDays bounds how long search_query_log rows are
+// kept; only recent traffic should influence cache warmup.
+const searchQueryLogRetentionDays = 7
+
+// invalidateSearchCache drops all cached search/glossary results. It's the
+// fallback used by invalidateEntryCache for cache backends (eg: the
+// in-process Badger cache) that can't evict more surgically than
+// "everything", and is also called directly after bulk/import-scale
+// mutations where no single lang/entry is a meaningful invalidation key.
+func (a *App) invalidateSearchCache() {
+	if a.cache == nil {
+		return
+	}
+
+	if err := a.cache.Purge(); err != nil {
+		a.lo.Printf("error purging cache: %v", err)
+	}
+}
+
+// invalidateEntryCache evicts cached search results for lang after a change
+// to entryID. On a cache.Invalidator backend (RedisCache) this publishes a
+// pub/sub message that every dictpress instance sharing the cache, including
+// this one, reacts to by evicting lang's keys; other backends fall back to
+// invalidateSearchCache's blunt full purge.
+func (a *App) invalidateEntryCache(lang string, entryID int) {
+	if a.cache == nil {
+		return
+	}
+
+	inv, ok := a.cache.(cache.Invalidator)
+	if !ok {
+		a.invalidateSearchCache()
+		return
+	}
+
+	if err := inv.PublishInvalidation(lang, entryID); err != nil {
+		a.lo.Printf("error publishing cache invalidation for lang %s: %v", lang, err)
+	}
+}
+
+// invalidateEntryCacheByID looks up entryID's language before evicting its
+// cached search results. Used by relation handlers, which only have an
+// entry id (not its language) at hand.
+func (a *App) invalidateEntryCacheByID(entryID int) {
+	e, err := a.data.GetEntry(entryID, "")
+	if err != nil {
+		a.invalidateSearchCache()
+		return
+	}
+
+	a.invalidateEntryCache(e.Lang, entryID)
+}
+
+// HandlePurgeCache purges all cached search/glossary results.
+func (a *App) HandlePurgeCache(c echo.Context) error {
+	a.invalidateSearchCache()
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// logSearchQuery records q against search_query_log for warmupCache to
+// replay on the next start. It's fire-and-forget off the request goroutine
+// since it's purely advisory: a lost row just means that query doesn't get
+// warmed next time around.
+func (a *App) logSearchQuery(q data.Query) {
+	go func() {
+		if _, err := a.db.Exec(`
+			INSERT INTO search_query_log (from_lang, to_lang, query, types, tags, status)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			q.FromLang, q.ToLang, q.Query, pq.StringArray(q.Types), pq.StringArray(q.Tags), q.Status); err != nil {
+			a.lo.Printf("error logging search query: %v", err)
+		}
+	}()
+}
+
+// warmupCache replays the consts.CacheWarmupTopN most frequent queries per
+// from-language logged over the last 24h against a.cache, so that early
+// traffic after a deploy or cache flush isn't all Postgres misses. It's
+// best-effort and runs once, in the background, at boot.
+func (a *App) warmupCache() {
+	if a.cache == nil || a.consts.CacheWarmupTopN <= 0 {
+		return
+	}
+
+	if _, err := a.db.Exec(
+		`DELETE FROM search_query_log WHERE created_at < NOW() - ($1 || ' days')::INTERVAL`,
+		searchQueryLogRetentionDays); err != nil {
+		a.lo.Printf("error pruning search query log: %v", err)
+	}
+
+	type loggedQuery struct {
+		FromLang string         `db:"from_lang"`
+		ToLang   string         `db:"to_lang"`
+		Query    string         `db:"query"`
+		Types    pq.StringArray `db:"types"`
+		Tags     pq.StringArray `db:"tags"`
+		Status   string         `db:"status"`
+		Hits     int            `db:"hits"`
+	}
+
+	var logged []loggedQuery
+	if err := a.db.Select(&logged, `
+		SELECT from_lang, to_lang, query, types, tags, status, COUNT(*) AS hits
+		FROM search_query_log
+		WHERE created_at > NOW() - INTERVAL '24 hours'
+		GROUP BY from_lang, to_lang, query, types, tags, status
+		ORDER BY hits DESC
+		LIMIT $1`, a.consts.CacheWarmupTopN); err != nil {
+		a.lo.Printf("error loading queries for cache warmup: %v", err)
+		return
+	}
+
+	for _, lg := range logged {
+		q := data.Query{
+			FromLang: lg.FromLang,
+			ToLang:   lg.ToLang,
+			Query:    lg.Query,
+			Types:    []string(lg.Types),
+			Tags:     []string(lg.Tags),
+			Status:   lg.Status,
+		}
+
+		if _, _, err := a.searchCached(context.Background(), q, false, a.resultsPg); err != nil {
+			a.lo.Printf("error warming cache for query %q (%s): %v", q.Query, q.FromLang, err)
+		}
+	}
+
+	a.lo.Printf("cache warmup: replayed %d queries", len(logged))
+}
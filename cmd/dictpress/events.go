@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// eventRingSize is how many recent events are kept in memory for
+// Last-Event-ID replay when a client reconnects.
+const eventRingSize = 200
+
+// adminEvent is a single admin notification streamed over /api/admin/events,
+// published whenever a new public submission or comment is recorded, or an
+// entry is otherwise updated in a way admins should know about.
+//
+// Event schema (JSON, one per SSE `data:` line):
+//
+//	{"id": 42, "type": "submission"|"comment"|"entry_updated", "entry_id": 7, "guid": "...", "lang": "english", "at": "2024-01-01T00:00:00Z"}
+type adminEvent struct {
+	ID      int64     `json:"id"`
+	Type    string    `json:"type"`
+	EntryID int       `json:"entry_id,omitempty"`
+	GUID    string    `json:"guid,omitempty"`
+	Lang    string    `json:"lang,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+func (e adminEvent) withID(id int64, at time.Time) adminEvent {
+	e.ID, e.At = id, at
+	return e
+}
+
+func (e adminEvent) eventID() int64 { return e.ID }
+
+// eventHub fans out adminEvents to every subscribed SSE client and replays
+// recently missed events to a client that reconnects with Last-Event-ID. It
+// wraps the generic sseHub (see sse.go), shared with entryEventHub, adding
+// only the admin-specific Publish API.
+type eventHub struct {
+	*sseHub[adminEvent]
+}
+
+// newEventHub returns an empty eventHub.
+func newEventHub() *eventHub {
+	return &eventHub{sseHub: newSSEHub[adminEvent](eventRingSize)}
+}
+
+// Publish stamps an event with an ID and timestamp, fans it out to every
+// current subscriber, and appends it to the replay ring buffer.
+func (h *eventHub) Publish(typ string, entryID int, guid, lang string) {
+	h.publish(adminEvent{Type: typ, EntryID: entryID, GUID: guid, Lang: lang})
+}
+
+// HandleAdminEvents streams live admin notifications (new pending
+// submissions and comments, entry updates) as Server-Sent Events. Clients
+// may send a `Last-Event-ID` header on reconnect to replay events missed
+// while disconnected, up to the last eventRingSize events.
+func (a *App) HandleAdminEvents(c echo.Context) error {
+	return streamSSE(c, a.events.sseHub, nil)
+}
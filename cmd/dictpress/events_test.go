@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// readSSEEvent is a minimal example SSE client: it reads one `id:`/`data:`
+// event pair off an SSE response body, the same way a browser's EventSource
+// (or any long-lived HTTP client) would consume /api/admin/events.
+func readSSEEvent(t *testing.T, r *bufio.Reader) adminEvent {
+	t.Helper()
+
+	var id, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("error reading SSE stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			if data != "" {
+				var e adminEvent
+				if err := json.Unmarshal([]byte(data), &e); err != nil {
+					t.Fatalf("error decoding SSE event %q (id=%s): %v", data, id, err)
+				}
+				return e
+			}
+		}
+	}
+}
+
+func TestEventHubPublishSubscribe(t *testing.T) {
+	h := newEventHub()
+
+	ch, replay := h.Subscribe(0)
+	defer h.Unsubscribe(ch)
+	if len(replay) != 0 {
+		t.Fatalf("expected no replay for a fresh subscriber, got %d", len(replay))
+	}
+
+	h.Publish("submission", 42, "", "english")
+
+	select {
+	case e := <-ch:
+		if e.Type != "submission" || e.EntryID != 42 || e.Lang != "english" {
+			t.Fatalf("unexpected event: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for published event")
+	}
+}
+
+func TestEventHubReplay(t *testing.T) {
+	h := newEventHub()
+
+	h.Publish("submission", 1, "", "english")
+	h.Publish("comment", 0, "guid-2", "")
+	h.Publish("entry_updated", 3, "guid-3", "italian")
+
+	// A client that missed the first event reconnects with Last-Event-ID=1
+	// and should only be replayed events 2 and 3.
+	ch, replay := h.Subscribe(1)
+	defer h.Unsubscribe(ch)
+
+	if len(replay) != 2 {
+		t.Fatalf("expected 2 replayed events, got %d", len(replay))
+	}
+	if replay[0].Type != "comment" || replay[1].Type != "entry_updated" {
+		t.Fatalf("unexpected replay order: %+v", replay)
+	}
+}
+
+// TestHandleAdminEventsStream exercises the actual HTTP handler end to end:
+// a client connects, the hub publishes an event, and the client reads it off
+// the wire exactly as a browser EventSource or the readSSEEvent helper above
+// would.
+func TestHandleAdminEventsStream(t *testing.T) {
+	a := &App{events: newEventHub(), lo: lo}
+
+	e := echo.New()
+	e.GET("/api/admin/events", a.HandleAdminEvents)
+
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/admin/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected Content-Type: %s", ct)
+	}
+
+	// Give the handler a moment to subscribe before publishing, since the
+	// subscription happens asynchronously from the client's point of view.
+	time.Sleep(50 * time.Millisecond)
+	a.events.Publish("submission", 7, "", "english")
+
+	got := readSSEEvent(t, bufio.NewReader(resp.Body))
+	if got.Type != "submission" || got.EntryID != 7 {
+		t.Fatalf("unexpected event over the wire: %+v", got)
+	}
+}
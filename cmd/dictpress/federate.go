@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// feedRingSize is how many recent changes are kept in memory per fromLang
+// for the Atom feed, and across all languages for the ActivityPub outbox.
+const feedRingSize = 50
+
+// feedHTTPTimeout bounds how long a WebSub hub ping or ActivityPub inbox
+// delivery is allowed to take; both are best-effort and run off the
+// request's goroutine.
+const feedHTTPTimeout = 10 * time.Second
+
+// entryChange is a single published new/updated entry, the unit the Atom
+// feed and the ActivityPub outbox both render from. ToLang is best-effort
+// (the lang of the entry's first loaded relation, if any) and is only used
+// for display; feeds are keyed by FromLang alone since a freshly
+// inserted/updated entry doesn't always have its relations loaded yet.
+type entryChange struct {
+	GUID     string
+	FromLang string
+	ToLang   string
+	Content  string
+	Notes    string
+	At       time.Time
+}
+
+// feedPublisher is the optional outbound WebSub/ActivityPub subsystem.
+// A nil *feedPublisher on App disables federation entirely, the same
+// convention a.cache and a.spamScorer use.
+type feedPublisher struct {
+	rootURL string
+	hub     string // WebSub hub to ping; empty skips the ping.
+	actor   string // ActivityPub actor slug, eg "dictionary" -> /ap/actor.
+	key     *rsa.PrivateKey
+	keyID   string // referenced by the `keyId` param of every HTTP Signature.
+	client  *http.Client
+	db      *sqlx.DB
+
+	mu      sync.Mutex
+	changes map[string][]entryChange // keyed by "fromLang/toLang".
+	outbox  []apActivity
+}
+
+// newFeedPublisher parses consts' PEM-encoded ActivityPub key and returns a
+// feedPublisher, or nil if federation isn't enabled.
+func newFeedPublisher(consts Consts, db *sqlx.DB) (*feedPublisher, error) {
+	if !consts.FederationEnabled {
+		return nil, nil
+	}
+
+	key, err := parseRSAPrivateKeyPEM(consts.FederationPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing federation.private_key: %v", err)
+	}
+
+	actor := consts.FederationActorName
+	if actor == "" {
+		actor = "dictionary"
+	}
+
+	return &feedPublisher{
+		rootURL: strings.TrimRight(consts.RootURL, "/"),
+		hub:     consts.FederationWebSubHub,
+		actor:   actor,
+		key:     key,
+		keyID:   strings.TrimRight(consts.RootURL, "/") + "/ap/actor#main-key",
+		client:  &http.Client{Timeout: feedHTTPTimeout},
+		db:      db,
+		changes: make(map[string][]entryChange),
+	}, nil
+}
+
+// parseRSAPrivateKeyPEM decodes a PKCS#1 or PKCS#8 PEM-encoded RSA private
+// key, as configured in federation.private_key.
+func parseRSAPrivateKeyPEM(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key is not an RSA private key")
+	}
+	return rsaKey, nil
+}
+
+// Publish records a new/updated entry, appends it to the relevant Atom
+// feed's ring and the ActivityPub outbox, and fans it out to subscribers:
+// a WebSub hub ping (if configured) and signed deliveries to every
+// follower's inbox. Delivery runs in background goroutines so a slow or
+// unreachable hub/follower never blocks the admin mutation that triggered
+// it, the same tradeoff a.recordAudit and a.invalidateSearchCache make.
+func (f *feedPublisher) Publish(e entryChange) {
+	if f == nil {
+		return
+	}
+
+	e.At = time.Now()
+
+	f.mu.Lock()
+	f.changes[e.FromLang] = prependCapped(f.changes[e.FromLang], e, feedRingSize)
+	act := f.newCreateActivity(e)
+	f.outbox = append([]apActivity{act}, f.outbox...)
+	if len(f.outbox) > feedRingSize {
+		f.outbox = f.outbox[:feedRingSize]
+	}
+	f.mu.Unlock()
+
+	if f.hub != "" {
+		go f.pingWebSubHub(f.feedURL(e.FromLang))
+	}
+
+	go f.deliverToFollowers(act)
+}
+
+// prependCapped prepends e to list, newest first, truncated to max.
+func prependCapped(list []entryChange, e entryChange, max int) []entryChange {
+	list = append([]entryChange{e}, list...)
+	if len(list) > max {
+		list = list[:max]
+	}
+	return list
+}
+
+// feedURL is the public Atom feed URL (the WebSub "topic") for fromLang's
+// recent changes. toLang is "*" (any), matching the `?to=*` convention
+// doSearch uses for an unfiltered target language, since a feed is keyed by
+// FromLang alone.
+func (f *feedPublisher) feedURL(fromLang string) string {
+	return fmt.Sprintf("%s/feed/%s/*.atom", f.rootURL, fromLang)
+}
+
+// pingWebSubHub notifies the configured hub that topic has new content, per
+// the WebSub spec (https://www.w3.org/TR/websub/#content-distribution):
+// a plain `hub.mode=publish&hub.url=<topic>` form POST. The hub is expected
+// to then fetch topic itself to pick up the new entries.
+func (f *feedPublisher) pingWebSubHub(topic string) {
+	form := url.Values{"hub.mode": {"publish"}, "hub.url": {topic}}
+	resp, err := f.client.PostForm(f.hub, form)
+	if err != nil {
+		lo.Printf("error pinging websub hub %s: %v", f.hub, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		lo.Printf("websub hub %s rejected ping for %s: %s", f.hub, topic, resp.Status)
+	}
+}
+
+// publishEntryChange fetches entry id and, if federation is enabled,
+// publishes it as an entryChange. Called after an entry is inserted,
+// updated, or a submission for it is approved; failures are logged, not
+// returned, the same tradeoff a.recordAudit and a.invalidateSearchCache
+// make for their own post-mutation side effects.
+func (a *App) publishEntryChange(id int) {
+	if a.feed == nil {
+		return
+	}
+
+	e, err := a.data.GetEntry(id, "")
+	if err != nil {
+		a.lo.Printf("error loading entry #%d for federation: %v", id, err)
+		return
+	}
+
+	toLang := ""
+	if len(e.Relations) > 0 {
+		toLang = e.Relations[0].Lang
+	}
+
+	a.feed.Publish(entryChange{
+		GUID:     e.GUID,
+		FromLang: e.Lang,
+		ToLang:   toLang,
+		Content:  e.Content,
+		Notes:    e.Notes,
+	})
+}
+
+// HandleFeed serves the Atom feed of recent changes to fromLang entries, the
+// WebSub "topic" URL subscribers poll/are notified about. toLang is kept in
+// the URL for symmetry with the rest of the dictionary API but isn't
+// filtered on: "*" (any) or a specific known language are both accepted.
+func (a *App) HandleFeed(c echo.Context) error {
+	var (
+		fromLang = c.Param("fromLang")
+		toLang   = strings.TrimSuffix(c.Param("toLangExt"), ".atom")
+	)
+
+	if _, ok := a.data.Langs[fromLang]; !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown `fromLang`")
+	}
+	if toLang != "*" {
+		if _, ok := a.data.Langs[toLang]; !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "unknown `toLang`")
+		}
+	}
+
+	var changes []entryChange
+	if a.feed != nil {
+		a.feed.mu.Lock()
+		changes = append([]entryChange{}, a.feed.changes[fromLang]...)
+		a.feed.mu.Unlock()
+	}
+
+	feed := atomFeed{
+		XMLNS:   "http://www.w3.org/2005/Atom",
+		ID:      fmt.Sprintf("%s/feed/%s/%s.atom", a.consts.RootURL, fromLang, toLang),
+		Title:   fmt.Sprintf("%s -> %s new entries", fromLang, toLang),
+		Updated: time.Now().Format(time.RFC3339),
+		Link: []atomLink{
+			{Rel: "self", Href: fmt.Sprintf("%s/feed/%s/%s.atom", a.consts.RootURL, fromLang, toLang)},
+		},
+	}
+	if a.feed != nil && a.feed.hub != "" {
+		feed.Link = append(feed.Link, atomLink{Rel: "hub", Href: a.feed.hub})
+	}
+
+	for _, ch := range changes {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("%s/dictionary/%s/%s/%s", a.consts.RootURL, fromLang, toLang, ch.GUID),
+			Title:   ch.Content,
+			Updated: ch.At.Format(time.RFC3339),
+			Summary: ch.Notes,
+			Link:    atomLink{Rel: "alternate", Href: fmt.Sprintf("%s/dictionary/%s/%s/%s", a.consts.RootURL, fromLang, toLang, ch.GUID)},
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/atom+xml")
+	return c.XMLBlob(http.StatusOK, append([]byte(xml.Header), mustMarshalXML(feed)...))
+}
+
+func mustMarshalXML(v interface{}) []byte {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// atomFeed and its children model the subset of the Atom syndication format
+// (RFC 4287) that HandleFeed renders.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary,omitempty"`
+	Link    atomLink `xml:"link"`
+}
+
+// apActivity is an ActivityStreams `Create{Note}` activity, the shape every
+// new/updated entry is published as in the ActivityPub outbox and to
+// followers' inboxes.
+type apActivity struct {
+	Context string   `json:"@context"`
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Actor   string   `json:"actor"`
+	Object  apNote   `json:"object"`
+	To      []string `json:"to"`
+}
+
+type apNote struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	AttributedTo string `json:"attributedTo"`
+	Content      string `json:"content"`
+	Published    string `json:"published"`
+}
+
+// newCreateActivity wraps e as a Create{Note} activity attributed to this
+// instance's actor.
+func (f *feedPublisher) newCreateActivity(e entryChange) apActivity {
+	actorURL := f.rootURL + "/ap/actor"
+	noteID := fmt.Sprintf("%s/ap/notes/%s", f.rootURL, e.GUID)
+
+	content := e.Content
+	if e.Notes != "" {
+		content = fmt.Sprintf("%s — %s", e.Content, e.Notes)
+	}
+
+	return apActivity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		ID:      noteID + "/activity",
+		Type:    "Create",
+		Actor:   actorURL,
+		To:      []string{"https://www.w3.org/ns/activitystreams#Public"},
+		Object: apNote{
+			ID:           noteID,
+			Type:         "Note",
+			AttributedTo: actorURL,
+			Content:      content,
+			Published:    e.At.Format(time.RFC3339),
+		},
+	}
+}
+
+// HandleActor serves this instance's ActivityPub actor document, a
+// `Service` representing the dictionary as a whole (rather than any single
+// editor), carrying the public key Fediverse servers use to verify the HTTP
+// Signatures on deliveries from its outbox.
+func (a *App) HandleActor(c echo.Context) error {
+	if a.feed == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "federation is not enabled")
+	}
+
+	actorURL := a.feed.rootURL + "/ap/actor"
+	pubPEM, err := marshalRSAPublicKeyPEM(&a.feed.key.PublicKey)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error encoding public key: %v", err))
+	}
+
+	out := map[string]interface{}{
+		"@context":          []string{"https://www.w3.org/ns/activitystreams", "https://w3id.org/security/v1"},
+		"id":                actorURL,
+		"type":              "Service",
+		"preferredUsername": a.feed.actor,
+		"name":              a.consts.Site,
+		"inbox":             a.feed.rootURL + "/ap/inbox",
+		"outbox":            a.feed.rootURL + "/ap/outbox",
+		"publicKey": map[string]string{
+			"id":           actorURL + "#main-key",
+			"owner":        actorURL,
+			"publicKeyPem": pubPEM,
+		},
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/activity+json")
+	return c.JSON(http.StatusOK, out)
+}
+
+// marshalRSAPublicKeyPEM PEM-encodes a public key in PKIX form, as expected
+// in an ActivityPub actor's publicKeyPem field.
+func marshalRSAPublicKeyPEM(pub *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// HandleOutbox serves the most recent Create{Note} activities as an
+// ActivityStreams OrderedCollection, letting a Fediverse instance backfill
+// recent words on first discovering this actor.
+func (a *App) HandleOutbox(c echo.Context) error {
+	if a.feed == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "federation is not enabled")
+	}
+
+	a.feed.mu.Lock()
+	items := append([]apActivity{}, a.feed.outbox...)
+	a.feed.mu.Unlock()
+
+	out := map[string]interface{}{
+		"@context":     "https://www.w3.org/ns/activitystreams",
+		"id":           a.feed.rootURL + "/ap/outbox",
+		"type":         "OrderedCollection",
+		"totalItems":   len(items),
+		"orderedItems": items,
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/activity+json")
+	return c.JSON(http.StatusOK, out)
+}
+
+// HandleInbox accepts incoming ActivityPub activities delivered to this
+// actor's inbox. Only `Follow` is acted on: the follower's inbox is
+// resolved and recorded in ap_followers, and a signed `Accept{Follow}` is
+// delivered back to confirm the subscription.
+func (a *App) HandleInbox(c echo.Context) error {
+	if a.feed == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "federation is not enabled")
+	}
+
+	var act struct {
+		ID     string `json:"id"`
+		Type   string `json:"type"`
+		Actor  string `json:"actor"`
+		Object string `json:"object"`
+	}
+	if err := json.NewDecoder(c.Request().Body).Decode(&act); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error parsing activity: %v", err))
+	}
+
+	if act.Type != "Follow" || act.Actor == "" {
+		return c.JSON(http.StatusAccepted, okResp{true})
+	}
+
+	inbox, err := a.feed.resolveActorInbox(act.Actor)
+	if err != nil {
+		a.lo.Printf("error resolving follower inbox for %s: %v", act.Actor, err)
+		return c.JSON(http.StatusAccepted, okResp{true})
+	}
+
+	if _, err := a.db.Exec(`
+		INSERT INTO ap_followers (actor, inbox) VALUES ($1, $2)
+		ON CONFLICT (actor) DO UPDATE SET inbox = EXCLUDED.inbox`, act.Actor, inbox); err != nil {
+		a.lo.Printf("error recording follower %s: %v", act.Actor, err)
+	}
+
+	go a.feed.deliver(inbox, map[string]interface{}{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       a.feed.rootURL + "/ap/activities/" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		"type":     "Accept",
+		"actor":    a.feed.rootURL + "/ap/actor",
+		"object":   act,
+	})
+
+	return c.JSON(http.StatusAccepted, okResp{true})
+}
+
+// resolveActorInbox fetches a remote actor document and returns its inbox
+// URL, so Follow doesn't have to assume a URL convention other servers
+// don't follow.
+func (f *feedPublisher) resolveActorInbox(actorURL string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, actorURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/activity+json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var actor struct {
+		Inbox string `json:"inbox"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&actor); err != nil {
+		return "", fmt.Errorf("error parsing actor document: %v", err)
+	}
+	if actor.Inbox == "" {
+		return "", fmt.Errorf("actor document has no inbox")
+	}
+
+	return actor.Inbox, nil
+}
+
+// deliverToFollowers delivers act to every known follower's inbox. Each
+// delivery is attempted independently and failures are only logged, since a
+// single unreachable follower shouldn't block delivery to the rest.
+func (f *feedPublisher) deliverToFollowers(act apActivity) {
+	var followers []struct {
+		Actor string `db:"actor"`
+		Inbox string `db:"inbox"`
+	}
+	if err := f.db.Select(&followers, `SELECT actor, inbox FROM ap_followers`); err != nil {
+		lo.Printf("error loading ap_followers: %v", err)
+		return
+	}
+
+	for _, flw := range followers {
+		f.deliver(flw.Inbox, act)
+	}
+}
+
+// deliver POSTs body to inbox as a signed ActivityPub delivery: a `Digest`
+// header over the body and a `Signature` header (HTTP Signatures,
+// https://datatracker.ietf.org/doc/html/draft-cavage-http-signatures-12)
+// covering `(request-target)`, `host`, `date` and `digest`, so the receiving
+// server can verify it came from this actor's keypair.
+func (f *feedPublisher) deliver(inbox string, body interface{}) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		lo.Printf("error marshalling activity for %s: %v", inbox, err)
+		return
+	}
+
+	u, err := url.Parse(inbox)
+	if err != nil {
+		lo.Printf("error parsing inbox url %s: %v", inbox, err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, inbox, bytes.NewReader(b))
+	if err != nil {
+		lo.Printf("error building inbox request for %s: %v", inbox, err)
+		return
+	}
+	req.Header.Set(echo.HeaderContentType, "application/activity+json")
+	req.Header.Set("Host", u.Host)
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+
+	digest := sha256.Sum256(b)
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+
+	if err := f.signRequest(req); err != nil {
+		lo.Printf("error signing delivery to %s: %v", inbox, err)
+		return
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		lo.Printf("error delivering activity to %s: %v", inbox, err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		lo.Printf("inbox %s rejected delivery: %s", inbox, resp.Status)
+	}
+}
+
+// signRequest adds an HTTP Signature `Signature` header to req, signing
+// `(request-target)`, `host`, `date` and `digest` with f.key (RSA-SHA256).
+func (f *feedPublisher) signRequest(req *http.Request) error {
+	signed := []string{"(request-target)", "host", "date", "digest"}
+
+	lines := make([]string, len(signed))
+	for i, h := range signed {
+		switch h {
+		case "(request-target)":
+			lines[i] = fmt.Sprintf("(request-target): post %s", req.URL.RequestURI())
+		default:
+			lines[i] = fmt.Sprintf("%s: %s", h, req.Header.Get(http.CanonicalHeaderKey(h)))
+		}
+	}
+
+	digest := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(
+		`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		f.keyID, strings.Join(signed, " "), base64.StdEncoding.EncodeToString(sig)))
+	return nil
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"database/sql"
 	"encoding/gob"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -13,13 +15,15 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/knadh/dictpress/internal/data"
 	"github.com/knadh/koanf/v2"
 	"github.com/knadh/paginator"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 )
 
 // results represents a set of results.
@@ -28,6 +32,10 @@ type results struct {
 
 	Query data.Query `json:"query"`
 
+	// Suggestions is a "did you mean ...?" list populated when Entries is
+	// empty, see searchCached.
+	Suggestions []data.Suggestion `json:"suggestions,omitempty"`
+
 	// Pagination fields.
 	paginator.Set
 }
@@ -56,8 +64,9 @@ func initHTTPServer(a *App, ko *koanf.Koanf) *echo.Echo {
 		// Public handlers with no auth.
 		pb = srv.Group("")
 
-		// Admin handlers with auth.
-		ad = srv.Group("", middleware.BasicAuth(a.basicAuth))
+		// Admin handlers with auth. Requests may authenticate with either
+		// HTTP BasicAuth or a scoped `Authorization: Bearer <token>` API token.
+		ad = srv.Group("", a.authMiddleware(""))
 	)
 
 	// Dictionary site HTML views.
@@ -98,11 +107,21 @@ func initHTTPServer(a *App, ko *koanf.Koanf) *echo.Echo {
 	pb.GET("/api/config", a.HandleGetConfig)
 	pb.GET("/api/dictionary/:fromLang/:toLang/:q", a.HandleSearch)
 	pb.GET("/api/dictionary/entries/:guid", a.HandleGetEntryPublic)
+	pb.GET("/api/events", a.HandleEntryEvents)
+
+	// WebSub Atom feeds and the ActivityPub actor/outbox/inbox. Routes are
+	// always registered; the handlers themselves 404 when federation.enabled
+	// is false.
+	pb.GET("/feed/:fromLang/:toLangExt", a.HandleFeed)
+	pb.GET("/ap/actor", a.HandleActor)
+	pb.GET("/ap/outbox", a.HandleOutbox)
+	pb.POST("/ap/inbox", a.HandleInbox)
 
 	// Public user submission APIs.
 	if ko.Bool("app.enable_submissions") {
 		pb.POST("/api/submissions", a.HandleNewSubmission)
 		pb.POST("/api/submissions/comments", a.HandleNewComments)
+		pb.POST("/api/v1/submissions", a.HandleNewSubmissionV1)
 
 		if a.consts.Site != "" {
 			pb.GET("/submit", a.HandleSubmissionPage)
@@ -118,6 +137,8 @@ func initHTTPServer(a *App, ko *koanf.Koanf) *echo.Echo {
 	ad.GET("/admin/search", a.adminPage("search"))
 	ad.GET("/admin/pending", a.adminPage("pending"))
 
+	ad.GET("/api/admin/events", a.HandleAdminEvents)
+
 	ad.GET("/api/stats", a.HandleGetStats)
 	ad.GET("/api/entries/pending", a.HandleGetPendingEntries)
 	ad.GET("/api/entries/comments", a.HandleGetComments)
@@ -125,15 +146,48 @@ func initHTTPServer(a *App, ko *koanf.Koanf) *echo.Echo {
 	ad.DELETE("/api/entries/pending", a.HandleDeletePending)
 	ad.GET("/api/entries/:id", a.HandleGetEntry)
 	ad.GET("/api/entries/:id/parents", a.HandleGetParentEntries)
-	ad.POST("/api/entries", a.HandleInsertEntry)
-	ad.PUT("/api/entries/:id", a.HandleUpdateEntry)
-	ad.DELETE("/api/entries/:id", a.HandleDeleteEntry)
-	ad.DELETE("/api/entries/:fromID/relations/:relID", a.HandleDeleteRelation)
-	ad.POST("/api/entries/:fromID/relations/:toID", a.HandleAddRelation)
-	ad.PUT("/api/entries/:id/relations/weights", a.HandleReorderRelations)
-	ad.PUT("/api/entries/:id/relations/:relID", a.HandleUpdateRelation)
-	ad.PUT("/api/entries/:id/submission", a.HandleApproveSubmission)
-	ad.DELETE("/api/entries/:id/submission", a.HandleRejectSubmission)
+	ad.POST("/api/entries", a.HandleInsertEntry, a.authMiddleware("entries:write"))
+	ad.PUT("/api/entries/:id", a.HandleUpdateEntry, a.authMiddleware("entries:write"))
+	ad.DELETE("/api/entries/:id", a.HandleDeleteEntry, a.authMiddleware("entries:write"))
+	ad.DELETE("/api/entries/:fromID/relations/:relID", a.HandleDeleteRelation, a.authMiddleware("relations:write"))
+	ad.POST("/api/entries/:fromID/relations/:toID", a.HandleAddRelation, a.authMiddleware("relations:write"))
+	ad.PUT("/api/entries/:id/relations/weights", a.HandleReorderRelations, a.authMiddleware("relations:write"))
+	ad.PUT("/api/entries/:id/relations/:relID", a.HandleUpdateRelation, a.authMiddleware("relations:write"))
+	ad.PUT("/api/entries/:id/submission", a.HandleApproveSubmission, a.authMiddleware("moderation:approve"))
+	ad.DELETE("/api/entries/:id/submission", a.HandleRejectSubmission, a.authMiddleware("moderation:approve"))
+
+	// Bulk import/export.
+	ad.POST("/api/entries/bulk", a.HandleBulkImportEntries, a.authMiddleware("entries:write"))
+	ad.DELETE("/api/entries/bulk", a.HandleBulkDeleteEntries, a.authMiddleware("entries:write"))
+	ad.GET("/api/entries/bulk", a.HandleBulkExportEntries)
+	ad.GET("/api/entries/export", a.HandleBulkExportEntries)
+
+	// Streaming bulk import with SSE progress, resumable across restarts.
+	ad.POST("/api/entries/import", a.HandleEnqueueImport, a.authMiddleware("entries:write"))
+	ad.POST("/api/entries/import/:jobID/resume", a.HandleResumeImport, a.authMiddleware("entries:write"))
+	ad.GET("/api/entries/import/:jobID/events", a.HandleImportEvents)
+
+	// TEI Lex-0 / Ontolex-Lemon JSON-LD dictionary import/export.
+	ad.GET("/api/dicts/:fromLang/:toLang/export", a.HandleExportDict)
+	ad.POST("/api/dicts/:fromLang/:toLang/import", a.HandleImportDict, a.authMiddleware("entries:write"))
+
+	// Background jobs.
+	ad.POST("/api/jobs/:kind", a.HandleEnqueueJob, a.authMiddleware("entries:write"))
+	ad.GET("/api/jobs", a.HandleListJobs)
+	ad.GET("/api/jobs/:id", a.HandleGetJob)
+	ad.DELETE("/api/jobs/:id", a.HandleCancelJob, a.authMiddleware("entries:write"))
+
+	// Audit log.
+	ad.GET("/api/audit", a.HandleGetAuditLog)
+	ad.POST("/api/audit/:id/revert", a.HandleRevertAudit, a.authMiddleware("entries:write"))
+
+	// Cache management.
+	ad.POST("/api/admin/cache/purge", a.HandlePurgeCache)
+
+	// API token management.
+	ad.GET("/api/tokens", a.HandleGetAPITokens)
+	ad.POST("/api/tokens", a.HandleCreateAPIToken)
+	ad.DELETE("/api/tokens/:id", a.HandleRevokeAPIToken)
 
 	// 404 pages.
 	srv.RouteNotFound("/api/*", func(c echo.Context) error {
@@ -149,6 +203,11 @@ func initHTTPServer(a *App, ko *koanf.Koanf) *echo.Echo {
 	return srv
 }
 
+// statusClientClosedRequest is nginx's de facto status for "the client went
+// away before the response was ready", used when a search is abandoned via
+// its context before Postgres returns.
+const statusClientClosedRequest = 499
+
 // HandleSearch performs a search and responds with JSON results.
 func (a *App) HandleSearch(c echo.Context) error {
 	isAuthed := c.Get(isAuthed) != nil
@@ -159,12 +218,34 @@ func (a *App) HandleSearch(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	out, err := a.doSearch(query, isAuthed, a.pgAPI)
+	// Bound the whole search (including relation loading) by
+	// search.query_timeout_sec so a slow scan doesn't hold a connection open
+	// indefinitely, and so it's cancelled the moment the client disconnects.
+	ctx, cancel := context.WithTimeout(c.Request().Context(), a.consts.SearchQueryTimeout)
+	defer cancel()
+
+	out, hit, err := a.doSearch(ctx, query, isAuthed, a.resultsPg)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		switch {
+		case errors.Is(err, context.Canceled):
+			return echo.NewHTTPError(statusClientClosedRequest, "client closed the request")
+		case errors.Is(err, context.DeadlineExceeded):
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "search timed out")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	cacheable := a.cache != nil && !isAuthed
+	if cacheable {
+		if hit {
+			c.Response().Header().Set("X-Cache", "HIT")
+		} else {
+			c.Response().Header().Set("X-Cache", "MISS")
+		}
 	}
 
-	return c.JSON(http.StatusOK, okResp{out})
+	return a.writeJSONCached(c, cacheable, okResp{out})
 }
 
 // HandleGetEntryPublic returns an entry by its guid.
@@ -183,7 +264,7 @@ func (a *App) HandleGetEntryPublic(c echo.Context) error {
 	e.Relations = make([]data.Entry, 0)
 
 	out := []data.Entry{e}
-	if err := a.data.SearchAndLoadRelations(out, data.Query{}); err != nil {
+	if err := a.data.SearchAndLoadRelations(c.Request().Context(), out, data.Query{}); err != nil {
 		a.lo.Printf("error loading relations: %v", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "error loading relations")
 	}
@@ -197,7 +278,7 @@ func (a *App) HandleGetEntryPublic(c echo.Context) error {
 		}
 	}
 
-	return c.JSON(http.StatusOK, okResp{out[0]})
+	return a.writeJSONCached(c, a.cache != nil, okResp{out[0]})
 }
 
 // handleServeBundle serves concatenated JS or CSS files based on query parameters
@@ -307,6 +388,22 @@ func (a *App) prepareQuery(c echo.Context) (data.Query, error) {
 		}
 	}
 
+	// ?fuzzy=1&sim=0.3 turns on the pg_trgm fuzzy fallback for this request.
+	if v := c.QueryParam("fuzzy"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return data.Query{}, fmt.Errorf("invalid `fuzzy` param: %v", err)
+		}
+		q.Fuzzy = b
+	}
+	if v := c.QueryParam("sim"); v != "" {
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return data.Query{}, fmt.Errorf("invalid `sim` param: %v", err)
+		}
+		q.MinSimilarity = float32(f)
+	}
+
 	// Final query.
 	q.Query = qStr
 	q.FromLang = fromLang
@@ -323,8 +420,28 @@ func (a *App) prepareQuery(c echo.Context) (data.Query, error) {
 	return q, nil
 }
 
-// doSearch takes a prepared query and performs the search, returning results.
-func (a *App) doSearch(q data.Query, isAuthed bool, pgn *paginator.Paginator) (*results, error) {
+// doSearch takes a prepared query and performs the search, returning results
+// and whether they were served from the results cache.
+func (a *App) doSearch(ctx context.Context, q data.Query, isAuthed bool, pgn *paginator.Paginator) (*results, bool, error) {
+	out, hit, err := a.searchCached(ctx, q, isAuthed, pgn)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// On a fresh, cacheable miss, warm the cache with the neighbouring pages
+	// in the background so that paging forward/back from here is instant.
+	if a.cache != nil && !isAuthed && !hit {
+		a.prefetchAdjacentPages(q, out.TotalPages, pgn)
+	}
+
+	return out, hit, nil
+}
+
+// searchCached performs the actual search, serving from and populating
+// a.cache for public, unauthenticated queries. On a cache miss it also fans
+// out q to any registered external searchProviders, merging their entries
+// into the result before it's cached.
+func (a *App) searchCached(ctx context.Context, q data.Query, isAuthed bool, pgn *paginator.Paginator) (*results, bool, error) {
 	// Pagination.
 	pg := pgn.New(q.Page, q.PerPage)
 	q.Offset = pg.Offset
@@ -336,63 +453,198 @@ func (a *App) doSearch(q data.Query, isAuthed bool, pgn *paginator.Paginator) (*
 	// Is result caching enabled (for public, unauthenticated requests)?
 	cacheKey := ""
 	if a.cache != nil && !isAuthed {
-		cacheKey = makeQueryCacheKey(q)
+		cacheKey = makeQueryCacheKey(q, a.consts.SearchBackend)
+		a.logSearchQuery(q)
+
 		if cached, _ := a.cache.Get(cacheKey); cached != nil {
 			var out results
 			if gobDecode(cached, &out) == nil {
-				return &out, nil
+				return &out, true, nil
 			}
 		}
 	}
 
-	// Search and compose results.
-	res, total, err := a.data.Search(q)
+	// Search and compose results, through the configured search.backend
+	// (Postgres by default, or an external index) rather than a.data.Search
+	// directly, so that search.backend actually governs querying and not
+	// just Index/Delete on writes.
+	res, total, err := a.search.Search(ctx, q)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
 		a.lo.Printf("error querying db: %v", err)
-		return nil, errors.New("error querying db")
+		return nil, false, errors.New("error querying db")
 	}
 
 	if len(res) == 0 {
 		out.Query = q
+	} else {
+		// Load relations under their own, shorter deadline so a slow
+		// relations join can't eat the whole of the parent search budget.
+		relCtx := ctx
+		if a.consts.SearchRelationsTimeout > 0 {
+			var relCancel context.CancelFunc
+			relCtx, relCancel = context.WithTimeout(ctx, a.consts.SearchRelationsTimeout)
+			defer relCancel()
+		}
 
-		return out, nil
-	}
-
-	// Load relations into the matches.
-	if err := a.data.SearchAndLoadRelations(res, q); err != nil {
-		return nil, errors.New("error querying db for definitions")
-	}
+		if err := a.data.SearchAndLoadRelations(relCtx, res, q); err != nil {
+			if relCtx.Err() != nil {
+				return nil, false, relCtx.Err()
+			}
+			return nil, false, errors.New("error querying db for definitions")
+		}
 
-	// If this is an un-authenticated query, hide the numerical IDs.
-	if !isAuthed {
-		for i := range res {
-			res[i].ID = 0
+		// If this is an un-authenticated query, hide the numerical IDs.
+		if !isAuthed {
+			for i := range res {
+				res[i].ID = 0
 
-			for j := range res[i].Relations {
-				res[i].Relations[j].ID = 0
-				res[i].Relations[j].Relation.ID = 0
+				for j := range res[i].Relations {
+					res[i].Relations[j].ID = 0
+					res[i].Relations[j].Relation.ID = 0
+				}
 			}
 		}
+
+		// Calculate pagination.
+		pg.SetTotal(total)
+
+		out.Query = q
+		out.Entries = res
+		out.Page = pg.Page
+		out.PerPage = pg.PerPage
+		out.TotalPages = pg.TotalPages
+		out.Total = total
 	}
 
-	// Calculate pagination.
-	pg.SetTotal(total)
+	// Fan out to any registered external search providers and merge their
+	// entries in, tagged with their Source.
+	a.federateSearch(ctx, q, out)
 
-	out.Query = q
-	out.Entries = res
-	out.Page = pg.Page
-	out.PerPage = pg.PerPage
-	out.TotalPages = pg.TotalPages
-	out.Total = total
+	// Still nothing after federation: surface a "did you mean ...?" list
+	// instead of a bare empty result page.
+	if len(out.Entries) == 0 {
+		sugg, err := a.data.Suggest(ctx, q)
+		if err != nil {
+			a.lo.Printf("error fetching suggestions: %v", err)
+		} else {
+			out.Suggestions = sugg
+		}
+	}
 
-	// Cache public results.
-	if a.cache != nil && !isAuthed {
+	// Cache public results, unless the request was abandoned or timed out
+	// mid-flight -- out may only hold a partial page in that case, and
+	// memoizing it would serve that partial page to the next caller too.
+	if a.cache != nil && !isAuthed && ctx.Err() == nil {
 		if b, err := gobEncode(out); err == nil {
 			a.cache.Put(cacheKey, b, nil)
 		}
 	}
 
-	return out, nil
+	return out, false, nil
+}
+
+// prefetchAdjacentPages warms the results cache for the page before and
+// after q's current page in the background, so that paging forward/back
+// from a fresh search is served from cache instead of hitting Postgres
+// again. totalPages, when known, bounds the forward prefetch.
+func (a *App) prefetchAdjacentPages(q data.Query, totalPages int, pgn *paginator.Paginator) {
+	for _, page := range []int{q.Page - 1, q.Page + 1} {
+		if page < 1 || (totalPages > 0 && page > totalPages) {
+			continue
+		}
+
+		pq := q
+		pq.Page = page
+
+		go func(pq data.Query) {
+			if _, _, err := a.searchCached(context.Background(), pq, false, pgn); err != nil {
+				a.lo.Printf("error prefetching page %d: %v", pq.Page, err)
+			}
+		}(pq)
+	}
+}
+
+// federateSearch fans q out to every registered external search provider
+// concurrently, merging their entries into out de-duped (by normalized
+// content+lang) against the local results and each other, ordered by
+// provider weight. Only runs for the first page, since external providers
+// don't support dictpress's offset-based pagination.
+func (a *App) federateSearch(ctx context.Context, q data.Query, out *results) {
+	if len(a.providers) == 0 || q.Page > 1 {
+		return
+	}
+
+	// Bound how long a slow upstream can hold up the response.
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+	}
+
+	type providerHits struct {
+		p       searchProvider
+		entries []data.Entry
+	}
+
+	hitsCh := make(chan providerHits, len(a.providers))
+
+	var wg sync.WaitGroup
+	for _, p := range a.providers {
+		wg.Add(1)
+		go func(p searchProvider) {
+			defer wg.Done()
+
+			entries, err := p.Find(ctx, q)
+			if err != nil {
+				a.lo.Printf("error querying search provider '%s': %v", p.Name(), err)
+				return
+			}
+			hitsCh <- providerHits{p, entries}
+		}(p)
+	}
+
+	go func() {
+		wg.Wait()
+		close(hitsCh)
+	}()
+
+	var all []providerHits
+	for h := range hitsCh {
+		all = append(all, h)
+	}
+
+	// Higher-weighted providers' entries are interleaved earlier.
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].p.Weight() > all[j].p.Weight()
+	})
+
+	seen := make(map[string]bool)
+	for _, e := range out.Entries {
+		seen[normalizeEntryKey(e.Content, e.Lang)] = true
+	}
+
+	for _, h := range all {
+		for _, e := range h.entries {
+			key := normalizeEntryKey(e.Content, e.Lang)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			e.Source = h.p.Name()
+			out.Entries = append(out.Entries, e)
+		}
+	}
+}
+
+// normalizeEntryKey returns a de-duplication key for an entry's content and
+// language, used to drop federated results that duplicate a local or
+// another provider's hit.
+func normalizeEntryKey(content, lang string) string {
+	return lang + ":" + strings.ToLower(strings.TrimSpace(content))
 }
 
 // getGlossaryWords is a helper function that takes an HTTP query context,
@@ -445,7 +697,11 @@ func (a *App) getGlossaryWords(lang, initial string, pg paginator.Set) (glossary
 
 // makeQueryCacheKey creates a deterministic cache key from a Query.
 // Normalizes and sorts fields in the query to generate consistent keys.
-func makeQueryCacheKey(q data.Query) string {
+// backend is the configured search.backend name (consts.SearchBackend) so
+// that switching backends -- which can yield different results for the
+// same Query -- starts from a cold cache instead of serving stale entries
+// cached under the previous backend.
+func makeQueryCacheKey(q data.Query, backend string) string {
 	// Sort slices for deterministic ordering.
 	types := make([]string, len(q.Types))
 	copy(types, q.Types)
@@ -456,7 +712,8 @@ func makeQueryCacheKey(q data.Query) string {
 	sort.Strings(tags)
 
 	// Build key string with all the fields.
-	key := fmt.Sprintf("s:%s:%s:%s:%s:%s:%s:%d:%d",
+	key := fmt.Sprintf("s:%s:%s:%s:%s:%s:%s:%s:%d:%d",
+		backend,
 		q.FromLang,
 		q.ToLang,
 		strings.ToLower(strings.TrimSpace(q.Query)),
@@ -491,3 +748,37 @@ func gobEncode(v any) ([]byte, error) {
 func gobDecode(data []byte, v any) error {
 	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
 }
+
+// etagForBytes returns a strong, quoted ETag for a response body so it can
+// be compared against a client's If-None-Match.
+func etagForBytes(b []byte) string {
+	h := md5.Sum(b)
+	return `"` + hex.EncodeToString(h[:]) + `"`
+}
+
+// writeJSONCached marshals v to JSON and writes it as the response. When
+// cacheable is true it also sets Cache-Control/Vary and a strong ETag
+// derived from the serialized bytes, answering a matching If-None-Match
+// with a 304 instead of re-serializing and resending a payload the client
+// (or a CDN in front of it) already has.
+func (a *App) writeJSONCached(c echo.Context, cacheable bool, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if !cacheable {
+		return c.JSONBlob(http.StatusOK, b)
+	}
+
+	etag := etagForBytes(b)
+	c.Response().Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(a.consts.CacheTTL.Seconds())))
+	c.Response().Header().Set("Vary", "Accept-Encoding")
+	c.Response().Header().Set("ETag", etag)
+
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.JSONBlob(http.StatusOK, b)
+}
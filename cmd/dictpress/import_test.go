@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestEstimateImportRowCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		body   string
+		want   int
+	}{
+		{"ndjson", "json", "{\"a\":1}\n{\"a\":2}\n{\"a\":3}\n", 3},
+		{"json array", "json", "[{\"a\":1},{\"a\":2}]", 0},
+		{"csv", "csv", "a,b,c\nd,e,f\n", 2},
+		{"empty", "csv", "", 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := estimateImportRowCount(tc.format, []byte(tc.body))
+			if got != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteImportProgressEvent(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest("GET", "/", nil), rec)
+	w := c.Response()
+	w.WriteHeader(200)
+
+	now := time.Now()
+	last := importProgress{Processed: 0, Total: 100, Errors: 0, At: now}
+	cur := importProgress{Processed: 10, Total: 100, Errors: 1, At: now.Add(time.Second)}
+
+	if err := writeImportProgressEvent(w, cur, last); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{`"processed":10`, `"rate":10`, `"eta":9`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected body to contain %q, got %q", want, body)
+		}
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"html/template"
 	"os"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/jmoiron/sqlx"
+	"github.com/knadh/dictpress/internal/cache"
 	"github.com/knadh/dictpress/internal/data"
+	"github.com/knadh/dictpress/internal/searchindex"
+	"github.com/knadh/dictpress/internal/tokenizer"
 	"github.com/knadh/dictpress/tokenizers/indicphone"
 	"github.com/knadh/goyesql"
 	goyesqlx "github.com/knadh/goyesql/sqlx"
@@ -28,6 +32,93 @@ func initConstants(ko *koanf.Koanf) Consts {
 
 		SiteMaxEntryRelationsPerType: ko.MustInt("site_results.max_entry_relations_per_type"),
 		SiteMaxEntryContentItems:     ko.MustInt("site_results.max_entry_content_items"),
+
+		Dev:             ko.Bool("app.dev"),
+		Watch:           ko.Bool("app.watch"),
+		ShutdownTimeout: time.Duration(ko.Int("app.shutdown_timeout_sec")) * time.Second,
+
+		ModerationRateLimitPerMin:      ko.Float64("moderation.rate_limit_per_min"),
+		ModerationRateLimitBurst:       ko.Int("moderation.rate_limit_burst"),
+		ModerationSpamAutoRejectScore:  ko.Float64("moderation.spam_auto_reject_score"),
+		ModerationSpamAutoApproveScore: ko.Float64("moderation.spam_auto_approve_score"),
+		ModerationTrustedIPs:           ko.Strings("moderation.trusted_ips"),
+		ModerationAkismetKey:           ko.String("moderation.akismet_key"),
+		ModerationAkismetSite:          ko.String("moderation.akismet_site"),
+
+		ModerationCaptchaProvider: ko.String("moderation.captcha_provider"),
+		ModerationCaptchaSecret:   ko.String("moderation.captcha_secret"),
+		ModerationHoneypotField:   ko.String("moderation.honeypot_field"),
+		ModerationBlocklist:       ko.Strings("moderation.blocklist"),
+
+		CacheEnabled:   ko.Bool("cache.enabled"),
+		CacheMode:      ko.String("cache.mode"),
+		CacheTTL:       time.Duration(ko.Int("cache.ttl_sec")) * time.Second,
+		CacheMaxMemory: int64(ko.Int("cache.max_memory_mb")),
+		CacheDir:       ko.String("cache.dir"),
+
+		CacheRedisAddr:     ko.String("cache.redis_addr"),
+		CacheRedisPassword: ko.String("cache.redis_password"),
+		CacheRedisDB:       ko.Int("cache.redis_db"),
+		CacheRedisChannel:  ko.String("cache.redis_channel"),
+		CacheWarmupTopN:    ko.Int("cache.warmup_top_n"),
+
+		JobsConcurrency: ko.Int("jobs.concurrency"),
+
+		AuditRetentionDays: ko.Int("audit.retention_days"),
+
+		FederationEnabled:    ko.Bool("federation.enabled"),
+		FederationWebSubHub:  ko.String("federation.websub_hub"),
+		FederationActorName:  ko.String("federation.actor_name"),
+		FederationPrivateKey: ko.String("federation.private_key"),
+
+		SearchBackend:         ko.String("search.backend"),
+		SearchOpenSearchURL:   ko.String("search.opensearch_url"),
+		SearchOpenSearchIndex: ko.String("search.opensearch_index"),
+
+		SearchQueryTimeout:     time.Duration(ko.Int("search.query_timeout_sec")) * time.Second,
+		SearchRelationsTimeout: time.Duration(ko.Int("search.relations_timeout_sec")) * time.Second,
+	}
+
+	// Sane defaults so moderation is usable out of the box even without
+	// these keys in the config file.
+	if c.ModerationRateLimitPerMin <= 0 {
+		c.ModerationRateLimitPerMin = 10
+	}
+	if c.ModerationRateLimitBurst <= 0 {
+		c.ModerationRateLimitBurst = 5
+	}
+	if c.ModerationSpamAutoRejectScore <= 0 {
+		c.ModerationSpamAutoRejectScore = 0.8
+	}
+	if c.ModerationSpamAutoApproveScore <= 0 {
+		c.ModerationSpamAutoApproveScore = 0.2
+	}
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = 10 * time.Second
+	}
+	if c.ModerationHoneypotField == "" {
+		c.ModerationHoneypotField = "url"
+	}
+	if c.CacheMode == "" {
+		c.CacheMode = cache.CacheTypeMemory
+	}
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = 5 * time.Minute
+	}
+	if c.CacheWarmupTopN <= 0 {
+		c.CacheWarmupTopN = 20
+	}
+	if c.JobsConcurrency <= 0 {
+		c.JobsConcurrency = 2
+	}
+	if c.SearchBackend == "" {
+		c.SearchBackend = "postgres"
+	}
+	if c.SearchOpenSearchIndex == "" {
+		c.SearchOpenSearchIndex = "dictpress_entries"
+	}
+	if c.SearchQueryTimeout <= 0 {
+		c.SearchQueryTimeout = 10 * time.Second
 	}
 
 	if len(c.AdminUsername) < 6 {
@@ -112,8 +203,7 @@ func initQueries(fs stuffbin.FileSystem, db *sqlx.DB) *data.Queries {
 }
 
 func initAdminTemplates(fs stuffbin.FileSystem) *template.Template {
-	// Init admin templates.
-	tpls, err := stuffbin.ParseTemplatesGlob(sprig.FuncMap(), fs, "/admin/*.html")
+	tpls, err := loadAdminTemplates(fs)
 	if err != nil {
 		lo.Fatalf("error parsing admin templates: %v", err)
 	}
@@ -121,15 +211,60 @@ func initAdminTemplates(fs stuffbin.FileSystem) *template.Template {
 	return tpls
 }
 
-// initTokenizers initializes all bundled tokenizers.
+// loadAdminTemplates parses the admin UI templates, returning an error
+// instead of exiting so a.startWatcher() can log a bad edit and keep serving
+// the previously loaded templates.
+func loadAdminTemplates(fs stuffbin.FileSystem) (*template.Template, error) {
+	return stuffbin.ParseTemplatesGlob(sprig.FuncMap(), fs, "/admin/*.html")
+}
+
+// initTokenizers initializes the bundled tokenizers and any external
+// tokenizers configured under [tokenizer.<name>] as Go plugins, out-of-
+// process RPC/gRPC services, or sandboxed wasm modules (tokenizer.<name>.type
+// = "plugin" | "rpc" | "grpc" | "wasm"), so that a new language/script
+// doesn't require forking and rebuilding dictpress.
 func initTokenizers(ko *koanf.Koanf) map[string]data.Tokenizer {
-	cfg := indicphone.Config{
-		NumKNKeys: ko.Int("tokenizer.indicphone.kn.num_keys"),
-		NumMLKeys: ko.Int("tokenizer.indicphone.ml.num_keys"),
+	numKeys := make(map[string]int)
+	for _, lang := range ko.MapKeys("tokenizer.indicphone") {
+		numKeys[lang] = ko.Int("tokenizer.indicphone." + lang + ".num_keys")
 	}
-	return map[string]data.Tokenizer{
+
+	cfg := indicphone.Config{NumKeys: numKeys}
+	out := map[string]data.Tokenizer{
 		"indicphone": indicphone.New(cfg),
 	}
+
+	for _, name := range ko.MapKeys("tokenizer") {
+		if name == "indicphone" {
+			continue
+		}
+
+		typ := ko.String("tokenizer." + name + ".type")
+		if typ == "" {
+			continue
+		}
+
+		tk, err := tokenizer.Load(name, tokenizer.Config{
+			Type:     typ,
+			Path:     ko.String("tokenizer." + name + ".path"),
+			Options:  ko.Cut("tokenizer." + name + ".config").All(),
+			Address:  ko.String("tokenizer." + name + ".address"),
+			Command:  ko.String("tokenizer." + name + ".command"),
+			Args:     ko.Strings("tokenizer." + name + ".args"),
+			Timeout:  ko.Duration("tokenizer." + name + ".timeout"),
+			URL:      ko.String("tokenizer." + name + ".url"),
+			Checksum: ko.String("tokenizer." + name + ".checksum"),
+			CacheDir: ko.String("tokenizer." + name + ".cache_dir"),
+		})
+		if err != nil {
+			lo.Fatalf("error loading tokenizer '%s': %v", name, err)
+		}
+
+		lo.Printf("loaded tokenizer: %s", tokenizer.Identify(name, tk))
+		out[name] = tk
+	}
+
+	return out
 }
 
 // initLangs loads language configuration into a given *App instance.
@@ -141,21 +276,37 @@ func initLangs(ko *koanf.Koanf) data.LangMap {
 
 	// Language configuration.
 	for _, l := range ko.MapKeys("lang") {
-		lang := data.Lang{ID: l, Types: make(map[string]string)}
+		lang := data.Lang{Types: make(map[string]string)}
 		if err := ko.UnmarshalWithConf("lang."+l, &lang, koanf.UnmarshalConf{Tag: "json"}); err != nil {
 			lo.Fatalf("error loading languages: %v", err)
 		}
 
-		// Does the language use a bundled tokenizer?
-		if lang.TokenizerType == "custom" {
+		// Look the language's tokenizer up by name in the merged registry of
+		// bundled and externally configured (plugin/RPC) tokenizers.
+		if lang.TokenizerName != "" {
 			t, ok := tks[lang.TokenizerName]
 			if !ok {
-				lo.Fatalf("unknown custom tokenizer '%s'", lang.TokenizerName)
+				lo.Fatalf("unknown tokenizer '%s' for language '%s'", lang.TokenizerName, l)
 			}
 			lang.Tokenizer = t
 		}
 
-		// Load external plugin.
+		// A language can instead (or additionally) configure a pipeline of
+		// several tokenizers, each tagged with the tsvector weight its
+		// tokens are merged in with. When set, this takes precedence over
+		// the single TokenizerName/Tokenizer above in Search/insertEntry.
+		for _, tc := range lang.TokenizerConfigs {
+			t, ok := tks[tc.Name]
+			if !ok {
+				lo.Fatalf("unknown tokenizer '%s' for language '%s'", tc.Name, l)
+			}
+			w := tc.Weight
+			if w <= 0 {
+				w = 1
+			}
+			lang.Tokenizers = append(lang.Tokenizers, data.ResolvedTokenizer{Tokenizer: t, Weight: w})
+		}
+
 		lo.Printf("language: %s", l)
 		out[l] = lang
 	}
@@ -167,6 +318,52 @@ func initLangs(ko *koanf.Koanf) data.LangMap {
 	return out
 }
 
+// initCache constructs the configured results-cache backend: "memory" (the
+// default) or "hybrid" use the embedded Badger cache.Cache, local to this
+// instance; "redis" uses cache.RedisCache, a shared store with pub/sub
+// invalidation for deployments running more than one dictpress instance.
+func initCache(consts Consts) (cache.Store, error) {
+	if consts.CacheMode == cache.CacheTypeRedis {
+		return cache.NewRedis(cache.RedisConfig{
+			Addr:     consts.CacheRedisAddr,
+			Password: consts.CacheRedisPassword,
+			DB:       consts.CacheRedisDB,
+			TTL:      consts.CacheTTL,
+			Channel:  consts.CacheRedisChannel,
+		}, lo)
+	}
+
+	return cache.New(cache.Config{
+		TTL:       consts.CacheTTL,
+		Mode:      consts.CacheMode,
+		CacheDir:  consts.CacheDir,
+		MaxMemory: consts.CacheMaxMemory,
+	}, lo)
+}
+
+// initSearchBackend constructs the Backend named by consts.SearchBackend.
+// "postgres" (the default) wraps dt's existing tsvector/pg_trgm search;
+// "postgres-websearch" wraps the same search but parses the query with
+// Postgres' websearch_to_tsquery() syntax instead; "opensearch" queries an
+// external OpenSearch/Elasticsearch cluster at consts.SearchOpenSearchURL,
+// creating consts.SearchOpenSearchIndex on first use if it doesn't already
+// exist.
+func initSearchBackend(consts Consts, dt *data.Data, db *sqlx.DB) (searchindex.Backend, error) {
+	switch consts.SearchBackend {
+	case "", "postgres":
+		return searchindex.NewPostgres(dt), nil
+	case "postgres-websearch":
+		return searchindex.NewPostgresWebsearch(dt), nil
+	case "opensearch":
+		return searchindex.New(searchindex.Config{
+			URL:   consts.SearchOpenSearchURL,
+			Index: consts.SearchOpenSearchIndex,
+		}, db, lo)
+	default:
+		return nil, fmt.Errorf("unknown search.backend '%s' (expected postgres, postgres-websearch or opensearch)", consts.SearchBackend)
+	}
+}
+
 // initDicts loads language->language dictionary map.
 func initDicts(langs data.LangMap, ko *koanf.Koanf) data.Dicts {
 	var (
@@ -1,16 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/knadh/dictpress/internal/migrations"
 	"github.com/knadh/koanf/v2"
 	"github.com/knadh/stuffbin"
 )
 
-func installSchema(ver string, prompt bool, fs stuffbin.FileSystem, db *sqlx.DB, ko *koanf.Koanf) {
+func installSchema(prompt bool, fs stuffbin.FileSystem, db *sqlx.DB, ko *koanf.Koanf) {
 	if prompt {
 		fmt.Println("")
 		fmt.Println("** first time installation **")
@@ -43,19 +45,38 @@ func installSchema(ver string, prompt bool, fs stuffbin.FileSystem, db *sqlx.DB,
 		return
 	}
 
-	// Insert the current migration version.
-	if err := recordMigrationVersion(ver, db); err != nil {
+	ctx := context.Background()
+	if err := ensureMigrationsTable(ctx, db); err != nil {
 		lo.Fatal(err)
 	}
 
+	// A fresh install is always created at the latest schema, so every
+	// registered migration is recorded as already applied.
+	for _, m := range migrations.All {
+		if err := recordMigrationVersion(ctx, m.Version, db); err != nil {
+			lo.Fatal(err)
+		}
+	}
+
 	lo.Println("successfully installed schema")
 }
 
-// recordMigrationVersion inserts the given version (of DB migration) into the
-// `migrations` array in the settings table.
-func recordMigrationVersion(ver string, db *sqlx.DB) error {
-	_, err := db.Exec(fmt.Sprintf(`INSERT INTO settings (key, value)
-	VALUES('migrations', '["%s"]'::JSONB)
-	ON CONFLICT (key) DO UPDATE SET value = settings.value || EXCLUDED.value`, ver))
+// recordMigrationVersion marks ver as cleanly applied in schema_migrations:
+// applied_at is bumped to now and dirty is cleared, inserting the row if a
+// prior markMigrationDirty call (or a fresh install) hasn't already.
+func recordMigrationVersion(ctx context.Context, ver string, db migrationsConn) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at, dirty)
+	VALUES ($1, NOW(), FALSE)
+	ON CONFLICT (version) DO UPDATE SET applied_at = NOW(), dirty = FALSE`, ver)
+	return err
+}
+
+// markMigrationDirty records that ver is about to run, before its
+// transaction starts, so a crash mid-migration leaves a durable trace in
+// schema_migrations instead of looking like the migration never began.
+func markMigrationDirty(ctx context.Context, ver string, db migrationsConn) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at, dirty)
+	VALUES ($1, NOW(), TRUE)
+	ON CONFLICT (version) DO UPDATE SET dirty = TRUE`, ver)
 	return err
 }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/dictpress/internal/jobs"
+	"github.com/labstack/echo/v4"
+)
+
+// jobKindBulkImport is the jobs.Runner kind for an asynchronous bulk import,
+// the background counterpart to the synchronous HandleBulkImportEntries.
+const jobKindBulkImport = "bulk_import"
+
+// bulkImportJobInput is the Enqueue() input for a jobKindBulkImport job: the
+// request body, buffered in full up-front (unlike the streaming synchronous
+// endpoint) so the import can keep running after the HTTP request returns.
+type bulkImportJobInput struct {
+	Format string `json:"format"`
+	Body   []byte `json:"body"`
+}
+
+// bulkImportJobResult is the Enqueue() result for a jobKindBulkImport job.
+type bulkImportJobResult struct {
+	Results []bulkImportResult `json:"results"`
+}
+
+// registerJobHandlers registers every jobs.Handler this app supports with
+// a.jobs. It's called once at boot, after a.jobs is initialized.
+func (a *App) registerJobHandlers() {
+	a.jobs.Register(jobKindBulkImport, a.runBulkImportJob)
+}
+
+// runBulkImportJob is the jobs.Handler for jobKindBulkImport. It reuses
+// bulkRowSource and bulkInsertEntries, the same row-parsing and insertion
+// code the synchronous /api/entries/bulk endpoint uses, so the two stay in
+// sync as the import format evolves.
+func (a *App) runBulkImportJob(ctx context.Context, input json.RawMessage, progress jobs.Progress) (json.RawMessage, error) {
+	var in bulkImportJobInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return nil, fmt.Errorf("error parsing job input: %v", err)
+	}
+
+	var (
+		src     = newBulkRowSource(bytes.NewReader(in.Body), in.Format)
+		results []bulkImportResult
+		line    = 0
+	)
+	for {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		batch := make([]bulkImportRow, 0, bulkImportBatchSize)
+		lines := make([]int, 0, bulkImportBatchSize)
+
+		done := false
+		for len(batch) < bulkImportBatchSize {
+			row, err := src.next()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			line++
+
+			if err != nil {
+				results = append(results, bulkImportResult{Line: line, Error: err.Error()})
+				continue
+			}
+
+			batch = append(batch, row)
+			lines = append(lines, line)
+		}
+
+		if len(batch) > 0 {
+			res, err := a.bulkInsertEntries(batch, lines)
+			if err != nil {
+				for _, ln := range lines {
+					results = append(results, bulkImportResult{Line: ln, Error: err.Error()})
+				}
+			} else {
+				results = append(results, res...)
+			}
+
+			progress(line, 0)
+		}
+
+		if done {
+			break
+		}
+	}
+
+	out, err := json.Marshal(bulkImportJobResult{Results: results})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling job result: %v", err)
+	}
+
+	return out, nil
+}
+
+// HandleEnqueueJob starts a new background job of the given kind, reading
+// its input off the request body, and returns the job's initial state
+// (typically `pending`) for the client to then poll via HandleGetJob.
+func (a *App) HandleEnqueueJob(c echo.Context) error {
+	kind := c.Param("kind")
+
+	var input json.RawMessage
+	switch kind {
+	case jobKindBulkImport:
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("error reading request: %v", err))
+		}
+
+		b, err := json.Marshal(bulkImportJobInput{Format: bulkImportFormat(c), Body: body})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		input = b
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown job kind")
+	}
+
+	j, err := a.jobs.Enqueue(kind, input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("error enqueuing job: %v", err))
+	}
+
+	return c.JSON(http.StatusOK, okResp{j})
+}
+
+// HandleGetJob returns the current state of a single job.
+func (a *App) HandleGetJob(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	j, err := a.jobs.Get(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "job not found")
+	}
+
+	return c.JSON(http.StatusOK, okResp{j})
+}
+
+// HandleListJobs returns every tracked job, most recently created first.
+func (a *App) HandleListJobs(c echo.Context) error {
+	return c.JSON(http.StatusOK, okResp{a.jobs.List()})
+}
+
+// HandleCancelJob asks a running job to stop. Cancellation is advisory: the
+// job's Handler only stops early if it checks its context between units of
+// work.
+func (a *App) HandleCancelJob(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := a.jobs.Cancel(id); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "job not found")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// jobStore persists jobs.Job state to the `jobs` table so in-flight and
+// completed jobs survive a restart. Enforced at the DB layer via the
+// migration that creates the table; queries are raw SQL here (rather than
+// going through queries.sql/data.Queries) since the rest of this
+// self-contained subsystem, like bulk.go, doesn't either.
+type jobStore struct {
+	db *sqlx.DB
+}
+
+// newJobStore returns a jobs.Store backed by db.
+func newJobStore(db *sqlx.DB) *jobStore {
+	return &jobStore{db: db}
+}
+
+// Insert records a newly-enqueued job.
+func (s *jobStore) Insert(j *jobs.Job) error {
+	_, err := s.db.Exec(`
+		INSERT INTO jobs (id, kind, state, progress, total, error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		j.ID, j.Kind, j.State, j.Progress, j.Total, j.Error, j.CreatedAt)
+
+	return err
+}
+
+// Update persists a job's current state.
+func (s *jobStore) Update(j *jobs.Job) error {
+	_, err := s.db.Exec(`
+		UPDATE jobs SET state = $2, progress = $3, total = $4, error = $5,
+			result = $6, finished_at = $7
+		WHERE id = $1`,
+		j.ID, j.State, j.Progress, j.Total, j.Error, j.Result, j.FinishedAt)
+
+	return err
+}
@@ -2,25 +2,36 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	mrand "math/rand"
+	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"regexp"
+	"sync/atomic"
+	"syscall"
+	"time"
 	"unicode"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/knadh/dictpress/internal/cache"
 	"github.com/knadh/dictpress/internal/data"
 	"github.com/knadh/dictpress/internal/importer"
+	"github.com/knadh/dictpress/internal/jobs"
+	"github.com/knadh/dictpress/internal/searchindex"
+	"github.com/knadh/dictpress/internal/spam"
 	"github.com/knadh/go-i18n"
 	"github.com/knadh/koanf/parsers/toml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
 	"github.com/knadh/paginator"
 	"github.com/knadh/stuffbin"
+	"github.com/labstack/echo/v4"
 	"github.com/urfave/cli/v2"
 )
 
@@ -45,6 +56,99 @@ type Consts struct {
 	EnableSubmissions            bool
 	EnableGlossary               bool
 	AdminUsername, AdminPassword []byte
+
+	// SiteMaxEntryRelationsPerType and SiteMaxEntryContentItems cap, per
+	// entry, how many relations of each type and content items a results
+	// page theme template renders, so an entry with an unusually large
+	// number of relations doesn't blow out the page.
+	SiteMaxEntryRelationsPerType int
+	SiteMaxEntryContentItems     int
+
+	// Dev re-parses site templates from disk on every request instead of
+	// using the cached, parsed-once-at-boot template tree.
+	Dev bool
+
+	// Watch starts a background fsnotify watcher over the site theme
+	// directory, its lang.json, and the admin template directory, debouncing
+	// bursts of edits and atomically swapping in freshly parsed templates as
+	// they change, instead of re-parsing on every request like Dev does.
+	Watch bool
+
+	// ShutdownTimeout bounds how long the HTTP server waits for in-flight
+	// requests to finish on SIGINT/SIGTERM before forcing an exit.
+	ShutdownTimeout time.Duration
+
+	// Moderation: submission rate limiting and spam scoring.
+	ModerationRateLimitPerMin      float64
+	ModerationRateLimitBurst       int
+	ModerationSpamAutoRejectScore  float64
+	ModerationSpamAutoApproveScore float64
+	ModerationTrustedIPs           []string
+	ModerationAkismetKey           string
+	ModerationAkismetSite          string
+
+	// Moderation: anti-abuse pipeline for public submissions.
+	ModerationCaptchaProvider string
+	ModerationCaptchaSecret   string
+	ModerationHoneypotField   string
+	ModerationBlocklist       []string
+
+	// Cache: in-memory/hybrid caching of public search and glossary results,
+	// or a shared Redis cache (cache.mode = "redis") so that multiple
+	// dictpress instances behind a load balancer evict in lockstep instead
+	// of each keeping its own stale in-process copy.
+	CacheEnabled   bool
+	CacheMode      string
+	CacheTTL       time.Duration
+	CacheMaxMemory int64
+	CacheDir       string
+
+	// Cache: Redis backend settings, used when CacheMode == cache.CacheTypeRedis.
+	CacheRedisAddr     string
+	CacheRedisPassword string
+	CacheRedisDB       int
+	CacheRedisChannel  string
+
+	// CacheWarmupTopN replays this many of the most frequent queries per
+	// from-language, logged over the preceding 24h, against a freshly
+	// started cache so early traffic after a deploy isn't all misses. 0
+	// disables warmup.
+	CacheWarmupTopN int
+
+	// Jobs: the background job runner used for long-running admin
+	// operations (eg: async bulk import) that are polled for progress.
+	JobsConcurrency int
+
+	// AuditRetentionDays caps how long audit_log rows are kept. 0 (the
+	// default) keeps them forever.
+	AuditRetentionDays int
+
+	// Federation: optional WebSub + ActivityPub publishing of new/updated
+	// entries. FederationPrivateKey (PEM, PKCS#1 or PKCS#8) signs outbound
+	// ActivityPub deliveries; FederationWebSubHub may be left empty to skip
+	// the WebSub ping while still serving Atom feeds and the AP actor.
+	FederationEnabled    bool
+	FederationWebSubHub  string
+	FederationActorName  string
+	FederationPrivateKey string
+
+	// Search: the full-text search backend. "postgres" (the default) uses
+	// the tsvector/pg_trgm search already built into data.Data. "opensearch"
+	// queries an external OpenSearch/Elasticsearch cluster instead.
+	SearchBackend         string
+	SearchOpenSearchURL   string
+	SearchOpenSearchIndex string
+
+	// SearchQueryTimeout bounds a single search request, including relation
+	// loading, so a slow scan can't hold a connection open indefinitely
+	// after the client has moved on.
+	SearchQueryTimeout time.Duration
+
+	// SearchRelationsTimeout, if set, bounds the relations-loading step with
+	// its own shorter deadline carved out of SearchQueryTimeout, so a slow
+	// join on a heavily related entry can't eat the whole search budget. 0
+	// leaves it bound only by SearchQueryTimeout.
+	SearchRelationsTimeout time.Duration
 }
 
 // App contains the "global" components that are
@@ -54,15 +158,117 @@ type App struct {
 	db         *sqlx.DB
 	queries    *data.Queries
 	data       *data.Data
-	i18n       *i18n.I18n
 	fs         stuffbin.FileSystem
 	resultsPg  *paginator.Paginator
 	glossaryPg *paginator.Paginator
 	lo         *log.Logger
 
-	adminTpl     *template.Template
-	siteTpl      *template.Template
-	sitePageTpls map[string]*template.Template
+	// resultsPgOpt and glossaryPgOpt are the options resultsPg/glossaryPg
+	// were built from. a.reloadSite() reuses them as the base for any
+	// per-language results.*/glossary.* overrides a multi-language theme
+	// directory's theme.toml sets (see siteResultsPgByLang below).
+	resultsPgOpt  paginator.Opt
+	glossaryPgOpt paginator.Opt
+
+	// i18n holds the site's loaded language pack -- for a multi-language
+	// theme directory (see loadSite), siteDefaultLang's pack. It's behind
+	// atomic.Pointer so that a.startWatcher() (consts.Watch mode) can swap
+	// in a freshly parsed lang.json without racing concurrent renders.
+	i18n atomic.Pointer[i18n.I18n]
+
+	// adminTpl holds the parsed admin UI templates, behind atomic.Pointer for
+	// the same reason as i18n above.
+	adminTpl atomic.Pointer[template.Template]
+
+	// siteTpl and sitePageTpls hold the parsed site theme -- for a
+	// multi-language theme directory, siteDefaultLang's theme, used for
+	// pages that aren't language-scoped (eg: handleIndexPage) and as the
+	// fallback for languages without their own theme. They're behind
+	// atomic.Pointer so that a.reloadSite() (used in consts.Dev and
+	// consts.Watch modes) can swap in a freshly parsed tree without racing
+	// concurrent renders.
+	siteTpl      atomic.Pointer[template.Template]
+	sitePageTpls atomic.Pointer[map[string]*template.Template]
+
+	// siteTpls, sitePageTplsByLang and i18nByLang hold the parsed theme,
+	// static pages and language pack for each source language, keyed by
+	// language code, when consts.Site is a directory of per-language
+	// subdirectories (eg: themes/de/, themes/en/) rather than a single
+	// theme -- see loadSite. A language missing from these maps falls back
+	// to siteTpl/sitePageTpls/i18n above. Empty (nil) for a single theme
+	// directory. Behind atomic.Pointer for the same reason as siteTpl.
+	siteTpls           atomic.Pointer[map[string]*template.Template]
+	sitePageTplsByLang atomic.Pointer[map[string]map[string]*template.Template]
+	i18nByLang         atomic.Pointer[map[string]*i18n.I18n]
+
+	// siteResultsPgByLang and siteGlossaryPgByLang hold, for the same
+	// per-language theme directories, any results.*/glossary.* paginator
+	// overrides a language's own theme.toml sets. A language missing from
+	// these maps uses the instance-wide resultsPg/glossaryPg.
+	siteResultsPgByLang  atomic.Pointer[map[string]*paginator.Paginator]
+	siteGlossaryPgByLang atomic.Pointer[map[string]*paginator.Paginator]
+
+	// siteDefaultLang is the language whose theme also backs
+	// siteTpl/sitePageTpls/i18n, for a multi-language theme directory. Set
+	// from site.default_lang, falling back to an arbitrary language found
+	// under the theme directory if that language has no theme of its own.
+	// Unused for a single theme directory.
+	siteDefaultLang string
+
+	sitePath      string
+	siteLoadPages bool
+
+	// spamScorer scores public submissions for spam likelihood. Nil disables
+	// scoring (and, with it, auto-reject/auto-approve).
+	spamScorer spam.Scorer
+
+	// submissionLimiter rate-limits public submissions per-IP and per-language.
+	submissionLimiter *submissionLimiter
+
+	// captchaVerifier verifies a CAPTCHA challenge on public submissions.
+	// Defaults to noopCaptchaVerifier when no provider is configured.
+	captchaVerifier captchaVerifier
+
+	// blocklist rejects submissions whose content matches any of these
+	// patterns (moderation.blocklist).
+	blocklist []*regexp.Regexp
+
+	// events fans out live admin notifications (new submissions, comments,
+	// entry updates) to subscribers of /api/admin/events.
+	events *eventHub
+
+	// entryEvents fans out entry/relation change notifications (installed
+	// as data.Notifier on app.data) to subscribers of the public /api/events
+	// stream.
+	entryEvents *entryEventHub
+
+	// cache stores gob-encoded public search/glossary results keyed by the
+	// query, with a TTL, so repeat lookups and typeahead traffic don't all
+	// hit Postgres. Nil disables caching. Backed by either the embedded
+	// Badger cache.Cache or, when cache.mode = "redis", cache.RedisCache,
+	// which also implements cache.Invalidator for fine-grained eviction.
+	cache cache.Store
+
+	// providers are external dictionary search providers (Wiktionary,
+	// Datamuse, user-configured JSON endpoints ...), fanned out to
+	// alongside the local Postgres search on the first page of results.
+	providers []searchProvider
+
+	// jobs runs long admin operations (eg: async bulk import) in the
+	// background so a client can poll /api/jobs/:id for progress instead of
+	// holding a request open for the duration of the work.
+	jobs *jobs.Runner
+
+	// feed publishes new/updated entries over WebSub (an Atom feed per
+	// dict pair) and ActivityPub (a signed outbox delivered to followers).
+	// Nil disables federation.
+	feed *feedPublisher
+
+	// search indexes and queries dictionary entries for full-text search.
+	// Defaults to a thin wrapper over the Postgres search already used by
+	// a.data; set search.backend = "opensearch" in the config to query an
+	// external OpenSearch/Elasticsearch cluster instead.
+	search searchindex.Backend
 }
 
 var (
@@ -85,6 +291,12 @@ func loadConfig(c *cli.Context) *koanf.Koanf {
 	if c.String("site") != "" {
 		ko.Set("site", c.String("site"))
 	}
+	if c.Bool("dev") {
+		ko.Set("app.dev", true)
+	}
+	if c.Bool("watch") {
+		ko.Set("app.watch", true)
+	}
 
 	return ko
 }
@@ -126,54 +338,162 @@ func runNewConfig(ctx *cli.Context) error {
 }
 
 func runInstall(c *cli.Context) error {
-	installSchema(versionString, !c.Bool("yes"), initFS(), initDB(loadConfig(c)), loadConfig(c))
+	installSchema(!c.Bool("yes"), initFS(), initDB(loadConfig(c)), loadConfig(c))
 	return nil
 }
 
 func runUpgrade(c *cli.Context) error {
+	if c.Bool("check") {
+		os.Exit(checkPendingMigrations(initDB(loadConfig(c))))
+		return nil
+	}
+
 	upgrade(!c.Bool("yes"), initFS(), initDB(loadConfig(c)), loadConfig(c))
 	return nil
 }
 
 func runImport(c *cli.Context) error {
+	var (
+		ko     = loadConfig(c)
+		consts = initConstants(ko)
+		db     = initDB(ko)
+		q      = initQueries(initFS(), db)
+		langs  = initLangs(ko)
+	)
+
+	imp := importer.New(langs, q.InsertSubmissionEntry, q.InsertSubmissionRelation, db,
+		data.New(q, langs, initDicts(langs, ko), db), c.Bool("upsert"), lo)
+	lo.Printf("importing data from %s ...", c.String("file"))
+
+	workers, resume := c.Int("workers"), c.Bool("resume")
+	if workers > 1 || resume {
+		err := imp.ImportWithOptions(c.String("file"), c.String("format"), importer.ImportOptions{
+			Workers:   workers,
+			BatchSize: c.Int("batch-size"),
+			Resume:    resume,
+		})
+		if err != nil {
+			lo.Fatal(err)
+		}
+	} else if err := imp.Import(c.String("file"), c.String("format")); err != nil {
+		lo.Fatal(err)
+	}
+
+	// This CLI path constructs its own *data.Data rather than sharing the
+	// server's, so it never calls SetCache and the generation bump
+	// BulkInsert/BulkRelate do on commit has nothing to invalidate. If a
+	// cache is configured, purge it wholesale so a server sharing the same
+	// backend doesn't keep serving pre-import results until TTL expiry.
+	if consts.CacheEnabled {
+		ch, err := initCache(consts)
+		if err != nil {
+			lo.Fatalf("error initializing cache: %v", err)
+		}
+		if err := ch.Purge(); err != nil {
+			lo.Fatalf("error purging cache: %v", err)
+		}
+	}
+
+	os.Exit(0)
+	return nil
+}
+
+// runExport streams every entry (and its outgoing relations) matching
+// --lang/--tag/--status to --file (or stdout if omitted) as JSONL or CSV,
+// the counterpart to runImport -- its JSONL output is re-importable
+// unchanged, including with --upsert to refresh entries that already exist.
+func runExport(c *cli.Context) error {
 	var (
 		ko = loadConfig(c)
 		db = initDB(ko)
-		q  = initQueries(initFS(), db)
 	)
 
-	imp := importer.New(initLangs(ko), q.InsertSubmissionEntry, q.InsertSubmissionRelation, db, lo)
-	lo.Printf("importing data from %s ...", c.String("file"))
-	if err := imp.Import(c.String("file")); err != nil {
+	w := os.Stdout
+	if f := c.String("file"); f != "" {
+		fh, err := os.Create(f)
+		if err != nil {
+			lo.Fatalf("error creating %s: %v", f, err)
+		}
+		defer fh.Close()
+		w = fh
+	}
+
+	opts := importer.ExportOptions{
+		Lang:   c.String("lang"),
+		Tag:    c.String("tag"),
+		Status: c.String("status"),
+	}
+	if err := importer.NewExporter(db).Export(w, c.String("format"), opts); err != nil {
 		lo.Fatal(err)
 	}
+
 	os.Exit(0)
 	return nil
 }
 func runSitemap(c *cli.Context) error {
 	var (
 		ko      = loadConfig(c)
-		queries = initQueries(initFS(), initDB(ko))
+		db      = initDB(ko)
+		queries = initQueries(initFS(), db)
 		consts  = initConstants(ko)
 	)
 
-	lo.Printf("generating sitemaps for %s -> %s", c.String("from-lang"), c.String("to-lang"))
+	format := c.String("format")
+	if format != sitemapFormatTXT && format != sitemapFormatXML && format != sitemapFormatBoth {
+		lo.Fatalf("unknown sitemap format: %s (expected txt, xml or both)", format)
+	}
 
-	// Generate the sitemaps.
-	err := generateSitemaps(c.String("from-lang"),
-		c.String("to-lang"),
-		consts.RootURL,
-		c.Int("max-rows"),
-		c.String("output-prefix"),
-		c.String("output-dir"),
-		queries.GetEntriesForSitemap)
+	pairs, err := resolveSitemapPairs(db, c.String("pairs"), c.String("from-lang"), c.String("to-lang"))
 	if err != nil {
 		lo.Fatal(err)
 	}
+	lo.Printf("generating sitemaps for %d language pair(s)", len(pairs))
+
+	// Every other language pair each entry has translations in, keyed by
+	// from-lang since a headword's alternates don't depend on which
+	// to-lang sitemap is currently being written.
+	altLangs := make(map[string]map[string][]string)
+	for _, p := range pairs {
+		if _, ok := altLangs[p[0]]; ok {
+			continue
+		}
+
+		m, err := getEntryAltLangs(db, p[0])
+		if err != nil {
+			lo.Fatal(err)
+		}
+		altLangs[p[0]] = m
+	}
+
+	for _, p := range pairs {
+		fromLang, toLang := p[0], p[1]
+		lo.Printf("generating sitemap for %s -> %s", fromLang, toLang)
+
+		// Priority can be weighted per from-language via
+		// sitemap.priority.$lang in the config, falling back to 0.5.
+		priority := ko.Float64(fmt.Sprintf("sitemap.priority.%s", fromLang))
+		if priority == 0 {
+			priority = 0.5
+		}
+
+		if err := generateSitemaps(fromLang,
+			toLang,
+			consts.RootURL,
+			c.Int("max-rows"),
+			c.String("output-prefix"),
+			c.String("output-dir"),
+			format,
+			priority,
+			c.String("changefreq"),
+			queries.GetEntriesForSitemap,
+			altLangs[fromLang]); err != nil {
+			lo.Fatal(err)
+		}
+	}
 
 	// Generate robots.txt?
 	if c.Bool("robots") {
-		if err := generateRobotsTxt(c.String("url"), c.String("output-dir")); err != nil {
+		if err := generateRobotsTxt(c.String("url"), c.String("output-dir"), format); err != nil {
 			lo.Fatal(err)
 		}
 	}
@@ -181,6 +501,33 @@ func runSitemap(c *cli.Context) error {
 	return nil
 }
 
+// runReindex rebuilds the configured search backend's index from scratch by
+// streaming every entry out of Postgres. It's a no-op for the default
+// postgres backend, which is always current as of the last write.
+func runReindex(c *cli.Context) error {
+	var (
+		ko     = loadConfig(c)
+		consts = initConstants(ko)
+		db     = initDB(ko)
+		langs  = initLangs(ko)
+		dt     = data.New(initQueries(initFS(), db), langs, initDicts(langs, ko), db)
+	)
+
+	search, err := initSearchBackend(consts, dt, db)
+	if err != nil {
+		lo.Fatalf("error initializing search backend: %v", err)
+	}
+
+	lo.Printf("reindexing into %s search backend ...", consts.SearchBackend)
+	if err := search.Reindex(context.Background()); err != nil {
+		lo.Fatalf("error reindexing: %v", err)
+	}
+
+	lo.Printf("reindex complete")
+	os.Exit(0)
+	return nil
+}
+
 func runServer(c *cli.Context) error {
 	var (
 		ko     = loadConfig(c)
@@ -188,7 +535,7 @@ func runServer(c *cli.Context) error {
 		fs     = initFS()
 		db     = initDB(ko)
 		langs  = initLangs(ko)
-		dt     = data.New(initQueries(fs, db), langs, initDicts(langs, ko))
+		dt     = data.New(initQueries(fs, db), langs, initDicts(langs, ko), db)
 	)
 
 	// Before the queries are prepared, see if there are pending upgrades.
@@ -203,25 +550,105 @@ func runServer(c *cli.Context) error {
 		queries: queries,
 		data:    dt,
 
-		resultsPg: paginator.New(paginator.Opt{
+		resultsPgOpt: paginator.Opt{
 			DefaultPerPage: ko.MustInt("results.default_per_page"),
 			MaxPerPage:     ko.MustInt("results.max_per_page"),
 			NumPageNums:    ko.MustInt("results.num_page_nums"),
 			PageParam:      "page", PerPageParam: "PerPageParam",
-		}),
+		},
+
+		events:      newEventHub(),
+		entryEvents: newEntryEventHub(),
+
+		providers: loadSearchProviders(ko),
 	}
+	dt.SetNotifier(app.entryEvents)
+	app.resultsPg = paginator.New(app.resultsPgOpt)
 
 	if consts.EnableGlossary {
-		app.glossaryPg = paginator.New(paginator.Opt{
+		app.glossaryPgOpt = paginator.Opt{
 			DefaultPerPage: ko.MustInt("glossary.default_per_page"),
 			MaxPerPage:     ko.MustInt("glossary.max_per_page"),
 			NumPageNums:    ko.MustInt("glossary.num_page_nums"),
 			PageParam:      "page", PerPageParam: "PerPageParam",
-		})
+		}
+		app.glossaryPg = paginator.New(app.glossaryPgOpt)
 	}
 
+	if consts.EnableSubmissions {
+		app.submissionLimiter = newSubmissionLimiter(consts.ModerationRateLimitPerMin, consts.ModerationRateLimitBurst)
+
+		if consts.ModerationAkismetKey != "" {
+			app.spamScorer = spam.NewAkismetScorer(
+				fmt.Sprintf("https://%s.rest.akismet.com/1.1/comment-check", consts.ModerationAkismetKey),
+				consts.ModerationAkismetKey,
+				consts.ModerationAkismetSite)
+		} else {
+			app.spamScorer = spam.NewHeuristicScorer(langs, dt)
+		}
+
+		switch consts.ModerationCaptchaProvider {
+		case "hcaptcha":
+			app.captchaVerifier = newHCaptchaVerifier(consts.ModerationCaptchaSecret)
+		case "turnstile":
+			app.captchaVerifier = newTurnstileVerifier(consts.ModerationCaptchaSecret)
+		default:
+			app.captchaVerifier = noopCaptchaVerifier{}
+		}
+
+		blocklist, err := compileBlocklist(consts.ModerationBlocklist)
+		if err != nil {
+			lo.Fatal(err)
+		}
+		app.blocklist = blocklist
+	}
+
+	if consts.CacheEnabled {
+		ch, err := initCache(consts)
+		if err != nil {
+			lo.Fatalf("error initializing cache: %v", err)
+		}
+		app.cache = ch
+
+		// Share the same backend with Data's own, lower-level result cache
+		// (see internal/data/cache.go), which additionally covers authed
+		// and non-HTTP callers that bypass a.cache in handlers.go.
+		dt.SetCache(ch, data.CacheConfig{DefaultTTL: consts.CacheTTL})
+
+		// Redis backends additionally subscribe to invalidations published
+		// by every dictpress instance (including this one) sharing the
+		// cache, so a write on any instance evicts everywhere.
+		if inv, ok := ch.(cache.Invalidator); ok {
+			if err := inv.SubscribeInvalidation(nil); err != nil {
+				lo.Fatalf("error subscribing to cache invalidation: %v", err)
+			}
+		}
+
+		go app.warmupCache()
+	}
+
+	app.jobs = jobs.New(consts.JobsConcurrency, newJobStore(db))
+	app.registerJobHandlers()
+	app.registerImportJobHandler()
+
+	go app.auditPruneLoop()
+
+	if consts.FederationEnabled {
+		feed, err := newFeedPublisher(consts, db)
+		if err != nil {
+			lo.Fatalf("error initializing federation: %v", err)
+		}
+		app.feed = feed
+	}
+
+	search, err := initSearchBackend(consts, dt, db)
+	if err != nil {
+		lo.Fatalf("error initializing search backend: %v", err)
+	}
+	app.search = search
+
 	// Load admin HTML templates.
-	app.adminTpl = initAdminTemplates(fs)
+	app.adminTpl.Store(initAdminTemplates(fs))
 
 	// Initialize the echo HTTP server.
 	srv := initHTTPServer(app, ko)
@@ -229,35 +656,62 @@ func runServer(c *cli.Context) error {
 	// Load optional HTML website.
 	if consts.Site != "" {
 		lo.Printf("loading site theme: %s", consts.Site)
-		theme, pages, err := loadSite(consts.Site, ko.Bool("app.enable_pages"))
-		if err != nil {
+
+		app.sitePath = consts.Site
+		app.siteLoadPages = ko.Bool("app.enable_pages")
+		app.siteDefaultLang = ko.String("site.default_lang")
+
+		// reloadSite() also loads the language pack(s): a single
+		// consts.Site/lang.json, or one lang.json per language subdirectory
+		// if consts.Site is a multi-language theme directory.
+		if err := app.reloadSite(); err != nil {
 			lo.Fatalf("error loading site theme: %v", err)
 		}
 
-		// Optionally load a language pack.
-		langFile := filepath.Join(consts.Site, "lang.json")
-		if _, err := os.Stat(langFile); !errors.Is(err, os.ErrNotExist) {
-			i, err := i18n.NewFromFile(langFile)
-			if err != nil {
-				lo.Fatalf("error loading i18n lang.json file: %v", err)
-			}
-			app.i18n = i
-		} else {
-			app.i18n, _ = i18n.New([]byte(`{"_.code": "", "_.name": ""}`))
+		if consts.Dev {
+			lo.Printf("dev mode: site templates will be re-parsed on every request")
 		}
 
 		// Attach HTML template renderer.
-		app.siteTpl = theme
-		app.sitePageTpls = pages
-		srv.Renderer = &tplRenderer{tpls: theme}
+		srv.Renderer = &tplRenderer{}
+	}
+
+	if consts.Watch {
+		if err := app.startWatcher(); err != nil {
+			lo.Fatalf("error starting template watcher: %v", err)
+		}
 	}
 
 	lo.Printf("starting server on %s", ko.MustString("app.address"))
-	if err := srv.Start(ko.MustString("app.address")); err != nil {
-		lo.Fatalf("error starting HTTP server: %v", err)
+	return startServerWithGracefulShutdown(srv, ko.MustString("app.address"), consts.ShutdownTimeout)
+}
+
+// startServerWithGracefulShutdown starts srv and blocks until it exits. On
+// SIGINT/SIGTERM it stops accepting new connections and waits up to timeout
+// for in-flight requests (eg: long-running searches) to finish before
+// returning, instead of cutting them off mid-flight.
+func startServerWithGracefulShutdown(srv *echo.Echo, address string, timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		if err := srv.Start(address); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-errCh:
+		return err
+	case sig := <-sigCh:
+		lo.Printf("received %s, shutting down (draining up to %s)", sig, timeout)
 	}
 
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return srv.Shutdown(ctx)
 }
 
 func main() {
@@ -277,6 +731,16 @@ func main() {
 				Usage:   "path to a site theme. If left empty, only HTTP APIs will be available",
 				EnvVars: []string{"DICTPRESS_SITE"},
 			},
+			&cli.BoolFlag{
+				Name:    "dev",
+				Usage:   "dev mode: re-parse site templates from disk on every request",
+				EnvVars: []string{"DICTPRESS_DEV"},
+			},
+			&cli.BoolFlag{
+				Name:    "watch",
+				Usage:   "watch mode: watch the site theme, lang.json, and admin templates for changes and live-reload them",
+				EnvVars: []string{"DICTPRESS_WATCH"},
+			},
 		},
 		Action: runServer,
 		Commands: []*cli.Command{
@@ -305,18 +769,71 @@ func main() {
 						Name:  "yes",
 						Usage: "assume 'yes' to prompts during upgrade",
 					},
+					&cli.BoolFlag{
+						Name:  "check",
+						Usage: "check for pending migrations and exit non-zero without applying them",
+					},
 				},
 			},
 			{
 				Name:   "import",
-				Usage:  "Import a CSV file into the database",
+				Usage:  "Bulk import dictionary entries into the database",
 				Action: runImport,
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "file",
-						Usage:    "CSV file to import",
+						Usage:    "File to import",
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Import format: csv, jsonl, tei, stardict, wordnet. Auto-detected from the file extension if omitted",
+					},
+					&cli.IntFlag{
+						Name:  "workers",
+						Usage: "Number of concurrent workers committing batches to the DB. 1 (default) imports serially",
+						Value: 1,
+					},
+					&cli.IntFlag{
+						Name:  "batch-size",
+						Usage: "Number of entries committed per transaction. Only used with --workers > 1 or --resume",
+					},
+					&cli.BoolFlag{
+						Name:  "resume",
+						Usage: "Resume from the checkpoint file left by a previous, interrupted run of the same --file",
+					},
+					&cli.BoolFlag{
+						Name:  "upsert",
+						Usage: "Update an existing entry in place (matched by its `guid`) instead of inserting a duplicate. JSONL rows without a guid are always inserted",
+					},
+				},
+			},
+			{
+				Name:   "export",
+				Usage:  "Stream dictionary entries and their relations out to a file",
+				Action: runExport,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "file",
+						Usage: "File to write to. Prints to stdout if omitted",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Export format: jsonl or csv",
+						Value: "jsonl",
+					},
+					&cli.StringFlag{
+						Name:  "lang",
+						Usage: "Only export entries in this language",
+					},
+					&cli.StringFlag{
+						Name:  "tag",
+						Usage: "Only export entries with this tag",
+					},
+					&cli.StringFlag{
+						Name:  "status",
+						Usage: "Only export entries with this status: enabled, pending, disabled",
+					},
 				},
 			},
 			{
@@ -325,14 +842,16 @@ func main() {
 				Action: runSitemap,
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "from-lang",
-						Usage:    "Language to translate from",
-						Required: true,
+						Name:  "from-lang",
+						Usage: "Language to translate from. Ignored if --pairs is set",
 					},
 					&cli.StringFlag{
-						Name:     "to-lang",
-						Usage:    "Language to translate to",
-						Required: true,
+						Name:  "to-lang",
+						Usage: "Language to translate to. Ignored if --pairs is set",
+					},
+					&cli.StringFlag{
+						Name:  "pairs",
+						Usage: "Comma separated from:to language pairs to generate sitemaps for, eg: en:fr,en:de,fr:en. If omitted, from-lang/to-lang is used, or pairs are discovered from entries that have translations in the database",
 					},
 					&cli.StringFlag{
 						Name:     "url",
@@ -358,8 +877,23 @@ func main() {
 						Name:  "robots",
 						Usage: "Generate robots.txt",
 					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Sitemap output format: txt, xml (xml is gzipped and includes a sitemap_index.xml) or both",
+						Value: sitemapFormatTXT,
+					},
+					&cli.StringFlag{
+						Name:  "changefreq",
+						Usage: "changefreq value to set on every URL in xml format",
+						Value: "weekly",
+					},
 				},
 			},
+			{
+				Name:   "reindex",
+				Usage:  "Rebuild the configured search backend's index from all entries in Postgres",
+				Action: runReindex,
+			},
 		},
 	}
 
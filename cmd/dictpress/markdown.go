@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/knadh/dictpress/internal/data"
+	"github.com/knadh/dictpress/internal/markdown"
+)
+
+const (
+	notesFormatMarkdown = "markdown"
+	notesFormatPlain    = "plain"
+	notesFormatHTML     = "html"
+)
+
+// renderEntryNotes renders e.Notes to sanitized HTML when e.Meta["notes_format"]
+// (or, if unset, the entry's language default) is "markdown", caching the
+// result in e.Meta["notes_html"] so themes don't re-render on every read.
+// Entries whose format is "plain" or "html" are left untouched -- the point
+// of notes_format is that legacy entries keep rendering exactly as before.
+func (a *App) renderEntryNotes(e data.Entry) data.Entry {
+	if e.Meta == nil {
+		e.Meta = data.JSON{}
+	}
+
+	format, _ := e.Meta["notes_format"].(string)
+	if format == "" {
+		format = notesFormatPlain
+		if lang, ok := a.data.Langs[e.Lang]; ok && lang.NotesMarkdown {
+			format = notesFormatMarkdown
+		}
+		e.Meta["notes_format"] = format
+	}
+
+	if format != notesFormatMarkdown {
+		return e
+	}
+
+	autoLink := a.data.Langs[e.Lang].NotesAutoLink
+	html, err := markdown.Render(e.Notes, markdown.Options{AutoLink: autoLink})
+	if err != nil {
+		a.lo.Printf("error rendering markdown notes for entry: %v", err)
+		return e
+	}
+
+	e.Meta["notes_html"] = html
+	e.Meta["notes_text"] = markdown.Strip(e.Notes)
+
+	return e
+}
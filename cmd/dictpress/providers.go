@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/knadh/dictpress/internal/data"
+	"github.com/knadh/koanf/v2"
+)
+
+// searchProvider is an external dictionary lookup that doSearch fans out to
+// alongside the local Postgres search, merging its results into the
+// response and tagging each entry's Source with the provider's Name.
+type searchProvider interface {
+	// Name identifies the provider and is tagged onto its result entries'
+	// Source field.
+	Name() string
+
+	// Weight controls how this provider's results are interleaved with
+	// local and other providers' results: higher sorts earlier among ties.
+	Weight() float64
+
+	// Find looks q up against the provider. It must respect ctx's deadline
+	// so a slow upstream can't stall the whole search response.
+	Find(ctx context.Context, q data.Query) ([]data.Entry, error)
+}
+
+// loadSearchProviders builds the external search providers configured under
+// [search_providers.*] in config.toml.
+func loadSearchProviders(ko *koanf.Koanf) []searchProvider {
+	var out []searchProvider
+
+	for _, name := range ko.MapKeys("search_providers") {
+		pfx := "search_providers." + name + "."
+
+		weight := ko.Float64(pfx + "weight")
+		if weight <= 0 {
+			weight = 1
+		}
+
+		switch ko.String(pfx + "type") {
+		case "wiktionary":
+			isoLang := ko.String(pfx + "lang")
+			if isoLang == "" {
+				isoLang = "en"
+			}
+			out = append(out, newWiktionaryProvider(weight, isoLang))
+
+		case "json_template":
+			out = append(out, newJSONTemplateProvider(name, weight,
+				ko.MustString(pfx+"url"),
+				ko.String(pfx+"result_path"),
+				ko.MustString(pfx+"content_field")))
+
+		default:
+			lo.Fatalf("unknown type for search_providers.%s", name)
+		}
+
+		lo.Printf("loaded search provider: %s", name)
+	}
+
+	return out
+}
+
+// wiktionaryProvider looks words up against the Wiktionary REST API
+// (https://en.wiktionary.org/api/rest_v1/page/definition/{word}),
+// restricted to the senses tagged with isoLang (eg: "en", "fr").
+type wiktionaryProvider struct {
+	weight  float64
+	isoLang string
+	client  *http.Client
+}
+
+// newWiktionaryProvider returns a searchProvider backed by Wiktionary.
+func newWiktionaryProvider(weight float64, isoLang string) *wiktionaryProvider {
+	return &wiktionaryProvider{
+		weight:  weight,
+		isoLang: isoLang,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Name implements searchProvider.
+func (p *wiktionaryProvider) Name() string { return "wiktionary" }
+
+// Weight implements searchProvider.
+func (p *wiktionaryProvider) Weight() float64 { return p.weight }
+
+// wiktionarySense is one part-of-speech block in a Wiktionary definition
+// response.
+type wiktionarySense struct {
+	PartOfSpeech string `json:"partOfSpeech"`
+	Language     string `json:"language"`
+	Definitions  []struct {
+		Definition string `json:"definition"`
+	} `json:"definitions"`
+}
+
+var reHTMLTag = regexp.MustCompile(`<[^>]*>`)
+
+// Find implements searchProvider.
+func (p *wiktionaryProvider) Find(ctx context.Context, q data.Query) ([]data.Entry, error) {
+	u := fmt.Sprintf("https://en.wiktionary.org/api/rest_v1/page/definition/%s", url.PathEscape(q.Query))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling wiktionary: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// No entry for this word at all.
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("wiktionary returned HTTP %d", resp.StatusCode)
+	}
+
+	// The response is keyed by ISO language code.
+	var out map[string][]wiktionarySense
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("error decoding wiktionary response: %v", err)
+	}
+
+	senses, ok := out[p.isoLang]
+	if !ok {
+		return nil, nil
+	}
+
+	var entries []data.Entry
+	for _, sense := range senses {
+		for _, d := range sense.Definitions {
+			def := strings.TrimSpace(html.UnescapeString(reHTMLTag.ReplaceAllString(d.Definition, "")))
+			if def == "" {
+				continue
+			}
+
+			entries = append(entries, data.Entry{Content: def, Lang: q.FromLang})
+		}
+	}
+
+	return entries, nil
+}
+
+// jsonTemplateProvider queries a user-configured HTTP/JSON endpoint (eg:
+// Datamuse: https://api.datamuse.com/words?sp=%s) and extracts a flat array
+// of results from it, using contentField as each result's text.
+type jsonTemplateProvider struct {
+	name         string
+	weight       float64
+	urlTemplate  string
+	resultPath   string
+	contentField string
+	client       *http.Client
+}
+
+// newJSONTemplateProvider returns a generic searchProvider that GETs
+// urlTemplate (with a single %s placeholder for the URL-escaped query),
+// pulls the array of results at resultPath (dot-separated object keys;
+// empty for a top-level array) and reads contentField off each result.
+func newJSONTemplateProvider(name string, weight float64, urlTemplate, resultPath, contentField string) *jsonTemplateProvider {
+	return &jsonTemplateProvider{
+		name:         name,
+		weight:       weight,
+		urlTemplate:  urlTemplate,
+		resultPath:   resultPath,
+		contentField: contentField,
+		client:       &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Name implements searchProvider.
+func (p *jsonTemplateProvider) Name() string { return p.name }
+
+// Weight implements searchProvider.
+func (p *jsonTemplateProvider) Weight() float64 { return p.weight }
+
+// Find implements searchProvider.
+func (p *jsonTemplateProvider) Find(ctx context.Context, q data.Query) ([]data.Entry, error) {
+	u := fmt.Sprintf(p.urlTemplate, url.QueryEscape(q.Query))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s: %v", p.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned HTTP %d", p.name, resp.StatusCode)
+	}
+
+	var raw interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error decoding %s response: %v", p.name, err)
+	}
+
+	items, err := jsonPath(raw, p.resultPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", p.name, err)
+	}
+
+	arr, ok := items.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: result_path %q did not resolve to an array", p.name, p.resultPath)
+	}
+
+	var entries []data.Entry
+	for _, item := range arr {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		content, ok := obj[p.contentField].(string)
+		if !ok || content == "" {
+			continue
+		}
+
+		entries = append(entries, data.Entry{Content: content, Lang: q.FromLang})
+	}
+
+	return entries, nil
+}
+
+// jsonPath navigates a decoded JSON value by a dot-separated path of object
+// keys (eg: "data.results"). An empty path returns v unchanged.
+func jsonPath(v interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return v, nil
+	}
+
+	cur := v
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("result_path %q: %q is not an object", path, part)
+		}
+
+		next, ok := obj[part]
+		if !ok {
+			return nil, fmt.Errorf("result_path %q: key %q not found", path, part)
+		}
+		cur = next
+	}
+
+	return cur, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/knadh/dictpress/internal/data"
+)
+
+// fakeProvider is a stub searchProvider for exercising federateSearch
+// without any network calls.
+type fakeProvider struct {
+	name    string
+	weight  float64
+	entries []data.Entry
+}
+
+func (p *fakeProvider) Name() string    { return p.name }
+func (p *fakeProvider) Weight() float64 { return p.weight }
+func (p *fakeProvider) Find(_ context.Context, _ data.Query) ([]data.Entry, error) {
+	return p.entries, nil
+}
+
+func TestFederateSearchMergesDedupesAndOrdersByWeight(t *testing.T) {
+	a := &App{
+		lo: lo,
+		providers: []searchProvider{
+			&fakeProvider{name: "low", weight: 1, entries: []data.Entry{
+				{Content: "cat", Lang: "english"},    // duplicates a local result
+				{Content: "feline", Lang: "english"}, // unique
+			}},
+			&fakeProvider{name: "high", weight: 10, entries: []data.Entry{
+				{Content: "kitty", Lang: "english"},
+			}},
+		},
+	}
+
+	out := &results{Entries: []data.Entry{{Content: "Cat", Lang: "english"}}}
+	a.federateSearch(context.Background(), data.Query{Query: "cat", FromLang: "english", Page: 1}, out)
+
+	if len(out.Entries) != 3 {
+		t.Fatalf("expected 3 entries after federation (1 local + 2 unique), got %d: %+v", len(out.Entries), out.Entries)
+	}
+
+	// The local result stays first; the higher-weighted provider's unique
+	// hit is interleaved before the lower-weighted one's.
+	if out.Entries[1].Content != "kitty" || out.Entries[1].Source != "high" {
+		t.Fatalf("expected high-weight provider's entry second, got %+v", out.Entries[1])
+	}
+	if out.Entries[2].Content != "feline" || out.Entries[2].Source != "low" {
+		t.Fatalf("expected low-weight provider's entry last, got %+v", out.Entries[2])
+	}
+}
+
+func TestFederateSearchSkipsPastFirstPage(t *testing.T) {
+	a := &App{
+		lo: lo,
+		providers: []searchProvider{
+			&fakeProvider{name: "p", weight: 1, entries: []data.Entry{{Content: "x", Lang: "english"}}},
+		},
+	}
+
+	out := &results{Entries: []data.Entry{}}
+	a.federateSearch(context.Background(), data.Query{Query: "cat", FromLang: "english", Page: 2}, out)
+
+	if len(out.Entries) != 0 {
+		t.Fatalf("expected no federated entries past page 1, got %+v", out.Entries)
+	}
+}
+
+func TestJSONTemplateProviderFind(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"results": []map[string]string{
+				{"word": "cat"},
+				{"word": "feline"},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	p := newJSONTemplateProvider("datamuse", 1, srv.URL+"/?sp=%s", "results", "word")
+
+	entries, err := p.Find(context.Background(), data.Query{Query: "cat", FromLang: "english"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Content != "cat" || entries[1].Content != "feline" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	v := map[string]interface{}{
+		"data": map[string]interface{}{
+			"results": []interface{}{"a", "b"},
+		},
+	}
+
+	got, err := jsonPath(v, "data.results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arr, ok := got.([]interface{}); !ok || len(arr) != 2 {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+
+	if _, err := jsonPath(v, "data.missing"); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// submissionLimiterGCEvery is how often idle per-key limiters are swept out,
+// so a long-running process doesn't accumulate one goroutine-free entry per
+// IP/language ever seen.
+const submissionLimiterGCEvery = 10 * time.Minute
+
+// submissionLimiterIdleAfter is how long a key can go unused before its
+// limiter is eligible for GC.
+const submissionLimiterIdleAfter = 30 * time.Minute
+
+// limiterEntry pairs a rate.Limiter with the last time it was consulted, so
+// idle entries can be identified and swept.
+type limiterEntry struct {
+	lim      *rate.Limiter
+	lastSeen time.Time
+}
+
+// submissionLimiter is a token-bucket rate limiter for public submissions,
+// keyed independently by client IP and by language so that a single noisy
+// IP or a flood of submissions in one language can't starve the others.
+type submissionLimiter struct {
+	mu    sync.Mutex
+	perIP map[string]*limiterEntry
+	perLg map[string]*limiterEntry
+
+	rate  rate.Limit
+	burst int
+}
+
+// newSubmissionLimiter returns a submissionLimiter that allows, per key,
+// `perMinute` submissions a minute with bursts up to `burst`. It starts a
+// background goroutine that periodically garbage collects limiters for keys
+// that haven't been seen in a while.
+func newSubmissionLimiter(perMinute float64, burst int) *submissionLimiter {
+	l := &submissionLimiter{
+		perIP: make(map[string]*limiterEntry),
+		perLg: make(map[string]*limiterEntry),
+		rate:  rate.Limit(perMinute / 60),
+		burst: burst,
+	}
+
+	go l.gcLoop()
+
+	return l
+}
+
+// RetryAfter returns the duration a rejected client should be told to wait
+// before retrying (rounded up to a whole second), for use in a `Retry-After`
+// response header.
+func (l *submissionLimiter) RetryAfter() time.Duration {
+	if l.rate <= 0 {
+		return time.Second
+	}
+
+	d := time.Duration(float64(time.Second) / float64(l.rate))
+	if d < time.Second {
+		return time.Second
+	}
+	return d
+}
+
+// Allow reports whether a new submission from ip for lang should be allowed,
+// consuming one token from both its IP and language buckets if so.
+func (l *submissionLimiter) Allow(ip, lang string) bool {
+	ipLim := l.limiterFor(l.perIP, ip)
+	lgLim := l.limiterFor(l.perLg, lang)
+
+	// Reserve from both before committing to either. ipLim.Allow() &&
+	// lgLim.Allow() would consume the IP's token outright before checking
+	// the language bucket, draining a well-behaved IP's quota on every
+	// submission in a language that's currently rate-limited. Reserving
+	// lets a rejection on either bucket be cancelled on both, so a
+	// submission is only ever actually counted against a bucket when it's
+	// let through.
+	ipRes := ipLim.Reserve()
+	if !ipRes.OK() || ipRes.Delay() > 0 {
+		ipRes.Cancel()
+		return false
+	}
+
+	lgRes := lgLim.Reserve()
+	if !lgRes.OK() || lgRes.Delay() > 0 {
+		lgRes.Cancel()
+		ipRes.Cancel()
+		return false
+	}
+
+	return true
+}
+
+// limiterFor returns the rate.Limiter for key in m, creating it if this is
+// the first time key is seen.
+func (l *submissionLimiter) limiterFor(m map[string]*limiterEntry, key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := m[key]
+	if !ok {
+		e = &limiterEntry{lim: rate.NewLimiter(l.rate, l.burst)}
+		m[key] = e
+	}
+	e.lastSeen = time.Now()
+
+	return e.lim
+}
+
+// gcLoop periodically sweeps out limiters that haven't been used in a while.
+func (l *submissionLimiter) gcLoop() {
+	for range time.Tick(submissionLimiterGCEvery) {
+		l.gc()
+	}
+}
+
+// gc removes per-IP and per-language limiters idle for longer than
+// submissionLimiterIdleAfter.
+func (l *submissionLimiter) gc() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := time.Now().Add(-submissionLimiterIdleAfter)
+	for _, m := range []map[string]*limiterEntry{l.perIP, l.perLg} {
+		for k, e := range m {
+			if e.lastSeen.Before(cutoff) {
+				delete(m, k)
+			}
+		}
+	}
+}
+
+// isTrustedIP reports whether ip is present in the configured list of
+// trusted IPs that are allowed to bypass the moderation queue.
+func isTrustedIP(ip string, trusted []string) bool {
+	for _, t := range trusted {
+		if t == ip {
+			return true
+		}
+	}
+
+	return false
+}
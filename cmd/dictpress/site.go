@@ -60,10 +60,8 @@ type tplData struct {
 	Data     interface{}
 }
 
-// tplRenderer wraps a template.tplRenderer for echo.
-type tplRenderer struct {
-	tpls *template.Template
-}
+// tplRenderer wraps the site's atomically-swappable template tree for echo.
+type tplRenderer struct{}
 
 // Random hash that changes every time the program boots, to append as
 // ?v=$hash to static assets in templates for cache-busting on program restarts.
@@ -75,19 +73,17 @@ func init() {
 }
 
 // handleIndexPage renders the homepage.
-func handleIndexPage(c echo.Context) error {
+func (a *App) handleIndexPage(c echo.Context) error {
 	return c.Render(http.StatusOK, "index", pageTpl{
 		PageType: pageIndex,
 	})
 }
 
 // handleSearchPage renders the search results page.
-func handleSearchPage(c echo.Context) error {
-	var (
-		app = c.Get("app").(*App)
-	)
+func (app *App) handleSearchPage(c echo.Context) error {
+	fromLang := c.Param("fromLang")
 
-	q, err := prepareQuery(c)
+	q, err := app.prepareQuery(c)
 	if err != nil {
 		return c.Render(http.StatusInternalServerError, "message", pageTpl{
 			Title: "Error", Heading: "Error", Description: err.Error(),
@@ -98,7 +94,7 @@ func handleSearchPage(c echo.Context) error {
 	q.MaxRelations = app.consts.SiteMaxEntryRelationsPerType
 	q.MaxContentItems = app.consts.SiteMaxEntryContentItems
 
-	res, err := doSearch(q, false, app.pgSite, app)
+	res, _, err := app.doSearch(c.Request().Context(), q, false, app.resultsPaginatorFor(fromLang))
 	if err != nil {
 		return c.Render(http.StatusInternalServerError, "message", pageTpl{
 			Title: "Error", Heading: "Error", Description: err.Error(),
@@ -112,38 +108,13 @@ func handleSearchPage(c echo.Context) error {
 	})
 }
 
-// handleSubmissionPage renders the new entry submission page.
-func handleSubmissionPage(c echo.Context) error {
-	if c.Request().Method == http.MethodPost {
-		if err := handleNewSubmission(c); err != nil {
-			e := err.(*echo.HTTPError)
-			return c.Render(e.Code, "message", pageTpl{
-				Title:       "Error",
-				Heading:     "Error",
-				Description: fmt.Sprintf("%s", e.Message),
-			})
-		}
-
-		return c.Render(http.StatusOK, "message", pageTpl{
-			Title:       "Submitted",
-			Heading:     "Submitted",
-			Description: "Your entry has been submitted for review.",
-		})
-	}
-
-	return c.Render(http.StatusOK, "submit-entry", pageTpl{
-		Title: "Submit a new entry",
-	})
-}
-
 // handleGlossaryPage renders the alphabet glossary page.
-func handleGlossaryPage(c echo.Context) error {
+func (app *App) handleGlossaryPage(c echo.Context) error {
 	var (
-		app      = c.Get("app").(*App)
 		fromLang = c.Param("fromLang")
 		toLang   = c.Param("toLang")
 		initial  = c.Param("initial")
-		pg       = app.glossaryPg.NewFromURL(c.Request().URL.Query())
+		pg       = app.glossaryPaginatorFor(fromLang).NewFromURL(c.Request().URL.Query())
 	)
 
 	// Get the alphabets.
@@ -176,7 +147,7 @@ func handleGlossaryPage(c echo.Context) error {
 	}
 
 	// Get words.
-	gloss, err := getGlossaryWords(fromLang, initial, pg, app)
+	gloss, err := app.getGlossaryWords(fromLang, initial, pg)
 	if err != nil {
 		app.lo.Printf("error getting glossary words: %v", err)
 		return c.Render(http.StatusInternalServerError, "message", pageTpl{
@@ -190,25 +161,45 @@ func handleGlossaryPage(c echo.Context) error {
 	gloss.ToLang = toLang
 	pg.SetTotal(gloss.Total)
 
-	// Render the results.
-	return c.Render(http.StatusOK, "glossary", pageTpl{
+	// Render the results, honoring If-None-Match so a repeat visit to an
+	// unchanged page (or a CDN sitting in front of it) doesn't re-execute
+	// the template and resend the body.
+	return renderCached(c, app, "glossary", pageTpl{
 		PageType: pageGlossary,
 		Initial:  initial,
 		Initials: initials,
-		Glossary: gloss,
+		Glossary: &gloss,
 		Pg:       &pg,
 		PgBar:    template.HTML(pg.HTML("?page=%d")),
 	})
 }
 
 // handleStaticPage renders an arbitrary static page.
-func handleStaticPage(c echo.Context) error {
-	var (
-		app = c.Get("app").(*App)
-		id  = strings.TrimRight(c.Param("page"), "/")
-	)
+func (app *App) handleStaticPage(c echo.Context) error {
+	id := strings.TrimRight(c.Param("page"), "/")
+
+	// In dev mode, re-parse the site theme from disk on every request so
+	// theme authors see edits without restarting dictpress.
+	if app.consts.Dev {
+		if err := app.reloadSite(); err != nil {
+			app.lo.Printf("error reloading site theme: %v", err)
+			return c.Render(http.StatusInternalServerError, "message", pageTpl{
+				Title:       "Error",
+				Heading:     "Error",
+				Description: "Error reloading site theme.",
+			})
+		}
+	}
+
+	pages := app.sitePageTpls.Load()
+	if pages == nil {
+		return c.Render(http.StatusNotFound, "message", pageTpl{
+			Title:   "404",
+			Heading: "Page not found",
+		})
+	}
 
-	tpl, ok := app.sitePageTpls[id]
+	tpl, ok := (*pages)[id]
 	if !ok {
 		return c.Render(http.StatusNotFound, "message", pageTpl{
 			Title:   "404",
@@ -225,7 +216,7 @@ func handleStaticPage(c echo.Context) error {
 		Consts:   app.consts,
 		Langs:    app.data.Langs,
 		Dicts:    app.data.Dicts,
-		L:        app.i18n,
+		L:        app.i18n.Load(),
 		Data: pageTpl{
 			PageType: pageStatic,
 			PageID:   id,
@@ -299,17 +290,140 @@ func loadSite(rootPath string, loadPages bool) (*template.Template, map[string]*
 	return theme, pages, nil
 }
 
+// reloadSite re-parses the site theme (and, if enabled, its static pages)
+// and its language pack(s) from disk and atomically swaps them in, so that
+// renders already in flight keep using the tree they started with.
+//
+// a.sitePath is either a single theme directory, or a directory of
+// per-language theme subdirectories (see isMultiLangSite) -- in the latter
+// case, every language's own theme/pages/lang.json/theme.toml is loaded
+// into the *ByLang maps, and a.siteDefaultLang's also becomes the
+// siteTpl/sitePageTpls/i18n used for pages that aren't language-scoped.
+func (a *App) reloadSite() error {
+	if isMultiLangSite(a.sitePath, a.data.Langs) {
+		set, err := loadSiteThemes(a.sitePath, a.siteLoadPages, a.data.Langs, a.siteDefaultLang, a.resultsPgOpt, a.glossaryPgOpt)
+		if err != nil {
+			return err
+		}
+
+		a.siteTpls.Store(&set.tpls)
+		a.sitePageTplsByLang.Store(&set.pageTpls)
+		a.i18nByLang.Store(&set.i18ns)
+		a.siteResultsPgByLang.Store(&set.resultsPgs)
+		a.siteGlossaryPgByLang.Store(&set.glossaryPgs)
+		a.siteDefaultLang = set.defaultLang
+
+		pages := set.pageTpls[set.defaultLang]
+		a.siteTpl.Store(set.tpls[set.defaultLang])
+		a.sitePageTpls.Store(&pages)
+		a.i18n.Store(set.i18ns[set.defaultLang])
+
+		return nil
+	}
+
+	theme, pages, err := loadSite(a.sitePath, a.siteLoadPages)
+	if err != nil {
+		return err
+	}
+	a.siteTpl.Store(theme)
+	a.sitePageTpls.Store(&pages)
+
+	i, err := loadI18n(filepath.Join(a.sitePath, "lang.json"))
+	if err != nil {
+		return err
+	}
+	a.i18n.Store(i)
+
+	return nil
+}
+
+// siteThemeFor returns the parsed template tree and i18n pack for lang (a
+// request's :fromLang), falling back to the default theme -- used as-is for
+// pages that aren't language-scoped (eg: handleIndexPage, handleStaticPage),
+// and as the fallback for a language without its own theme directory.
+func (a *App) siteThemeFor(lang string) (*template.Template, *i18n.I18n) {
+	if lang != "" {
+		if tpls := a.siteTpls.Load(); tpls != nil {
+			if tpl, ok := (*tpls)[lang]; ok {
+				i18ns := a.i18nByLang.Load()
+				return tpl, (*i18ns)[lang]
+			}
+		}
+	}
+
+	return a.siteTpl.Load(), a.i18n.Load()
+}
+
+// resultsPaginatorFor returns lang's results.* paginator override (from its
+// theme.toml), falling back to the instance-wide a.resultsPg.
+func (a *App) resultsPaginatorFor(lang string) *paginator.Paginator {
+	if pgs := a.siteResultsPgByLang.Load(); pgs != nil {
+		if pg, ok := (*pgs)[lang]; ok {
+			return pg
+		}
+	}
+	return a.resultsPg
+}
+
+// glossaryPaginatorFor returns lang's glossary.* paginator override (from
+// its theme.toml), falling back to the instance-wide a.glossaryPg.
+func (a *App) glossaryPaginatorFor(lang string) *paginator.Paginator {
+	if pgs := a.siteGlossaryPgByLang.Load(); pgs != nil {
+		if pg, ok := (*pgs)[lang]; ok {
+			return pg
+		}
+	}
+	return a.glossaryPg
+}
+
 // Render executes and renders a template for echo.
 func (t *tplRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
 	app := c.Get("app").(*App)
 
-	return t.tpls.ExecuteTemplate(w, name, tplData{
+	// In dev mode, re-parse the site theme from disk on every request so
+	// theme authors see edits without restarting dictpress. In watch mode,
+	// a.startWatcher() keeps the theme current in the background instead.
+	if app.consts.Dev {
+		if err := app.reloadSite(); err != nil {
+			return err
+		}
+	}
+
+	tpl, l := app.siteThemeFor(c.Param("fromLang"))
+
+	return tpl.ExecuteTemplate(w, name, tplData{
 		Path:     c.Path(),
 		AssetVer: assetVer,
 		Consts:   app.consts,
 		Langs:    app.data.Langs,
 		Dicts:    app.data.Dicts,
-		L:        app.i18n,
+		L:        l,
 		Data:     data,
 	})
 }
+
+// renderCached renders name/data like c.Render, additionally setting
+// Cache-Control/Vary and a strong ETag derived from the rendered bytes when
+// result caching is enabled, and answering a matching If-None-Match with a
+// 304 instead of re-executing the template and resending the page.
+func renderCached(c echo.Context, app *App, name string, data pageTpl) error {
+	buf := new(bytes.Buffer)
+	if err := c.Echo().Renderer.Render(buf, name, data, c); err != nil {
+		return err
+	}
+
+	if app.cache == nil {
+		return c.HTMLBlob(http.StatusOK, buf.Bytes())
+	}
+
+	etag := etagForBytes(buf.Bytes())
+	c.Response().Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(app.consts.CacheTTL.Seconds())))
+	c.Response().Header().Set("Vary", "Accept-Encoding")
+	c.Response().Header().Set("ETag", etag)
+
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.HTMLBlob(http.StatusOK, buf.Bytes())
+}
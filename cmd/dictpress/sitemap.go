@@ -1,22 +1,76 @@
 package main
 
 import (
+	"compress/gzip"
+	"encoding/xml"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
+	null "gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	sitemapFormatTXT  = "txt"
+	sitemapFormatXML  = "xml"
+	sitemapFormatBoth = "both"
+
+	xhtmlNS = "http://www.w3.org/1999/xhtml"
 )
 
 var (
 	reClean = regexp.MustCompile(`\s+`)
 )
 
-// generateSitemaps generates sitemap files from database content.
-func generateSitemaps(fromLang, toLang, rootURL string, maxRows int, outputPrefix, outputDir string, getQuery *sqlx.Stmt) error {
+// urlSet and sitemapURL mirror the sitemaps.org XML schema, plus the
+// xhtml: namespace used for hreflang alternates.
+type urlSet struct {
+	XMLName    xml.Name     `xml:"urlset"`
+	Xmlns      string       `xml:"xmlns,attr"`
+	XmlnsXHTML string       `xml:"xmlns:xhtml,attr,omitempty"`
+	URLs       []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string             `xml:"loc"`
+	LastMod    string             `xml:"lastmod,omitempty"`
+	ChangeFreq string             `xml:"changefreq,omitempty"`
+	Priority   float64            `xml:"priority,omitempty"`
+	Alternates []sitemapAlternate `xml:"xhtml:link,omitempty"`
+}
+
+// sitemapAlternate is an xhtml:link hreflang alternate pointing at the same
+// headword's page in another language pair (or "x-default").
+type sitemapAlternate struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// sitemapIndex is the top-level index referencing every generated shard.
+type sitemapIndex struct {
+	XMLName xml.Name            `xml:"sitemapindex"`
+	Xmlns   string              `xml:"xmlns,attr"`
+	Maps    []sitemapIndexEntry `xml:"sitemap"`
+}
+
+type sitemapIndexEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// generateSitemaps generates sitemap files from database content, either as
+// plain URL-per-line text files, sitemaps.org XML files (optionally gzip
+// compressed), or both, depending on format. altLangs, when non-nil, maps a
+// headword to every other to-lang it has translations in (across every pair
+// being generated this run) and is used to emit xhtml:link hreflang
+// alternates in XML mode.
+func generateSitemaps(fromLang, toLang, rootURL string, maxRows int, outputPrefix, outputDir, format string, priority float64, changeFreq string, getQuery *sqlx.Stmt, altLangs map[string][]string) error {
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("error creating output directory: %w", err)
 	}
@@ -29,13 +83,17 @@ func generateSitemaps(fromLang, toLang, rootURL string, maxRows int, outputPrefi
 	defer rows.Close()
 
 	var (
-		urls  []string
+		urls  []sitemapURL
 		n     = 0
 		index = 1
+		files []string
 	)
 	for rows.Next() {
-		var word string
-		if err := rows.Scan(&word); err != nil {
+		var (
+			word      string
+			updatedAt null.Time
+		)
+		if err := rows.Scan(&word, &updatedAt); err != nil {
 			return fmt.Errorf("error scanning row: %w", err)
 		}
 
@@ -47,13 +105,29 @@ func generateSitemaps(fromLang, toLang, rootURL string, maxRows int, outputPrefi
 		if err != nil {
 			return fmt.Errorf("error joining URL paths: %w", err)
 		}
-		urls = append(urls, dictURL)
+
+		su := sitemapURL{Loc: dictURL, ChangeFreq: changeFreq, Priority: priority}
+		if updatedAt.Valid {
+			su.LastMod = updatedAt.Time.Format("2006-01-02")
+		}
+
+		if format != sitemapFormatTXT {
+			alts, err := sitemapAlternates(rootURL, fromLang, toLang, word, altLangs[word])
+			if err != nil {
+				return fmt.Errorf("error building hreflang alternates: %w", err)
+			}
+			su.Alternates = alts
+		}
+
+		urls = append(urls, su)
 
 		// Write sitemap if we've reached the maximum URLs per file.
 		if len(urls) >= maxRows {
-			if err := writeSitemap(urls, index, outputPrefix, outputDir); err != nil {
+			f, err := writeSitemap(urls, index, outputPrefix, outputDir, format)
+			if err != nil {
 				return err
 			}
+			files = append(files, f)
 
 			urls = urls[:0]
 			index++
@@ -63,42 +137,287 @@ func generateSitemaps(fromLang, toLang, rootURL string, maxRows int, outputPrefi
 
 	// Write remaining URLs if any.
 	if len(urls) > 0 {
-		if err := writeSitemap(urls, index, outputPrefix, outputDir); err != nil {
+		f, err := writeSitemap(urls, index, outputPrefix, outputDir, format)
+		if err != nil {
 			return err
 		}
+		files = append(files, f)
 	}
 
 	if err := rows.Err(); err != nil {
 		return fmt.Errorf("error iterating rows: %w", err)
 	}
 
+	// Write the top-level sitemap index for XML mode so search engines only
+	// have to discover a single URL.
+	if format == sitemapFormatXML || format == sitemapFormatBoth {
+		if err := writeSitemapIndex(files, rootURL, outputDir); err != nil {
+			return err
+		}
+	}
+
 	lo.Printf("generated %d URLs in %d sitemap files\n", n, index)
 
 	return nil
 }
 
-// writeSitemap writes a slice of URLs to a sitemap file.
-func writeSitemap(urls []string, index int, outputPrefix, outputDir string) error {
-	filepath := filepath.Join(outputDir, fmt.Sprintf("%s%d.txt", outputPrefix, index))
+// sitemapAlternates builds the xhtml:link hreflang alternates for word: one
+// "alternate" entry per other to-lang it has translations in (from
+// otherToLangs), plus an "x-default" pointing at the lexicographically
+// first to-lang among toLang and otherToLangs, a deterministic stand-in for
+// "this instance's default translation direction for word".
+func sitemapAlternates(rootURL, fromLang, toLang, word string, otherToLangs []string) ([]sitemapAlternate, error) {
+	all := append([]string{toLang}, otherToLangs...)
+	sort.Strings(all)
+
+	var out []sitemapAlternate
+	for _, lang := range otherToLangs {
+		href, err := url.JoinPath(rootURL, "dictionary", fromLang, lang, word)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sitemapAlternate{Rel: "alternate", Hreflang: lang, Href: href})
+	}
+
+	defaultHref, err := url.JoinPath(rootURL, "dictionary", fromLang, all[0], word)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, sitemapAlternate{Rel: "alternate", Hreflang: "x-default", Href: defaultHref})
+
+	return out, nil
+}
+
+// writeSitemap writes a slice of URLs to a sitemap file in plain-text,
+// sitemaps.org XML (gzipped), or both, returning the file name written
+// (without directory) for use in the sitemap index. In "both" mode, the
+// gzipped XML name is returned since that's what the index references.
+func writeSitemap(urls []sitemapURL, index int, outputPrefix, outputDir, format string) (string, error) {
+	if format == sitemapFormatTXT {
+		return writeSitemapTXT(urls, index, outputPrefix, outputDir)
+	}
+
+	if format == sitemapFormatBoth {
+		if _, err := writeSitemapTXT(urls, index, outputPrefix, outputDir); err != nil {
+			return "", err
+		}
+	}
+
+	return writeSitemapXML(urls, index, outputPrefix, outputDir)
+}
+
+func writeSitemapTXT(urls []sitemapURL, index int, outputPrefix, outputDir string) (string, error) {
+	name := fmt.Sprintf("%s%d.txt", outputPrefix, index)
+	path := filepath.Join(outputDir, name)
 
-	lo.Printf("writing to %s\n", filepath)
-	f, err := os.Create(filepath)
+	lo.Printf("writing to %s\n", path)
+	f, err := os.Create(path)
 	if err != nil {
-		return fmt.Errorf("error creating sitemap file: %w", err)
+		return "", fmt.Errorf("error creating sitemap file: %w", err)
 	}
 	defer f.Close()
 
 	for _, u := range urls {
-		if _, err := f.WriteString(u + "\n"); err != nil {
-			return fmt.Errorf("error writing URL to sitemap: %w", err)
+		if _, err := f.WriteString(u.Loc + "\n"); err != nil {
+			return "", fmt.Errorf("error writing URL to sitemap: %w", err)
 		}
 	}
 
-	return nil
+	return name, nil
+}
+
+func writeSitemapXML(urls []sitemapURL, index int, outputPrefix, outputDir string) (string, error) {
+	name := fmt.Sprintf("%s%d.xml.gz", outputPrefix, index)
+	path := filepath.Join(outputDir, name)
+
+	lo.Printf("writing to %s\n", path)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("error creating sitemap file: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+
+	if _, err := gz.Write([]byte(xml.Header)); err != nil {
+		return "", fmt.Errorf("error writing sitemap XML: %w", err)
+	}
+
+	set := urlSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9", URLs: urls}
+	for _, u := range urls {
+		if len(u.Alternates) > 0 {
+			set.XmlnsXHTML = xhtmlNS
+			break
+		}
+	}
+	enc := xml.NewEncoder(gz)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return "", fmt.Errorf("error encoding sitemap XML: %w", err)
+	}
+
+	return name, nil
 }
 
-// generateRobotsTxt generates a robots.txt file with sitemap references.
-func generateRobotsTxt(sitemapURL string, outputDir string) error {
+// writeSitemapIndex writes sitemap_index.xml referencing every generated shard.
+func writeSitemapIndex(files []string, rootURL, outputDir string) error {
+	path := filepath.Join(outputDir, "sitemap_index.xml")
+
+	lo.Printf("writing to %s\n", path)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating sitemap index: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(xml.Header); err != nil {
+		return fmt.Errorf("error writing sitemap index: %w", err)
+	}
+
+	idx := sitemapIndex{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, name := range files {
+		loc, err := url.JoinPath(rootURL, name)
+		if err != nil {
+			return fmt.Errorf("error joining URL paths: %w", err)
+		}
+		idx.Maps = append(idx.Maps, sitemapIndexEntry{Loc: loc})
+	}
+
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	return enc.Encode(idx)
+}
+
+// resolveSitemapPairs resolves the from:to language pairs to generate
+// sitemaps for: pairsFlag (eg: "en:fr,en:de,fr:en") wins if set, then the
+// legacy single from-lang/to-lang flags, and failing both, every pair that
+// currently has at least one enabled entry with an enabled translation.
+func resolveSitemapPairs(db *sqlx.DB, pairsFlag, fromLang, toLang string) ([][2]string, error) {
+	if pairsFlag != "" {
+		return parseSitemapPairs(pairsFlag)
+	}
+
+	if fromLang != "" && toLang != "" {
+		return [][2]string{{fromLang, toLang}}, nil
+	}
+
+	return discoverSitemapPairs(db)
+}
+
+// parseSitemapPairs parses a "from:to,from:to" --pairs flag value.
+func parseSitemapPairs(s string) ([][2]string, error) {
+	var out [][2]string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		parts := strings.SplitN(p, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid --pairs entry '%s', expected from:to", p)
+		}
+		out = append(out, [2]string{parts[0], parts[1]})
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("--pairs was set but contained no valid from:to entries")
+	}
+
+	return out, nil
+}
+
+// discoverSitemapPairs returns every distinct (from-lang, to-lang) combination
+// that currently has at least one enabled entry with an enabled translation
+// relation, so `sitemap` can be run with no flags on an existing database.
+func discoverSitemapPairs(db *sqlx.DB) ([][2]string, error) {
+	rows, err := db.Queryx(`
+		SELECT DISTINCT e1.lang, e2.lang
+		FROM relations r
+		JOIN entries e1 ON e1.id = r.from_id
+		JOIN entries e2 ON e2.id = r.to_id
+		WHERE r.status = 'enabled' AND e1.status = 'enabled' AND e2.status = 'enabled'
+		ORDER BY 1, 2`)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering language pairs: %w", err)
+	}
+	defer rows.Close()
+
+	var out [][2]string
+	for rows.Next() {
+		var from, to string
+		if err := rows.Scan(&from, &to); err != nil {
+			return nil, fmt.Errorf("error scanning language pair: %w", err)
+		}
+		out = append(out, [2]string{from, to})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating language pairs: %w", err)
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no language pairs found in the database; pass --pairs or --from-lang/--to-lang")
+	}
+
+	return out, nil
+}
+
+// getEntryAltLangs returns, for every headword in fromLang, the distinct set
+// of to-langs it has enabled translation relations in. Keys are normalized
+// the same way generateSitemaps normalizes a word before using it in a URL,
+// so the two can be looked up against each other directly.
+func getEntryAltLangs(db *sqlx.DB, fromLang string) (map[string][]string, error) {
+	rows, err := db.Queryx(`
+		SELECT e1.content, e2.lang
+		FROM entries e1
+		JOIN relations r ON r.from_id = e1.id
+		JOIN entries e2 ON e2.id = r.to_id
+		WHERE e1.lang = $1 AND e1.status = 'enabled' AND r.status = 'enabled' AND e2.status = 'enabled'`,
+		fromLang)
+	if err != nil {
+		return nil, fmt.Errorf("error querying entry translations: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[string][]string)
+	seen := make(map[string]map[string]bool)
+	for rows.Next() {
+		var word, toLang string
+		if err := rows.Scan(&word, &toLang); err != nil {
+			return nil, fmt.Errorf("error scanning entry translation: %w", err)
+		}
+
+		word = sitemapWordKey(word)
+		if seen[word] == nil {
+			seen[word] = make(map[string]bool)
+		}
+		if seen[word][toLang] {
+			continue
+		}
+		seen[word][toLang] = true
+		out[word] = append(out[word], toLang)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entry translations: %w", err)
+	}
+
+	return out, nil
+}
+
+// sitemapWordKey normalizes a headword the same way generateSitemaps does
+// before using it in a dictionary URL, so words read from different queries
+// can be matched against each other.
+func sitemapWordKey(word string) string {
+	word = strings.ToLower(strings.TrimSpace(word))
+	return reClean.ReplaceAllString(word, "+")
+}
+
+// generateRobotsTxt generates a robots.txt file with sitemap references. In
+// XML mode, it references the single sitemap index rather than every shard.
+func generateRobotsTxt(sitemapURL, outputDir, format string) error {
 	robotsPath := filepath.Join(outputDir, "robots.txt")
 
 	lo.Printf("writing to %s\n", robotsPath)
@@ -118,7 +437,18 @@ Allow: /
 		return fmt.Errorf("error writing robots.txt content: %w", err)
 	}
 
-	// Add sitemap references.
+	if format == sitemapFormatXML || format == sitemapFormatBoth {
+		loc, err := url.JoinPath(sitemapURL, "sitemap_index.xml")
+		if err != nil {
+			return fmt.Errorf("error joining URL paths: %w", err)
+		}
+		if _, err := f.WriteString(fmt.Sprintf("Sitemap: %s\n", loc)); err != nil {
+			return fmt.Errorf("error writing sitemap URL: %w", err)
+		}
+		return nil
+	}
+
+	// Add sitemap references for every shard.
 	files, err := os.ReadDir(outputDir)
 	if err != nil {
 		return fmt.Errorf("error reading output directory: %w", err)
@@ -126,11 +456,11 @@ Allow: /
 
 	for _, file := range files {
 		if !file.IsDir() && file.Name() != "robots.txt" && strings.Contains(file.Name(), ".txt") {
-			sitemapURL, err := url.JoinPath(sitemapURL, file.Name())
+			loc, err := url.JoinPath(sitemapURL, file.Name())
 			if err != nil {
 				return fmt.Errorf("error joining URL paths: %w", err)
 			}
-			if _, err := f.WriteString(fmt.Sprintf("Sitemap: %s\n", sitemapURL)); err != nil {
+			if _, err := f.WriteString(fmt.Sprintf("Sitemap: %s\n", loc)); err != nil {
 				return fmt.Errorf("error writing sitemap URL: %w", err)
 			}
 		}
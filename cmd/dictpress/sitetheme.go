@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+
+	"github.com/knadh/dictpress/internal/data"
+	"github.com/knadh/go-i18n"
+	"github.com/knadh/koanf/parsers/toml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/paginator"
+)
+
+// isMultiLangSite reports whether rootPath is a directory of per-language
+// theme subdirectories (eg: themes/de/, themes/en/, one per source
+// language), rather than a single theme directory. It's multi-language iff
+// rootPath itself has no *.html templates but at least one subdirectory
+// named after a configured source language does.
+func isMultiLangSite(rootPath string, langs data.LangMap) bool {
+	if hasHTML(rootPath) {
+		return false
+	}
+
+	for l := range langs {
+		if hasHTML(filepath.Join(rootPath, l)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasHTML(dir string) bool {
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	return err == nil && len(files) > 0
+}
+
+// siteThemeOverrides holds the results.*/glossary.* paginator overrides a
+// per-language theme directory may set in its own theme.toml. A zero field
+// leaves the instance-wide results.*/glossary.* config untouched.
+type siteThemeOverrides struct {
+	ResultsDefaultPerPage  int
+	ResultsMaxPerPage      int
+	GlossaryDefaultPerPage int
+	GlossaryMaxPerPage     int
+}
+
+// loadSiteThemeOverrides reads dir/theme.toml, returning a zero-value
+// siteThemeOverrides (ie: no overrides) if the language's theme doesn't set one.
+func loadSiteThemeOverrides(dir string) (siteThemeOverrides, error) {
+	var o siteThemeOverrides
+
+	path := filepath.Join(dir, "theme.toml")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return o, nil
+	}
+
+	k := koanf.New(".")
+	if err := k.Load(file.Provider(path), toml.Parser()); err != nil {
+		return o, err
+	}
+
+	o.ResultsDefaultPerPage = k.Int("results.default_per_page")
+	o.ResultsMaxPerPage = k.Int("results.max_per_page")
+	o.GlossaryDefaultPerPage = k.Int("glossary.default_per_page")
+	o.GlossaryMaxPerPage = k.Int("glossary.max_per_page")
+
+	return o, nil
+}
+
+// overridePaginator returns a paginator built from base, with DefaultPerPage
+// and MaxPerPage replaced by defaultPerPage/maxPerPage when they're set (>0).
+func overridePaginator(base paginator.Opt, defaultPerPage, maxPerPage int) *paginator.Paginator {
+	o := base
+	if defaultPerPage > 0 {
+		o.DefaultPerPage = defaultPerPage
+	}
+	if maxPerPage > 0 {
+		o.MaxPerPage = maxPerPage
+	}
+	return paginator.New(o)
+}
+
+// siteThemeSet is every per-language theme loaded from a multi-language
+// theme directory (see isMultiLangSite), plus the language its theme also
+// doubles as the instance's default.
+type siteThemeSet struct {
+	tpls        map[string]*template.Template
+	pageTpls    map[string]map[string]*template.Template
+	i18ns       map[string]*i18n.I18n
+	resultsPgs  map[string]*paginator.Paginator
+	glossaryPgs map[string]*paginator.Paginator
+	defaultLang string
+}
+
+// loadSiteThemes loads every language subdirectory of rootPath that has its
+// own *.html templates, each with its own templates, lang.json, static
+// assets and optional theme.toml pagination overrides. defaultLangHint
+// (site.default_lang) picks which loaded language also backs the instance's
+// default theme; if it has no theme of its own, an arbitrary loaded
+// language is used instead.
+func loadSiteThemes(rootPath string, loadPages bool, langs data.LangMap, defaultLangHint string, resultsOpt, glossaryOpt paginator.Opt) (siteThemeSet, error) {
+	set := siteThemeSet{
+		tpls:        make(map[string]*template.Template),
+		pageTpls:    make(map[string]map[string]*template.Template),
+		i18ns:       make(map[string]*i18n.I18n),
+		resultsPgs:  make(map[string]*paginator.Paginator),
+		glossaryPgs: make(map[string]*paginator.Paginator),
+	}
+
+	for l := range langs {
+		dir := filepath.Join(rootPath, l)
+		if !hasHTML(dir) {
+			continue
+		}
+
+		theme, pages, err := loadSite(dir, loadPages)
+		if err != nil {
+			return siteThemeSet{}, fmt.Errorf("error loading theme for language '%s': %v", l, err)
+		}
+		set.tpls[l] = theme
+		set.pageTpls[l] = pages
+
+		i, err := loadI18n(filepath.Join(dir, "lang.json"))
+		if err != nil {
+			return siteThemeSet{}, fmt.Errorf("error loading lang.json for language '%s': %v", l, err)
+		}
+		set.i18ns[l] = i
+
+		ov, err := loadSiteThemeOverrides(dir)
+		if err != nil {
+			return siteThemeSet{}, fmt.Errorf("error loading theme.toml for language '%s': %v", l, err)
+		}
+		set.resultsPgs[l] = overridePaginator(resultsOpt, ov.ResultsDefaultPerPage, ov.ResultsMaxPerPage)
+		set.glossaryPgs[l] = overridePaginator(glossaryOpt, ov.GlossaryDefaultPerPage, ov.GlossaryMaxPerPage)
+	}
+
+	if len(set.tpls) == 0 {
+		return siteThemeSet{}, fmt.Errorf("no per-language theme found under %s", rootPath)
+	}
+
+	set.defaultLang = defaultLangHint
+	if _, ok := set.tpls[set.defaultLang]; !ok {
+		for l := range set.tpls {
+			set.defaultLang = l
+			break
+		}
+	}
+
+	return set, nil
+}
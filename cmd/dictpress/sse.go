@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// eventHeartbeatEvery is how often a comment line is sent on an idle SSE
+// connection to keep intermediate proxies from closing it.
+const eventHeartbeatEvery = 25 * time.Second
+
+// sseEvent is implemented by an SSE event payload type (adminEvent,
+// entryEvent), giving sseHub a way to stamp a monotonic ID and timestamp
+// onto a new event, and read its ID back for Last-Event-ID replay, without
+// knowing anything else about the type's fields.
+type sseEvent[T any] interface {
+	withID(id int64, at time.Time) T
+	eventID() int64
+}
+
+// sseHub fans out events of type T to every subscribed SSE client and
+// replays recently missed ones to a client that reconnects with
+// Last-Event-ID. It backs both the admin notification stream (adminEvent,
+// via eventHub) and the public entry-change stream (entryEvent, via
+// entryEventHub): same ring buffer, subscribe/unsubscribe and
+// slow-consumer-drop semantics, so the two streams share one implementation
+// instead of maintaining independent copies of it.
+type sseHub[T sseEvent[T]] struct {
+	ringSize int
+
+	mu     sync.Mutex
+	subs   map[chan T]struct{}
+	ring   []T
+	nextID int64
+}
+
+// newSSEHub returns an empty sseHub that retains up to ringSize events for
+// Last-Event-ID replay.
+func newSSEHub[T sseEvent[T]](ringSize int) *sseHub[T] {
+	return &sseHub[T]{ringSize: ringSize, subs: make(map[chan T]struct{})}
+}
+
+// publish stamps e with the next ID and the current time, fans it out to
+// every current subscriber, and appends it to the replay ring buffer.
+func (h *sseHub[T]) publish(e T) T {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	e = e.withID(h.nextID, time.Now())
+
+	h.ring = append(h.ring, e)
+	if len(h.ring) > h.ringSize {
+		h.ring = h.ring[len(h.ring)-h.ringSize:]
+	}
+
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+			// Slow subscriber. Drop the event rather than block the publisher;
+			// it'll catch up on reconnect via Last-Event-ID replay.
+		}
+	}
+
+	return e
+}
+
+// Subscribe registers a new client, returning its event channel and any
+// buffered events with an ID greater than lastID (0 for none) for
+// Last-Event-ID replay.
+func (h *sseHub[T]) Subscribe(lastID int64) (chan T, []T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan T, 16)
+	h.subs[ch] = struct{}{}
+
+	var replay []T
+	if lastID > 0 {
+		for _, e := range h.ring {
+			if e.eventID() > lastID {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	return ch, replay
+}
+
+// Unsubscribe removes and closes a client's event channel.
+func (h *sseHub[T]) Unsubscribe(ch chan T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[ch]; !ok {
+		return
+	}
+	delete(h.subs, ch)
+	close(ch)
+}
+
+// streamSSE drives the HTTP side of an SSE subscription common to
+// HandleAdminEvents and HandleEntryEvents: parse Last-Event-ID, subscribe to
+// hub, replay missed events matching match, then stream new ones until the
+// client disconnects, sending a heartbeat comment on an idle connection to
+// keep intermediate proxies from closing it. match may be nil to stream
+// every event unfiltered.
+func streamSSE[T sseEvent[T]](c echo.Context, hub *sseHub[T], match func(T) bool) error {
+	var lastID int64
+	if v := c.Request().Header.Get("Last-Event-ID"); v != "" {
+		lastID, _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	ch, replay := hub.Subscribe(lastID)
+	defer hub.Unsubscribe(ch)
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, e := range replay {
+		if match != nil && !match(e) {
+			continue
+		}
+		if err := writeSSEEvent(w, e); err != nil {
+			return nil
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatEvery)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+
+		case e, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if match != nil && !match(e) {
+				continue
+			}
+			if err := writeSSEEvent(w, e); err != nil {
+				return nil
+			}
+			w.Flush()
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			w.Flush()
+		}
+	}
+}
+
+// writeSSEEvent writes e to w in SSE wire format.
+func writeSSEEvent[T sseEvent[T]](w *echo.Response, e T) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.eventID(), b)
+	return err
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/knadh/dictpress/internal/data"
@@ -10,8 +11,9 @@ import (
 	"github.com/lib/pq"
 )
 
-// newSubmission is an entry and relations submitted by the public for review.
-// These are recorded in the entries and relations table with status=pending.
+// newSubmission is an entry and relations submitted by the public for review,
+// bound from the legacy form-encoded `/submit` page and `/api/submissions`
+// request. It's translated into a submissionRequest before processing.
 type newSubmission struct {
 	EntryLang    string `form:"entry_lang"`
 	EntryContent string `form:"entry_content"`
@@ -21,6 +23,126 @@ type newSubmission struct {
 	RelationLang    []string `form:"relation_lang"`
 	RelationContent []string `form:"relation_content"`
 	RelationTypes   []string `form:"relation_type"`
+
+	// Per-relation phones/notes/tags. Each, if present, must have the same
+	// number of values as RelationLang - see validate().
+	RelationPhones []string `form:"relation_phones"`
+	RelationNotes  []string `form:"relation_notes"`
+	RelationTags   []string `form:"relation_tags"`
+}
+
+// validate checks that every populated parallel per-relation slice has the
+// same length as RelationLang, so toRequest() never silently misaligns one
+// relation's phones/notes/tags with another's.
+func (s newSubmission) validate() error {
+	ln := len(s.RelationLang)
+
+	if len(s.RelationContent) != ln || len(s.RelationTypes) != ln {
+		return fmt.Errorf("`relation_content` and `relation_type` must have the same number of values as `relation_lang`")
+	}
+	if len(s.RelationPhones) > 0 && len(s.RelationPhones) != ln {
+		return fmt.Errorf("`relation_phones` must have the same number of values as `relation_lang`")
+	}
+	if len(s.RelationNotes) > 0 && len(s.RelationNotes) != ln {
+		return fmt.Errorf("`relation_notes` must have the same number of values as `relation_lang`")
+	}
+	if len(s.RelationTags) > 0 && len(s.RelationTags) != ln {
+		return fmt.Errorf("`relation_tags` must have the same number of values as `relation_lang`")
+	}
+
+	return nil
+}
+
+// toRequest converts the form-encoded newSubmission into the typed
+// submissionRequest used internally by both the form and JSON APIs.
+func (s newSubmission) toRequest() submissionRequest {
+	req := submissionRequest{
+		EntryLang:    s.EntryLang,
+		EntryContent: s.EntryContent,
+		EntryPhones:  s.EntryPhones,
+		EntryNotes:   s.EntryNotes,
+	}
+
+	for i := range s.RelationLang {
+		req.Relations = append(req.Relations, submissionRelationRequest{
+			Lang:    valueAt(s.RelationLang, i),
+			Content: valueAt(s.RelationContent, i),
+			Type:    valueAt(s.RelationTypes, i),
+			Phones:  valueAt(s.RelationPhones, i),
+			Notes:   valueAt(s.RelationNotes, i),
+			Tags:    splitCommaList(valueAt(s.RelationTags, i)),
+		})
+	}
+
+	return req
+}
+
+// valueAt returns s[i], or "" if i is out of bounds.
+func valueAt(s []string, i int) string {
+	if i < 0 || i >= len(s) {
+		return ""
+	}
+	return s[i]
+}
+
+// splitCommaList splits a comma separated list (phones, tags) into a
+// trimmed, non-empty slice.
+func splitCommaList(s string) []string {
+	out := []string{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if len(p) > 0 {
+			out = append(out, p)
+		}
+	}
+
+	return out
+}
+
+// submissionRequest is the typed entry+relations payload accepted by both the
+// versioned JSON submissions API and (translated from form fields) the
+// legacy `/submit` page and `/api/submissions` endpoint.
+type submissionRequest struct {
+	EntryLang    string `json:"entry_lang"`
+	EntryContent string `json:"entry_content"`
+	EntryPhones  string `json:"entry_phones"`
+	EntryNotes   string `json:"entry_notes"`
+
+	Relations []submissionRelationRequest `json:"relations"`
+}
+
+// submissionRelationRequest is a single related entry submitted alongside
+// the main entry, each with its own phones/notes/tags.
+type submissionRelationRequest struct {
+	Lang    string   `json:"lang"`
+	Content string   `json:"content"`
+	Type    string   `json:"type"`
+	Phones  string   `json:"phones"`
+	Notes   string   `json:"notes"`
+	Tags    []string `json:"tags"`
+}
+
+// submissionResult is the typed response returned on a successful submission.
+type submissionResult struct {
+	FromID    int                        `json:"from_id"`
+	Relations []submissionRelationResult `json:"relations"`
+	Status    string                     `json:"status"`
+}
+
+// submissionRelationResult identifies the entry and relation created for
+// each submitted submissionRelationRequest.
+type submissionRelationResult struct {
+	ToID       int `json:"to_id"`
+	RelationID int `json:"relation_id"`
+}
+
+// submissionValidationError describes a single invalid field in a
+// submissionRequest, for clients (browser extensions, mobile apps) that
+// submit programmatically against the JSON API.
+type submissionValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 // changeSubmission is a comment for change submitted by the public that can be
@@ -31,117 +153,287 @@ type changeSubmission struct {
 	Comments string `json:"comments"`
 }
 
-// HandleNewSubmission inserts a new dictionary entry suggestion from the public
-// in the `pending` state for review.
+// HandleNewSubmission inserts a new dictionary entry suggestion from the
+// public in the `pending` state for review. It accepts the legacy
+// form-encoded payload (used by `/submit` and existing API clients) and
+// responds with the same typed submissionResult as the v1 JSON API.
 func (a *App) HandleNewSubmission(c echo.Context) error {
 	var s newSubmission
 	if err := c.Bind(&s); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			fmt.Sprintf("error parsing request: %v", err))
 	}
+	if err := s.validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
-	s.EntryContent = strings.TrimSpace(s.EntryContent)
-	if len(s.EntryContent) == 0 {
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid `entry_content`.")
+	res, verrs, err := a.processSubmission(c, s.toRequest())
+	if err != nil {
+		return err
+	}
+	if len(verrs) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, verrs[0].Message)
 	}
 
-	// Validate input.
-	ln := len(s.RelationLang)
-	if ln == 0 || ln != len(s.RelationContent) || ln != len(s.RelationTypes) {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid submission fields.")
+	return c.JSON(http.StatusOK, okResp{res})
+}
+
+// HandleNewSubmissionV1 is the versioned JSON submissions API
+// (`/api/v1/submissions`). It accepts a typed submissionRequest and responds
+// with either a typed submissionResult or a submissionValidationError list,
+// for clients (browser extensions, mobile apps) that submit programmatically.
+func (a *App) HandleNewSubmissionV1(c echo.Context) error {
+	var req submissionRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("error parsing request: %v", err))
+	}
+
+	res, verrs, err := a.processSubmission(c, req)
+	if err != nil {
+		return err
+	}
+	if len(verrs) > 0 {
+		return c.JSON(http.StatusBadRequest, verrs)
 	}
 
-	// Validate language and type enums.
+	return c.JSON(http.StatusOK, okResp{res})
+}
+
+// HandleSubmissionPage renders the HTML new-entry submission page. On POST,
+// it calls into the same processSubmission() the JSON APIs use, rather than
+// duplicating the anti-abuse pipeline, validation and insertion logic.
+func (a *App) HandleSubmissionPage(c echo.Context) error {
+	if c.Request().Method != http.MethodPost {
+		return c.Render(http.StatusOK, "submit-entry", pageTpl{
+			Title: "Submit a new entry",
+		})
+	}
+
+	var s newSubmission
+	if err := c.Bind(&s); err != nil {
+		return c.Render(http.StatusBadRequest, "message", pageTpl{
+			Title:       "Error",
+			Heading:     "Error",
+			Description: fmt.Sprintf("error parsing request: %v", err),
+		})
+	}
+	if err := s.validate(); err != nil {
+		return c.Render(http.StatusBadRequest, "message", pageTpl{
+			Title:       "Error",
+			Heading:     "Error",
+			Description: err.Error(),
+		})
+	}
+
+	_, verrs, err := a.processSubmission(c, s.toRequest())
+	if err != nil {
+		e := err.(*echo.HTTPError)
+		return c.Render(e.Code, "message", pageTpl{
+			Title:       "Error",
+			Heading:     "Error",
+			Description: fmt.Sprintf("%s", e.Message),
+		})
+	}
+	if len(verrs) > 0 {
+		return c.Render(http.StatusBadRequest, "message", pageTpl{
+			Title:       "Error",
+			Heading:     "Error",
+			Description: verrs[0].Message,
+		})
+	}
+
+	return c.Render(http.StatusOK, "message", pageTpl{
+		Title:       "Submitted",
+		Heading:     "Submitted",
+		Description: "Your entry has been submitted for review.",
+	})
+}
+
+// recordSubmissionAttempt audits a throttled or rejected public submission
+// attempt. Failures to record are only logged - they must never cause the
+// rejection itself to fail.
+func (a *App) recordSubmissionAttempt(ip, lang, reason string) {
+	if err := a.data.InsertSubmissionAttempt(ip, lang, reason); err != nil {
+		a.lo.Printf("error recording submission attempt: %v", err)
+	}
+}
+
+// processSubmission runs a submissionRequest through the anti-abuse pipeline
+// (honeypot, rate limiting, CAPTCHA, blocklist, spam scoring), validates it,
+// and on success inserts the entry and its relations as pending. It's shared
+// by the form-encoded and v1 JSON submission handlers.
+//
+// A non-nil error is a pipeline rejection or internal error, already wrapped
+// as an *echo.HTTPError. A non-empty submissionValidationError slice means
+// the request reached validation but failed it; err is nil in that case.
+func (a *App) processSubmission(c echo.Context, s submissionRequest) (*submissionResult, []submissionValidationError, error) {
+	// Honeypot: a hidden form field that's invisible to humans but that
+	// bots tend to fill in. Reject outright without hitting the DB.
+	if a.consts.ModerationHoneypotField != "" && c.FormValue(a.consts.ModerationHoneypotField) != "" {
+		a.recordSubmissionAttempt(c.RealIP(), s.EntryLang, "honeypot")
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "Submission rejected.")
+	}
+
+	// Rate limit floods of submissions from a single IP or in a single
+	// language before doing any other work.
+	if a.submissionLimiter != nil && !a.submissionLimiter.Allow(c.RealIP(), s.EntryLang) {
+		a.recordSubmissionAttempt(c.RealIP(), s.EntryLang, "rate_limit")
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(a.submissionLimiter.RetryAfter().Seconds())))
+		return nil, nil, echo.NewHTTPError(http.StatusTooManyRequests, "Too many submissions. Please try again later.")
+	}
+
+	// Verify the CAPTCHA challenge, if one is configured.
+	if a.captchaVerifier != nil {
+		ok, err := a.captchaVerifier.Verify(c.FormValue("captcha_response"), c.RealIP())
+		if err != nil {
+			a.lo.Printf("error verifying captcha: %v", err)
+			return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "Error verifying CAPTCHA.")
+		}
+		if !ok {
+			a.recordSubmissionAttempt(c.RealIP(), s.EntryLang, "captcha")
+			return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "CAPTCHA verification failed.")
+		}
+	}
+
+	s.EntryContent = strings.TrimSpace(s.EntryContent)
+
+	if matchesBlocklist(s.EntryContent, a.blocklist) {
+		a.recordSubmissionAttempt(c.RealIP(), s.EntryLang, "blocklist")
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "Submission rejected.")
+	}
+
+	// Validate input, collecting every field issue instead of bailing out on
+	// the first one so JSON API clients can surface them all at once.
+	var verrs []submissionValidationError
+
+	if len(s.EntryContent) == 0 {
+		verrs = append(verrs, submissionValidationError{"entry_content", "required", "`entry_content` is required."})
+	}
 	if _, ok := a.data.Langs[s.EntryLang]; !ok {
-		return echo.NewHTTPError(http.StatusBadRequest, "Unknown `entry_lang`.")
+		verrs = append(verrs, submissionValidationError{"entry_lang", "unknown_lang", "Unknown `entry_lang`."})
 	}
-	for i := range s.RelationLang {
-		lang, ok := a.data.Langs[s.RelationLang[i]]
+	if len(s.Relations) == 0 {
+		verrs = append(verrs, submissionValidationError{"relations", "required", "At least one relation is required."})
+	}
+
+	for i := range s.Relations {
+		r := &s.Relations[i]
+		field := fmt.Sprintf("relations[%d]", i)
+
+		lang, ok := a.data.Langs[r.Lang]
 		if !ok {
-			return echo.NewHTTPError(http.StatusBadRequest, "Unknown `relation_lang`.")
+			verrs = append(verrs, submissionValidationError{field + ".lang", "unknown_lang", "Unknown relation `lang`."})
+			continue
 		}
 
-		if _, ok := lang.Types[s.RelationTypes[i]]; !ok {
-			return echo.NewHTTPError(http.StatusBadRequest, "Unknown `relation_type`.")
+		if _, ok := lang.Types[r.Type]; !ok {
+			verrs = append(verrs, submissionValidationError{field + ".type", "unknown_type", "Unknown relation `type`."})
 		}
 
-		s.RelationContent[i] = strings.TrimSpace(s.RelationContent[i])
-		if len(s.RelationContent[i]) == 0 {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid `entry_content`.")
+		r.Content = strings.TrimSpace(r.Content)
+		if len(r.Content) == 0 {
+			verrs = append(verrs, submissionValidationError{field + ".content", "required", "Relation `content` is required."})
 		}
 	}
 
-	// Check if the main entry and the relational entries already exist.
-	// If they exist, no new entries are inserted, only relations.
-
-	// Insert the main entry.
-	phones := []string{}
-	for _, p := range strings.Split(s.EntryPhones, ",") {
-		p = strings.TrimSpace(p)
-		if len(p) > 0 {
-			phones = append(phones, p)
-		}
+	if len(verrs) > 0 {
+		return nil, verrs, nil
 	}
 
+	// Insert the main entry.
 	e := data.Entry{
 		Lang:    s.EntryLang,
 		Initial: strings.ToUpper(string(s.EntryContent[0])),
-		Content: pq.StringArray([]string{s.EntryContent}),
-		Phones:  pq.StringArray(phones),
+		Content: s.EntryContent,
+		Phones:  pq.StringArray(splitCommaList(s.EntryPhones)),
+		Notes:   s.EntryNotes,
 		Tags:    pq.StringArray{},
 		Status:  data.StatusPending,
 	}
 
-	// Save the main entry.
+	// Score the submission for spam and reject it outright if it's above
+	// the configured threshold, without ever writing it to the DB.
+	if a.spamScorer != nil {
+		score, err := a.spamScorer.Score(e)
+		if err != nil {
+			a.lo.Printf("error scoring submission for spam: %v", err)
+		} else {
+			e.SpamScore = score
+		}
+
+		if e.SpamScore >= a.consts.ModerationSpamAutoRejectScore {
+			return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "This submission looks like spam and was rejected.")
+		}
+	}
+
 	fromID, err := a.data.InsertSubmissionEntry(e)
 	if err != nil {
 		a.lo.Printf("error inserting submission entry: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error saving entry.")
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "Error saving entry.")
 	}
 
-	// Insert relations.
-	for i := range s.RelationLang {
-		phones := []string{}
-		for _, p := range strings.Split(s.EntryPhones, ",") {
-			p = strings.TrimSpace(p)
-			if len(p) > 0 {
-				phones = append(phones, p)
-			}
+	// Trusted IPs (eg: known partner integrations) with a low enough spam
+	// score skip the moderation queue entirely.
+	if isTrustedIP(c.RealIP(), a.consts.ModerationTrustedIPs) && e.SpamScore < a.consts.ModerationSpamAutoApproveScore {
+		if err := a.data.ApproveSubmission(fromID); err != nil {
+			a.lo.Printf("error auto-approving trusted submission: %v", err)
 		}
+	}
 
+	// Insert relations.
+	res := &submissionResult{FromID: fromID, Status: data.StatusPending}
+	for _, r := range s.Relations {
 		toID, err := a.data.InsertSubmissionEntry(data.Entry{
-			Lang:    s.RelationLang[i],
-			Initial: strings.ToUpper(string(s.RelationContent[i][0])),
-			Content: pq.StringArray([]string{s.RelationContent[i]}),
-			Phones:  pq.StringArray(phones),
-			Tags:    pq.StringArray{},
+			Lang:    r.Lang,
+			Initial: strings.ToUpper(string(r.Content[0])),
+			Content: r.Content,
+			Phones:  pq.StringArray(splitCommaList(r.Phones)),
+			Notes:   r.Notes,
+			Tags:    pq.StringArray(r.Tags),
 			Status:  data.StatusPending,
 		})
 		if err != nil {
 			a.lo.Printf("error inserting submission definition: %v", err)
-			return echo.NewHTTPError(http.StatusInternalServerError,
+			return nil, nil, echo.NewHTTPError(http.StatusInternalServerError,
 				fmt.Sprintf("Error saving definition: %v", err))
 		}
 
 		rel := data.Relation{
-			Types:  pq.StringArray{s.RelationTypes[i]},
+			Types:  pq.StringArray{r.Type},
 			Tags:   pq.StringArray{},
 			Status: data.StatusPending,
 		}
-		if _, err := a.data.InsertSubmissionRelation(fromID, toID, rel); err != nil {
+		relID, err := a.data.InsertSubmissionRelation(fromID, toID, rel)
+		if err != nil {
 			a.lo.Printf("error inserting submission relation: %v", err)
-			return echo.NewHTTPError(http.StatusInternalServerError,
+			return nil, nil, echo.NewHTTPError(http.StatusInternalServerError,
 				fmt.Sprintf("Error saving relation.%v", err))
 		}
+
+		res.Relations = append(res.Relations, submissionRelationResult{ToID: toID, RelationID: relID})
 	}
 
-	return nil
+	if a.events != nil {
+		a.events.Publish("submission", fromID, "", s.EntryLang)
+	}
+
+	return res, nil, nil
 }
 
 // HandleNewComments records a suggestion for change from the public in the changes table.
 // These suggestions are reviewed in the admin and any change involves manually incorporating
 // them to the linked entries.
 func (a *App) HandleNewComments(c echo.Context) error {
+	// Rate limit floods of comments from a single IP before doing any other
+	// work. Comments aren't tied to a language, so only the per-IP bucket
+	// applies here.
+	if a.submissionLimiter != nil && !a.submissionLimiter.Allow(c.RealIP(), "") {
+		a.recordSubmissionAttempt(c.RealIP(), "", "rate_limit")
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(a.submissionLimiter.RetryAfter().Seconds())))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "Too many submissions. Please try again later.")
+	}
+
 	var s changeSubmission
 	if err := c.Bind(&s); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
@@ -156,11 +448,20 @@ func (a *App) HandleNewComments(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Comments are too big.")
 	}
 
+	if matchesBlocklist(s.Comments, a.blocklist) {
+		a.recordSubmissionAttempt(c.RealIP(), "", "blocklist")
+		return echo.NewHTTPError(http.StatusBadRequest, "Submission rejected.")
+	}
+
 	if err := a.data.InsertComments(s.FromGUID, s.ToGUID, s.Comments); err != nil {
 		a.lo.Printf("error inserting change submission: %v", err)
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			fmt.Sprintf("Error saving submission: %v", err))
 	}
 
+	if a.events != nil {
+		a.events.Publish("comment", 0, s.FromGUID, "")
+	}
+
 	return c.JSON(http.StatusOK, okResp{true})
 }
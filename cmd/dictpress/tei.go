@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/dictpress/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+// dictExportBatchSize is the number of entries fetched and relation-loaded
+// per round-trip while streaming a TEI/JSON-LD export, so a multi-million
+// entry dictionary is never buffered whole in memory.
+const dictExportBatchSize = bulkExportFlushEvery
+
+// teiDoc is the root of a TEI Lex-0 document, the community-endorsed
+// dictionary subset of TEI (https://www.tei-c.org/Vault/P5/current/doc/tei-p5-doc/en/html/DI.html).
+// Only the subset dictpress round-trips is modelled; unknown elements are
+// ignored on import and omitted on export.
+type teiDoc struct {
+	XMLName xml.Name   `xml:"TEI"`
+	Entries []teiEntry `xml:"text>body>entry"`
+}
+
+type teiEntry struct {
+	ID     string     `xml:"id,attr,omitempty"`
+	Form   teiForm    `xml:"form"`
+	Senses []teiSense `xml:"sense"`
+}
+
+type teiForm struct {
+	Orth string `xml:"orth"`
+}
+
+type teiSense struct {
+	N    string   `xml:"n,attr,omitempty"`
+	Def  string   `xml:"def,omitempty"`
+	Cits []teiCit `xml:"cit"`
+}
+
+// teiCit is a "citation" inside a sense. A cit type="translation" names the
+// entry's translation in another language, which dictpress imports as a
+// to-lang entry linked by a relation.
+type teiCit struct {
+	Type  string `xml:"type,attr"`
+	Quote string `xml:"quote"`
+}
+
+// lemonDoc is the root of an Ontolex-Lemon (https://www.w3.org/2016/05/ontolex)
+// JSON-LD document. Only LexicalEntry/Form/LexicalSense are modelled.
+type lemonDoc struct {
+	Context interface{}  `json:"@context,omitempty"`
+	Graph   []lemonEntry `json:"@graph"`
+}
+
+type lemonEntry struct {
+	ID            string       `json:"@id,omitempty"`
+	Type          string       `json:"@type"`
+	CanonicalForm lemonForm    `json:"canonicalForm"`
+	Sense         []lemonSense `json:"sense"`
+}
+
+type lemonForm struct {
+	WrittenRep lemonLangString `json:"writtenRep"`
+}
+
+type lemonLangString struct {
+	Lang  string `json:"@language,omitempty"`
+	Value string `json:"@value"`
+}
+
+type lemonSense struct {
+	Definition  string            `json:"definition,omitempty"`
+	Translation *lemonTranslation `json:"translation,omitempty"`
+}
+
+// lemonTranslation is a non-standard (dictpress-specific) extension of
+// LexicalSense carrying the sense's translation inline, since Ontolex-Lemon
+// itself models cross-lingual links via a separate `vartrans:Translation`
+// graph that's overkill for a flat dictionary export.
+type lemonTranslation struct {
+	WrittenRep lemonLangString `json:"writtenRep"`
+}
+
+// dictImportEntry is the from-lang entry and its parsed to-lang
+// translations, the common shape both parseTEI and parseJSONLD reduce their
+// input to before it's validated and inserted.
+type dictImportEntry struct {
+	Index        int
+	Content      string
+	Notes        string
+	Translations []dictImportTranslation
+}
+
+type dictImportTranslation struct {
+	Content string
+	Notes   string
+}
+
+// dictImportResult reports the outcome of importing a single TEI/JSON-LD
+// entry, keyed by its position in the document.
+type dictImportResult struct {
+	Index int    `json:"index"`
+	ID    int    `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// dictFormat negotiates a TEI/JSON-LD request's body format: the `format`
+// query param ("tei" or "jsonld") takes precedence, falling back to
+// sniffing the `Content-Type` header.
+func dictFormat(c echo.Context) string {
+	switch strings.ToLower(c.QueryParam("format")) {
+	case "jsonld", "json-ld", "json":
+		return "jsonld"
+	case "tei", "xml":
+		return "tei"
+	}
+
+	if strings.Contains(c.Request().Header.Get(echo.HeaderContentType), "json") {
+		return "jsonld"
+	}
+	return "tei"
+}
+
+// HandleExportDict streams every entry in the fromLang -> toLang dictionary
+// as a TEI Lex-0 (format=tei, the default) or Ontolex-Lemon JSON-LD
+// (format=jsonld) document. Entries are read and relation-loaded in batches
+// of dictExportBatchSize and the response is flushed after each batch, so
+// exporting a multi-million entry dictionary never buffers it whole.
+func (a *App) HandleExportDict(c echo.Context) error {
+	var (
+		fromLang = c.Param("fromLang")
+		toLang   = c.Param("toLang")
+	)
+
+	if _, ok := a.data.Langs[fromLang]; !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown `fromLang`")
+	}
+	if _, ok := a.data.Langs[toLang]; !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown `toLang`")
+	}
+
+	rows, err := a.db.Queryx(`SELECT id, guid, weight, initial, lang, content, tags, phones, notes, status
+		FROM entries WHERE lang = $1 ORDER BY id`, fromLang)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Sprintf("error querying entries: %v", err))
+	}
+	defer rows.Close()
+
+	if dictFormat(c) == "jsonld" {
+		return a.streamExportLemon(c, rows, fromLang, toLang)
+	}
+	return a.streamExportTEI(c, rows, fromLang, toLang)
+}
+
+// nextExportBatch reads up to dictExportBatchSize rows off rows and loads
+// their toLang relations in one round-trip.
+func (a *App) nextExportBatch(ctx context.Context, rows *sqlx.Rows, toLang string) ([]data.Entry, error) {
+	batch := make([]data.Entry, 0, dictExportBatchSize)
+	for len(batch) < dictExportBatchSize && rows.Next() {
+		var e data.Entry
+		if err := rows.StructScan(&e); err != nil {
+			return nil, err
+		}
+		e.Relations = make([]data.Entry, 0)
+		batch = append(batch, e)
+	}
+
+	if len(batch) > 0 {
+		if err := a.data.SearchAndLoadRelations(ctx, batch, data.Query{ToLang: toLang}); err != nil {
+			return nil, fmt.Errorf("error loading relations: %v", err)
+		}
+	}
+
+	return batch, nil
+}
+
+func (a *App) streamExportTEI(c echo.Context, rows *sqlx.Rows, fromLang, toLang string) error {
+	c.Response().Header().Set(echo.HeaderContentType, "application/tei+xml")
+	c.Response().WriteHeader(http.StatusOK)
+
+	flusher := c.Response().Writer.(http.Flusher)
+	if _, err := c.Response().Write([]byte(xml.Header + "<TEI xmlns=\"http://www.tei-c.org/ns/1.0\">\n<text>\n<body>\n")); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(c.Response())
+	for {
+		batch, err := a.nextExportBatch(c.Request().Context(), rows, toLang)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, e := range batch {
+			te := teiEntry{ID: e.GUID, Form: teiForm{Orth: e.Content}}
+			sense := teiSense{N: "1", Def: e.Notes}
+			for _, r := range e.Relations {
+				sense.Cits = append(sense.Cits, teiCit{Type: "translation", Quote: r.Content})
+			}
+			te.Senses = []teiSense{sense}
+
+			if err := enc.Encode(te); err != nil {
+				return err
+			}
+			if _, err := c.Response().Write([]byte("\n")); err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+	}
+
+	_, err := c.Response().Write([]byte("</body>\n</text>\n</TEI>\n"))
+	flusher.Flush()
+	return err
+}
+
+func (a *App) streamExportLemon(c echo.Context, rows *sqlx.Rows, fromLang, toLang string) error {
+	c.Response().Header().Set(echo.HeaderContentType, "application/ld+json")
+	c.Response().WriteHeader(http.StatusOK)
+
+	flusher := c.Response().Writer.(http.Flusher)
+	if _, err := c.Response().Write([]byte(`{"@context":"http://www.w3.org/ns/lemon/ontolex#","@graph":[` + "\n")); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(c.Response())
+	first := true
+	for {
+		batch, err := a.nextExportBatch(c.Request().Context(), rows, toLang)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, e := range batch {
+			if !first {
+				if _, err := c.Response().Write([]byte(",")); err != nil {
+					return err
+				}
+			}
+			first = false
+
+			le := lemonEntry{
+				ID:            "#" + e.GUID,
+				Type:          "LexicalEntry",
+				CanonicalForm: lemonForm{WrittenRep: lemonLangString{Lang: fromLang, Value: e.Content}},
+			}
+			for _, r := range e.Relations {
+				le.Sense = append(le.Sense, lemonSense{
+					Definition:  e.Notes,
+					Translation: &lemonTranslation{WrittenRep: lemonLangString{Lang: toLang, Value: r.Content}},
+				})
+			}
+
+			if err := enc.Encode(le); err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+	}
+
+	_, err := c.Response().Write([]byte("]}\n"))
+	flusher.Flush()
+	return err
+}
+
+// HandleImportDict parses a TEI Lex-0 (format=tei, the default) or
+// Ontolex-Lemon JSON-LD (format=jsonld) document from the request body into
+// data.Entry/data.Relation rows for the fromLang -> toLang dictionary pair
+// and inserts them in a transaction, batched like HandleBulkImportEntries.
+// With ?dry_run=1 set, entries are parsed and validated but nothing is
+// written; the response reports per-entry parse errors so an operator can
+// fix a source file before committing to a real import.
+func (a *App) HandleImportDict(c echo.Context) error {
+	var (
+		fromLang = c.Param("fromLang")
+		toLang   = c.Param("toLang")
+		dryRun   = c.QueryParam("dry_run") == "1"
+	)
+
+	if _, ok := a.data.Langs[fromLang]; !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown `fromLang`")
+	}
+	if _, ok := a.data.Langs[toLang]; !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown `toLang`")
+	}
+
+	var (
+		entries []dictImportEntry
+		err     error
+	)
+	if dictFormat(c) == "jsonld" {
+		entries, err = parseLemon(c.Request().Body)
+	} else {
+		entries, err = parseTEI(c.Request().Body)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error parsing document: %v", err))
+	}
+
+	out := make([]dictImportResult, 0, len(entries))
+	if dryRun {
+		for _, e := range entries {
+			res := dictImportResult{Index: e.Index}
+			if strings.TrimSpace(e.Content) == "" {
+				res.Error = "empty entry form/writtenRep"
+			}
+			out = append(out, res)
+		}
+		return c.JSON(http.StatusOK, okResp{out})
+	}
+
+	for _, e := range entries {
+		id, err := a.importDictEntry(e, fromLang, toLang)
+		res := dictImportResult{Index: e.Index, ID: id}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		out = append(out, res)
+	}
+
+	a.invalidateSearchCache()
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// importDictEntry inserts a single parsed TEI/JSON-LD entry and its
+// translations in one transaction: the from-lang headword, a to-lang entry
+// per translation, and a relation linking the two.
+func (a *App) importDictEntry(e dictImportEntry, fromLang, toLang string) (int, error) {
+	if strings.TrimSpace(e.Content) == "" {
+		return 0, fmt.Errorf("empty entry form/writtenRep")
+	}
+
+	tx, err := a.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	fromID, err := a.insertDictEntry(tx, fromLang, e.Content, e.Notes)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, t := range e.Translations {
+		if strings.TrimSpace(t.Content) == "" {
+			continue
+		}
+
+		toID, err := a.insertDictEntry(tx, toLang, t.Content, t.Notes)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err := tx.Stmtx(a.queries.InsertRelation).Exec(fromID, toID,
+			[]string{"translation"}, []string{}, "", 0, data.StatusEnabled); err != nil {
+			return 0, fmt.Errorf("error inserting relation: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing entry: %v", err)
+	}
+
+	return fromID, nil
+}
+
+// insertDictEntry tokenizes and inserts a single entry within tx, deriving
+// `initial` from the content's first character the same way the admin UI
+// does for manually entered entries.
+func (a *App) insertDictEntry(tx *sqlx.Tx, lang, content, notes string) (int, error) {
+	l, ok := a.data.Langs[lang]
+	if !ok {
+		return 0, fmt.Errorf("unknown lang '%s'", lang)
+	}
+
+	var (
+		tsVectorLang = ""
+		tokens       = ""
+	)
+	if l.Tokenizer == nil {
+		tsVectorLang = l.TokenizerName
+	} else {
+		toks, err := l.Tokenizer.ToTokens(content, lang)
+		if err != nil {
+			return 0, fmt.Errorf("error tokenizing content: %v", err)
+		}
+		tokens = strings.Join(toks, " ")
+	}
+
+	initial := string([]rune(content)[:1])
+
+	var id int
+	if err := tx.Stmtx(a.queries.InsertEntry).Get(&id, content, initial, 0.0, tokens, tsVectorLang,
+		lang, []string{}, []string{}, notes, data.StatusEnabled); err != nil {
+		return 0, fmt.Errorf("error inserting entry: %v", err)
+	}
+
+	return id, nil
+}
+
+// parseTEI reads a TEI Lex-0 document and reduces its entries/senses/cits
+// to the common dictImportEntry shape.
+func parseTEI(r io.Reader) ([]dictImportEntry, error) {
+	var doc teiDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	out := make([]dictImportEntry, 0, len(doc.Entries))
+	for i, te := range doc.Entries {
+		e := dictImportEntry{Index: i, Content: strings.TrimSpace(te.Form.Orth)}
+		for _, s := range te.Senses {
+			if e.Notes == "" {
+				e.Notes = strings.TrimSpace(s.Def)
+			}
+			for _, cit := range s.Cits {
+				if cit.Type != "translation" {
+					continue
+				}
+				e.Translations = append(e.Translations, dictImportTranslation{
+					Content: strings.TrimSpace(cit.Quote),
+					Notes:   strings.TrimSpace(s.Def),
+				})
+			}
+		}
+		out = append(out, e)
+	}
+
+	return out, nil
+}
+
+// parseLemon reads an Ontolex-Lemon JSON-LD document and reduces its
+// LexicalEntry/LexicalSense graph to the common dictImportEntry shape.
+func parseLemon(r io.Reader) ([]dictImportEntry, error) {
+	var doc lemonDoc
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	out := make([]dictImportEntry, 0, len(doc.Graph))
+	for i, le := range doc.Graph {
+		e := dictImportEntry{Index: i, Content: strings.TrimSpace(le.CanonicalForm.WrittenRep.Value)}
+		for _, s := range le.Sense {
+			if e.Notes == "" {
+				e.Notes = strings.TrimSpace(s.Definition)
+			}
+			if s.Translation != nil && strings.TrimSpace(s.Translation.WrittenRep.Value) != "" {
+				e.Translations = append(e.Translations, dictImportTranslation{
+					Content: strings.TrimSpace(s.Translation.WrittenRep.Value),
+					Notes:   strings.TrimSpace(s.Definition),
+				})
+			}
+		}
+		out = append(out, e)
+	}
+
+	return out, nil
+}
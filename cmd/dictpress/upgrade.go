@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/dictpress/internal/migrations"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+	"github.com/lib/pq"
+)
+
+// migrationsConn is satisfied by both *sqlx.DB (boot-time checks, which can
+// run against any pooled connection) and *sqlx.Conn (upgrade(), which must
+// keep every statement -- lock, reads and every migration's own transaction
+// -- on the single connection holding its session-scoped advisory lock).
+type migrationsConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// ensureMigrationsTable creates the schema_migrations tracking table if it
+// doesn't exist yet, and, the first time it's created, backfills it from the
+// legacy settings.migrations JSON array so a database upgraded from an
+// older dictpress isn't treated as having every migration pending again.
+// dirty starts FALSE for every backfilled row: a pre-existing install, by
+// definition, already has those migrations cleanly applied.
+//
+// version is TEXT (a semver tag, see migrations.Migration.Version) rather
+// than a bigint sequence, and checkUpgrade's boot-time check is unconditional
+// rather than gated behind an opt-in flag: this table tracks the same
+// Go-function runner installSchema seeds and upgrade applies, not a separate
+// --migrate-check-style subsystem.
+func ensureMigrationsTable(ctx context.Context, db migrationsConn) error {
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			dirty      BOOLEAN NOT NULL DEFAULT FALSE
+		)`); err != nil {
+		return err
+	}
+
+	// CREATE TABLE IF NOT EXISTS doesn't tell us whether it already existed,
+	// so only backfill when the table is empty -- a no-op on every boot
+	// after the first.
+	var n int
+	if err := db.GetContext(ctx, &n, `SELECT COUNT(*) FROM schema_migrations`); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	legacy, err := getLegacyAppliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, v := range legacy {
+		if err := recordMigrationVersion(ctx, v, db); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getLegacyAppliedMigrations returns the versions recorded in the old
+// settings.migrations JSON array, used only to backfill schema_migrations on
+// a database that predates it. A database that pre-dates the migrations
+// system entirely (no settings table yet) simply has none applied; V2_0_0,
+// the first migration, creates the table.
+func getLegacyAppliedMigrations(ctx context.Context, db migrationsConn) ([]string, error) {
+	var raw []byte
+	err := db.GetContext(ctx, &raw, `SELECT value FROM settings WHERE key = 'migrations'`)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "42P01" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var out []string
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// getAppliedMigrations returns the versions recorded as cleanly applied in
+// schema_migrations, creating and backfilling that table on first use. A
+// version left dirty by a migration that failed mid-run is deliberately
+// excluded, so it's retried rather than considered done.
+func getAppliedMigrations(ctx context.Context, db migrationsConn) ([]string, error) {
+	if err := ensureMigrationsTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	var out []string
+	if err := db.SelectContext(ctx, &out, `SELECT version FROM schema_migrations WHERE NOT dirty`); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// getDirtyMigrations returns the versions in schema_migrations left marked
+// dirty by a migration that was interrupted mid-run (eg: the process was
+// killed, or its Fn returned an error after partially altering the schema).
+// These require manual inspection before dictpress can be trusted to run
+// against the database again.
+func getDirtyMigrations(ctx context.Context, db migrationsConn) ([]string, error) {
+	if err := ensureMigrationsTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	var out []string
+	if err := db.SelectContext(ctx, &out, `SELECT version FROM schema_migrations WHERE dirty ORDER BY applied_at`); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pendingMigrations returns the migrations in migrations.All that aren't
+// present in applied, in the order they should be run.
+func pendingMigrations(applied []string) []migrations.Migration {
+	done := make(map[string]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+
+	var out []migrations.Migration
+	for _, m := range migrations.All {
+		if !done[m.Version] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// checkUpgrade exits the process if the database has pending migrations.
+// It's called on every server boot so that a stale schema fails fast with a
+// clear message instead of surfacing as confusing runtime errors.
+func checkUpgrade(db *sqlx.DB) {
+	ctx := context.Background()
+
+	dirty, err := getDirtyMigrations(ctx, db)
+	if err != nil {
+		lo.Fatalf("error reading migration state: %v", err)
+	}
+	if len(dirty) > 0 {
+		lo.Fatalf("database has %d migration(s) left dirty by a previous failed run: %s. "+
+			"Inspect the schema manually, then clear schema_migrations.dirty for it before retrying `--upgrade`.",
+			len(dirty), strings.Join(dirty, ", "))
+	}
+
+	applied, err := getAppliedMigrations(ctx, db)
+	if err != nil {
+		lo.Fatalf("error reading applied migrations: %v", err)
+	}
+
+	if pending := pendingMigrations(applied); len(pending) > 0 {
+		lo.Fatalf("database is out of date. %d pending migration(s) found. Run `--upgrade`.", len(pending))
+	}
+}
+
+// checkPendingMigrations implements `upgrade --check`. It prints a summary
+// and returns a process exit code: 0 when the database is current, 1 when
+// migrations are pending, so it can gate container startup on schema
+// freshness without actually applying anything.
+func checkPendingMigrations(db *sqlx.DB) int {
+	ctx := context.Background()
+
+	dirty, err := getDirtyMigrations(ctx, db)
+	if err != nil {
+		lo.Fatalf("error reading migration state: %v", err)
+	}
+
+	applied, err := getAppliedMigrations(ctx, db)
+	if err != nil {
+		lo.Fatalf("error reading applied migrations: %v", err)
+	}
+
+	pending := pendingMigrations(applied)
+	if len(pending) == 0 && len(dirty) == 0 {
+		lo.Println("database is up to date.")
+		return 0
+	}
+
+	if len(dirty) > 0 {
+		lo.Printf("%d migration(s) left dirty by a previous failed run:", len(dirty))
+		for _, v := range dirty {
+			fmt.Printf("  - %s\n", v)
+		}
+	}
+	if len(pending) > 0 {
+		lo.Printf("%d pending migration(s):", len(pending))
+		for _, m := range pending {
+			fmt.Printf("  - %s: %s\n", m.Version, m.Description)
+		}
+	}
+	return 1
+}
+
+// migrationLockKey is the key passed to pg_advisory_lock/pg_advisory_unlock
+// around upgrade(), so that two `dictpress --upgrade` processes started at
+// once (eg: a multi-replica deploy rolling out simultaneously) don't race to
+// apply the same migration twice; the second one blocks until the first
+// releases the lock, then finds nothing pending and exits cleanly.
+const migrationLockKey = 8731562031
+
+// upgrade runs every pending migration against db, each inside its own
+// transaction, recording its version in schema_migrations on success, and
+// prints a summary. A version is marked dirty the moment it starts and only
+// cleared once its transaction commits, so a migration interrupted partway
+// through (a crash, a killed process) is caught by checkUpgrade on the next
+// boot instead of being silently retried or considered done.
+//
+// The advisory lock, every schema_migrations read/write and every
+// migration's own transaction all run against one *sqlx.Conn reserved for
+// the duration of the call -- not the pooled *sqlx.DB. pg_advisory_lock is
+// scoped to the session holding it; running each statement through the pool
+// would let database/sql hand later statements to a different physical
+// connection (or close the locking one as idle) and silently drop the lock
+// mid-run, defeating the serialization this function exists to provide.
+func upgrade(prompt bool, fs stuffbin.FileSystem, db *sqlx.DB, ko *koanf.Koanf) {
+	ctx := context.Background()
+
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		lo.Fatalf("error reserving a migration connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		lo.Fatalf("error acquiring migration lock: %v", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	applied, err := getAppliedMigrations(ctx, conn)
+	if err != nil {
+		lo.Fatal(err)
+	}
+
+	pending := pendingMigrations(applied)
+	if len(pending) == 0 {
+		lo.Println("no pending migrations. database is already up to date.")
+		return
+	}
+
+	fmt.Println("")
+	fmt.Printf("found %d pending migration(s):\n", len(pending))
+	for _, m := range pending {
+		fmt.Printf("  - %s: %s\n", m.Version, m.Description)
+	}
+	fmt.Println("")
+
+	if prompt {
+		var ok string
+		fmt.Print("continue (y/n)?  ")
+		if _, err := fmt.Scanf("%s", &ok); err != nil {
+			fmt.Printf("error reading value from terminal: %v", err)
+			os.Exit(1)
+		}
+		if strings.ToLower(ok) != "y" {
+			fmt.Println("upgrade cancelled.")
+			return
+		}
+	}
+
+	for _, m := range pending {
+		lo.Printf("running migration %s ...", m.Version)
+
+		// Mark the version dirty outside the migration's own transaction,
+		// committed immediately, so a crash mid-migration leaves a durable
+		// trail rather than silently looking like it never started.
+		if err := markMigrationDirty(ctx, m.Version, conn); err != nil {
+			lo.Fatalf("error marking migration %s dirty: %v", m.Version, err)
+		}
+
+		tx, err := conn.BeginTxx(ctx, nil)
+		if err != nil {
+			lo.Fatal(err)
+		}
+
+		if err := m.Fn(tx, fs, ko); err != nil {
+			tx.Rollback()
+			lo.Fatalf("error running migration %s: %v. "+
+				"It remains marked dirty in schema_migrations for manual inspection.", m.Version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			lo.Fatalf("error committing migration %s: %v. "+
+				"It remains marked dirty in schema_migrations for manual inspection.", m.Version, err)
+		}
+
+		// Only clear dirty once the migration's own transaction has
+		// actually committed.
+		if err := recordMigrationVersion(ctx, m.Version, conn); err != nil {
+			lo.Fatalf("error recording migration %s: %v", m.Version, err)
+		}
+	}
+
+	lo.Printf("successfully applied %d migration(s)", len(pending))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/knadh/dictpress/internal/migrations"
+)
+
+func TestPendingMigrationsNoneApplied(t *testing.T) {
+	pending := pendingMigrations(nil)
+	if len(pending) != len(migrations.All) {
+		t.Fatalf("expected all %d migrations pending, got %d", len(migrations.All), len(pending))
+	}
+}
+
+func TestPendingMigrationsAllApplied(t *testing.T) {
+	applied := make([]string, len(migrations.All))
+	for i, m := range migrations.All {
+		applied[i] = m.Version
+	}
+
+	pending := pendingMigrations(applied)
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending migrations, got %d", len(pending))
+	}
+}
+
+func TestPendingMigrationsSkipsOnlyApplied(t *testing.T) {
+	if len(migrations.All) < 2 {
+		t.Skip("not enough registered migrations to test a partial apply")
+	}
+
+	applied := []string{migrations.All[0].Version}
+	pending := pendingMigrations(applied)
+
+	if len(pending) != len(migrations.All)-1 {
+		t.Fatalf("expected %d pending migrations, got %d", len(migrations.All)-1, len(pending))
+	}
+	for _, m := range pending {
+		if m.Version == applied[0] {
+			t.Fatalf("applied migration %s should not be pending", m.Version)
+		}
+	}
+}
+
+// TestPendingMigrationsPreservesOrder asserts pendingMigrations keeps
+// migrations.All's order (applied by upgrade() in that order), rather than
+// reshuffling them by iterating the `done` map.
+func TestPendingMigrationsPreservesOrder(t *testing.T) {
+	pending := pendingMigrations(nil)
+	for i, m := range pending {
+		if m.Version != migrations.All[i].Version {
+			t.Fatalf("expected pending[%d] = %s, got %s", i, migrations.All[i].Version, m.Version)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/knadh/go-i18n"
+)
+
+// watchDebounce coalesces a burst of filesystem events (editors commonly
+// fire several per save, eg: write + rename + chmod) into a single reload.
+const watchDebounce = 200 * time.Millisecond
+
+// loadI18n loads the language pack at langFile, falling back to an empty
+// pack (so templates referencing .L still render) if the file doesn't exist.
+func loadI18n(langFile string) (*i18n.I18n, error) {
+	if _, err := os.Stat(langFile); errors.Is(err, os.ErrNotExist) {
+		return i18n.New([]byte(`{"_.code": "", "_.name": ""}`))
+	}
+
+	return i18n.NewFromFile(langFile)
+}
+
+// startWatcher watches the site theme directory (and its pages/ subdirectory
+// and lang.json, if configured) -- or, for a multi-language theme directory
+// (see isMultiLangSite), every language's own subdirectory and pages/ -- and
+// the admin template directory, for changes. It debounces bursts of events
+// and atomically swaps in freshly parsed templates/i18n packs as they're
+// edited. A bad edit is logged and the previously loaded, good template is
+// kept live rather than crashing the server or serving a half-parsed tree.
+func (a *App) startWatcher() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if a.sitePath != "" {
+		dirs := []string{a.sitePath}
+		if isMultiLangSite(a.sitePath, a.data.Langs) {
+			dirs = nil
+			for l := range a.data.Langs {
+				dir := filepath.Join(a.sitePath, l)
+				if hasHTML(dir) {
+					dirs = append(dirs, dir)
+				}
+			}
+		}
+
+		for _, dir := range dirs {
+			if err := w.Add(dir); err != nil {
+				return err
+			}
+			lo.Printf("watch: watching %s for changes", dir)
+
+			if a.siteLoadPages {
+				pagesDir := filepath.Join(dir, "pages")
+				if err := w.Add(pagesDir); err != nil {
+					lo.Printf("watch: not watching %s: %v", pagesDir, err)
+				} else {
+					lo.Printf("watch: watching %s for changes", pagesDir)
+				}
+			}
+		}
+	}
+
+	const adminDir = "admin"
+	if err := w.Add(adminDir); err != nil {
+		lo.Printf("watch: not watching %s: %v", adminDir, err)
+	} else {
+		lo.Printf("watch: watching %s for changes", adminDir)
+	}
+
+	go a.watchLoop(w)
+
+	return nil
+}
+
+// watchLoop debounces fsnotify events off w and reloads on the trailing
+// edge, until w is closed.
+func (a *App) watchLoop(w *fsnotify.Watcher) {
+	defer w.Close()
+
+	var timer *time.Timer
+	for {
+		select {
+		case _, ok := <-w.Events:
+			if !ok {
+				return
+			}
+
+			if timer == nil {
+				timer = time.AfterFunc(watchDebounce, a.reloadWatched)
+			} else {
+				timer.Reset(watchDebounce)
+			}
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			lo.Printf("watch: error: %v", err)
+		}
+	}
+}
+
+// reloadWatched re-parses and atomically swaps in everything startWatcher
+// watches. It never returns an error: a failure is logged and the
+// previously loaded, good template/i18n pack is left in place.
+func (a *App) reloadWatched() {
+	if a.sitePath != "" {
+		// reloadSite() re-parses templates and lang.json(s) together, so a
+		// theme/lang.json pair edited in the same debounce window is always
+		// swapped in atomically as a matching set, never templates from one
+		// edit paired with a stale or half-written language pack from another.
+		if err := a.reloadSite(); err != nil {
+			lo.Printf("watch: error reloading site theme, keeping previous version: %v", err)
+		} else {
+			lo.Printf("watch: reloaded site theme")
+		}
+	}
+
+	tpl, err := loadAdminTemplates(a.fs)
+	if err != nil {
+		lo.Printf("watch: error reloading admin templates, keeping previous version: %v", err)
+		return
+	}
+	a.adminTpl.Store(tpl)
+	lo.Printf("watch: reloaded admin templates")
+}
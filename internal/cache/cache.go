@@ -11,8 +11,16 @@ import (
 const (
 	CacheTypeMemory = "memory"
 	CacheTypeHybrid = "hybrid"
+
+	// CacheTypeRedis selects RedisCache instead of the embedded Badger
+	// Cache, for deployments running more than one dictpress instance
+	// behind a load balancer.
+	CacheTypeRedis = "redis"
 )
 
+// Cache satisfies Store.
+var _ Store = (*Cache)(nil)
+
 // Config holds cache configuration.
 type Config struct {
 	TTL time.Duration
@@ -116,6 +124,39 @@ func (c *Cache) Put(key string, val []byte, ttl *time.Duration) error {
 	})
 }
 
+// Reserve implements Store using Badger's transaction conflict detection:
+// if two Reserve calls for the same key race, Badger aborts the commit of
+// whichever transaction loses with ErrConflict, so the loser correctly
+// reports losing instead of overwriting the winner's value.
+func (c *Cache) Reserve(key string, val []byte, ttl *time.Duration) (bool, error) {
+	t := c.defaultTTL
+	if ttl != nil {
+		t = *ttl
+	}
+
+	won := false
+	err := c.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(key)); err == nil {
+			won = false
+			return nil
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		won = true
+		e := badger.NewEntry([]byte(key), val).WithTTL(t)
+		return txn.SetEntry(e)
+	})
+	if err == badger.ErrConflict {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return won, nil
+}
+
 // Delete deletes a key from the cache.
 func (c *Cache) Delete(key string) error {
 	return c.db.Update(func(txn *badger.Txn) error {
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+var lo = log.New(os.Stdout, "cache: ", log.LstdFlags)
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+
+	c, err := New(Config{TTL: time.Minute, Mode: CacheTypeMemory}, lo)
+	assert.NilError(t, err)
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func TestCacheGetPutMiss(t *testing.T) {
+	c := newTestCache(t)
+
+	v, err := c.Get("missing")
+	assert.NilError(t, err)
+	assert.Assert(t, v == nil)
+}
+
+func TestCacheGetPutHit(t *testing.T) {
+	c := newTestCache(t)
+
+	assert.NilError(t, c.Put("k", []byte("v"), nil))
+
+	v, err := c.Get("k")
+	assert.NilError(t, err)
+	assert.Equal(t, string(v), "v")
+}
+
+func TestCacheTTLExpiry(t *testing.T) {
+	c := newTestCache(t)
+
+	ttl := 10 * time.Millisecond
+	assert.NilError(t, c.Put("k", []byte("v"), &ttl))
+
+	time.Sleep(100 * time.Millisecond)
+
+	v, err := c.Get("k")
+	assert.NilError(t, err)
+	assert.Assert(t, v == nil)
+}
+
+func TestCacheDeleteAndPurge(t *testing.T) {
+	c := newTestCache(t)
+
+	assert.NilError(t, c.Put("a", []byte("1"), nil))
+	assert.NilError(t, c.Put("b", []byte("2"), nil))
+
+	assert.NilError(t, c.Delete("a"))
+	v, err := c.Get("a")
+	assert.NilError(t, err)
+	assert.Assert(t, v == nil)
+
+	assert.NilError(t, c.Purge())
+	v, err = c.Get("b")
+	assert.NilError(t, err)
+	assert.Assert(t, v == nil)
+}
+
+func TestCacheReserve(t *testing.T) {
+	c := newTestCache(t)
+
+	won, err := c.Reserve("k", []byte("first"), nil)
+	assert.NilError(t, err)
+	assert.Assert(t, won)
+
+	won, err = c.Reserve("k", []byte("second"), nil)
+	assert.NilError(t, err)
+	assert.Assert(t, !won)
+
+	v, err := c.Get("k")
+	assert.NilError(t, err)
+	assert.Equal(t, string(v), "first")
+}
+
+func TestCacheReserveAfterExpiry(t *testing.T) {
+	c := newTestCache(t)
+
+	ttl := 10 * time.Millisecond
+	won, err := c.Reserve("k", []byte("first"), &ttl)
+	assert.NilError(t, err)
+	assert.Assert(t, won)
+
+	time.Sleep(100 * time.Millisecond)
+
+	won, err = c.Reserve("k", []byte("second"), nil)
+	assert.NilError(t, err)
+	assert.Assert(t, won)
+
+	v, err := c.Get("k")
+	assert.NilError(t, err)
+	assert.Equal(t, string(v), "second")
+}
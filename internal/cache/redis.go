@@ -0,0 +1,512 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisReconnectBaseDelay and redisReconnectMaxDelay bound the exponential
+// backoff used to re-establish a dropped Redis connection, doubling from the
+// base on each failed attempt up to the max.
+const (
+	redisReconnectBaseDelay = 100 * time.Millisecond
+	redisReconnectMaxDelay  = 5 * time.Second
+)
+
+// redisReconnectAttempts caps how many times do() retries connect() for the
+// shared command connection before giving up and returning an error to its
+// caller -- unlike the subscription listener, a command has a caller
+// waiting on it, so it can't back off forever.
+const redisReconnectAttempts = 5
+
+// RedisConfig configures a RedisCache.
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	TTL      time.Duration
+
+	// Channel is the pub/sub channel invalidations are published to and
+	// subscribed from. Every dictpress instance pointed at the same Redis
+	// and Channel evicts in lockstep.
+	Channel string
+}
+
+// RedisCache is a Store (and Invalidator) backed by Redis, used when
+// cache.mode = "redis" so that multiple dictpress instances behind a load
+// balancer share one results cache instead of each keeping its own
+// in-process Badger cache. It speaks just enough RESP2 to avoid pulling in
+// a full client library for what's a handful of commands (GET/SET/DEL,
+// SADD/SMEMBERS for the per-language key index, PUBLISH/SUBSCRIBE for
+// invalidation).
+type RedisCache struct {
+	cfg RedisConfig
+	lo  *log.Logger
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// RedisCache satisfies Store and Invalidator.
+var (
+	_ Store       = (*RedisCache)(nil)
+	_ Invalidator = (*RedisCache)(nil)
+)
+
+// NewRedis dials addr and returns a ready-to-use RedisCache.
+func NewRedis(cfg RedisConfig, lo *log.Logger) (*RedisCache, error) {
+	if cfg.Channel == "" {
+		cfg.Channel = "dictpress:cache:invalidate"
+	}
+
+	r := &RedisCache{cfg: cfg, lo: lo}
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// connect (re)establishes the command connection and authenticates/selects
+// the configured DB. Callers must hold r.mu.
+func (r *RedisCache) connect() error {
+	conn, err := net.DialTimeout("tcp", r.cfg.Addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("error connecting to redis at %s: %v", r.cfg.Addr, err)
+	}
+
+	r.conn = conn
+	r.rd = bufio.NewReader(conn)
+
+	// Authenticate over the connection just opened via doOnce, not do: do
+	// retries a failure by calling back into connect, which would recurse
+	// if authenticating the new connection failed too.
+	if r.cfg.Password != "" {
+		if _, err := r.doOnce("AUTH", r.cfg.Password); err != nil {
+			return err
+		}
+	}
+	if r.cfg.DB != 0 {
+		if _, err := r.doOnce("SELECT", strconv.Itoa(r.cfg.DB)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get implements Store.
+func (r *RedisCache) Get(key string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v, err := r.do("GET", key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected redis reply for GET: %v", v)
+	}
+
+	return b, nil
+}
+
+// Put implements Store. Keys produced by makeQueryCacheKey look like
+// "s:{fromLang}:...": when a key has that shape, Put also tracks it in a
+// "lang:{fromLang}" set so a later invalidation for that language knows
+// exactly which keys to evict instead of having to FLUSHDB.
+func (r *RedisCache) Put(key string, val []byte, ttl *time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.cfg.TTL
+	if ttl != nil {
+		t = *ttl
+	}
+
+	if t > 0 {
+		if _, err := r.do("SET", key, string(val), "PX", strconv.FormatInt(t.Milliseconds(), 10)); err != nil {
+			return err
+		}
+	} else {
+		if _, err := r.do("SET", key, string(val)); err != nil {
+			return err
+		}
+	}
+
+	if lang, ok := searchCacheKeyLang(key); ok {
+		if _, err := r.do("SADD", "lang:"+lang, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reserve implements Store using Redis's atomic `SET key val NX`, which only
+// succeeds if key doesn't already exist.
+func (r *RedisCache) Reserve(key string, val []byte, ttl *time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.cfg.TTL
+	if ttl != nil {
+		t = *ttl
+	}
+
+	var (
+		reply interface{}
+		err   error
+	)
+	if t > 0 {
+		reply, err = r.do("SET", key, string(val), "PX", strconv.FormatInt(t.Milliseconds(), 10), "NX")
+	} else {
+		reply, err = r.do("SET", key, string(val), "NX")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return reply != nil, nil
+}
+
+// Delete implements Store.
+func (r *RedisCache) Delete(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.do("DEL", key)
+	return err
+}
+
+// Purge implements Store by flushing the entire (dedicated) Redis DB.
+func (r *RedisCache) Purge() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.do("FLUSHDB")
+	return err
+}
+
+// Close implements Store.
+func (r *RedisCache) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.conn.Close()
+}
+
+// searchCacheKeyLang extracts the from-language out of a doSearch cache key
+// of the form "s:{fromLang}:...", which is the only key shape Put tracks per
+// language. Keys of any other shape (eg: the bulk-import idempotency cache)
+// are cached as usual but not subject to language-scoped invalidation.
+func searchCacheKeyLang(key string) (string, bool) {
+	if !strings.HasPrefix(key, "s:") {
+		return "", false
+	}
+
+	parts := strings.SplitN(key[2:], ":", 2)
+	if len(parts) == 0 || parts[0] == "" {
+		return "", false
+	}
+
+	return parts[0], true
+}
+
+// evictLang deletes every key tracked under lang:{lang} along with the
+// tracking set itself.
+func (r *RedisCache) evictLang(lang string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	setKey := "lang:" + lang
+
+	members, err := r.do("SMEMBERS", setKey)
+	if err != nil {
+		return err
+	}
+
+	keys, _ := members.([]interface{})
+	for _, k := range keys {
+		if b, ok := k.([]byte); ok {
+			if _, err := r.do("DEL", string(b)); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = r.do("DEL", setKey)
+	return err
+}
+
+// PublishInvalidation implements Invalidator.
+func (r *RedisCache) PublishInvalidation(lang string, entryID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.do("PUBLISH", r.cfg.Channel, fmt.Sprintf("%s:%d", lang, entryID))
+	return err
+}
+
+// SubscribeInvalidation implements Invalidator. It opens a second,
+// dedicated connection for the subscription (a connection in subscriber
+// mode can't run ordinary commands) and evicts lang:{lang} on every message,
+// for as long as the RedisCache lives -- reconnecting and re-subscribing
+// with backoff across a network blip or Redis restart instead of the
+// listener goroutine exiting for good.
+func (r *RedisCache) SubscribeInvalidation(fn func(lang string, entryID int)) error {
+	conn, rd, err := r.dialSubscriber()
+	if err != nil {
+		return err
+	}
+
+	go r.subscriptionLoop(conn, rd, fn)
+
+	return nil
+}
+
+// dialSubscriber opens a fresh connection, authenticates it and issues
+// SUBSCRIBE, ready for subscriptionLoop to read replies off of.
+func (r *RedisCache) dialSubscriber() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", r.cfg.Addr, 5*time.Second)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening redis subscriber connection: %v", err)
+	}
+
+	rd := bufio.NewReader(conn)
+	if r.cfg.Password != "" {
+		if err := writeCommand(conn, "AUTH", r.cfg.Password); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		if _, err := readReply(rd); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+
+	if err := writeCommand(conn, "SUBSCRIBE", r.cfg.Channel); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if _, err := readReply(rd); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, rd, nil
+}
+
+// subscriptionLoop reads invalidation messages off conn/rd for as long as
+// the RedisCache lives. Unlike do(), which gives up after
+// redisReconnectAttempts because a caller is waiting, this loop has no
+// caller to return an error to -- it keeps retrying with backoff (capped at
+// redisReconnectMaxDelay) indefinitely, since the alternative is silently
+// and permanently losing cross-instance cache invalidation until the
+// process itself is restarted.
+func (r *RedisCache) subscriptionLoop(conn net.Conn, rd *bufio.Reader, fn func(lang string, entryID int)) {
+	delay := redisReconnectBaseDelay
+
+	for {
+		reply, err := readReply(rd)
+		if err != nil {
+			conn.Close()
+			r.lo.Printf("redis subscription lost: %v; reconnecting in %s", err, delay)
+			time.Sleep(delay)
+
+			newConn, newRd, derr := r.dialSubscriber()
+			if derr != nil {
+				r.lo.Printf("redis subscription reconnect failed: %v", derr)
+				delay *= 2
+				if delay > redisReconnectMaxDelay {
+					delay = redisReconnectMaxDelay
+				}
+				continue
+			}
+
+			r.lo.Printf("redis subscription reconnected")
+			conn, rd = newConn, newRd
+			delay = redisReconnectBaseDelay
+			continue
+		}
+
+		msg, ok := reply.([]interface{})
+		if !ok || len(msg) != 3 {
+			continue
+		}
+
+		payload, ok := msg[2].([]byte)
+		if !ok {
+			continue
+		}
+
+		lang, idStr, found := strings.Cut(string(payload), ":")
+		if !found {
+			continue
+		}
+		id, _ := strconv.Atoi(idStr)
+
+		if err := r.evictLang(lang); err != nil {
+			r.lo.Printf("error evicting cache for lang %s: %v", lang, err)
+		}
+		if fn != nil {
+			fn(lang, id)
+		}
+	}
+}
+
+// do sends a RESP2 command on the command connection and returns its
+// decoded reply, reconnecting with backoff and retrying once if the
+// connection itself failed (a network blip, a Redis restart) rather than
+// Redis rejecting the command. Callers must hold r.mu.
+func (r *RedisCache) do(args ...string) (interface{}, error) {
+	v, err := r.doOnce(args...)
+	if err == nil {
+		return v, nil
+	}
+	if _, ok := err.(redisReplyError); ok {
+		return nil, err
+	}
+
+	if rerr := r.reconnect(); rerr != nil {
+		return nil, fmt.Errorf("redis command failed (%v) and reconnect failed: %v", err, rerr)
+	}
+	return r.doOnce(args...)
+}
+
+// doOnce sends a RESP2 command on the command connection and returns its
+// decoded reply, with no reconnect-on-failure. Callers must hold r.mu.
+func (r *RedisCache) doOnce(args ...string) (interface{}, error) {
+	if err := writeCommand(r.conn, args...); err != nil {
+		return nil, err
+	}
+	return readReply(r.rd)
+}
+
+// reconnect closes the current (presumed dead) command connection and
+// retries connect with exponential backoff, giving up after
+// redisReconnectAttempts so a single do() call doesn't block forever if
+// Redis is genuinely down. Callers must hold r.mu.
+func (r *RedisCache) reconnect() error {
+	if r.conn != nil {
+		r.conn.Close()
+	}
+
+	delay := redisReconnectBaseDelay
+	var err error
+	for attempt := 1; attempt <= redisReconnectAttempts; attempt++ {
+		if err = r.connect(); err == nil {
+			return nil
+		}
+
+		r.lo.Printf("redis reconnect attempt %d/%d failed: %v", attempt, redisReconnectAttempts, err)
+		if attempt == redisReconnectAttempts {
+			break
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+		if delay > redisReconnectMaxDelay {
+			delay = redisReconnectMaxDelay
+		}
+	}
+
+	return err
+}
+
+// writeCommand encodes args as a RESP2 array of bulk strings.
+func writeCommand(w interface{ Write([]byte) (int, error) }, args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
+
+// redisReplyError marks a reply Redis itself sent back as an error (a "-"
+// line, eg: a bad command or an auth failure), as opposed to a failure to
+// read or write the connection at all. do and the subscription listener
+// only reconnect on the latter -- a reply error means the connection is
+// fine and Redis is responding, just rejecting this particular command.
+type redisReplyError string
+
+func (e redisReplyError) Error() string { return string(e) }
+
+// readReply decodes a single RESP2 reply: simple strings and integers come
+// back as string/int64, bulk strings as []byte (nil for a null bulk
+// string), arrays as []interface{}, and error replies as a Go error.
+func readReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisReplyError(fmt.Sprintf("redis error: %s", line[1:]))
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n, nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2) // +2 for the trailing \r\n
+		if _, err := ioReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil, nil
+		}
+		out := make([]interface{}, n)
+		for i := 0; i < n; i++ {
+			v, err := readReply(rd)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis reply prefix: %q", line[0])
+	}
+}
+
+// ioReadFull fills buf completely from rd, the same contract as io.ReadFull,
+// named locally to avoid importing "io" for a single call site.
+func ioReadFull(rd *bufio.Reader, buf []byte) (int, error) {
+	read := 0
+	for read < len(buf) {
+		n, err := rd.Read(buf[read:])
+		read += n
+		if err != nil {
+			return read, err
+		}
+	}
+	return read, nil
+}
@@ -0,0 +1,19 @@
+package cache
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSearchCacheKeyLang(t *testing.T) {
+	lang, ok := searchCacheKeyLang("s:en:abcdef0123456789")
+	assert.Assert(t, ok)
+	assert.Equal(t, lang, "en")
+
+	_, ok = searchCacheKeyLang("g:en:a:0:10")
+	assert.Assert(t, !ok)
+
+	_, ok = searchCacheKeyLang("s:")
+	assert.Assert(t, !ok)
+}
@@ -0,0 +1,53 @@
+package cache
+
+import "time"
+
+// Store is the result-cache contract used by cmd/dictpress: a simple
+// key/value store with per-key TTL and a full purge. *Cache (Badger) and
+// *RedisCache both satisfy it, so callers don't need to care which backend
+// cache.mode selected.
+type Store interface {
+	// Get retrieves a value by key. It doesn't return an error when the key
+	// isn't found — a nil slice means "not cached".
+	Get(key string) ([]byte, error)
+
+	// Put stores a value with the given key and an optional TTL. If ttl is
+	// nil, the backend's default TTL is used.
+	Put(key string, val []byte, ttl *time.Duration) error
+
+	// Reserve stores val under key only if key doesn't already exist, so
+	// that two callers racing on the same key can tell who got there first
+	// without the TOCTOU window a separate Get-then-Put would have. It
+	// reports whether this call won the race (true) or key was already
+	// present (false), in which case val was not stored.
+	Reserve(key string, val []byte, ttl *time.Duration) (bool, error)
+
+	// Delete removes a single key.
+	Delete(key string) error
+
+	// Purge drops every cached value.
+	Purge() error
+
+	// Close releases any resources (connections, file handles) held by the
+	// backend.
+	Close() error
+}
+
+// Invalidator is implemented by backends that can propagate fine-grained
+// cache invalidation across multiple dictpress instances (eg: RedisCache via
+// pub/sub), as opposed to Store.Purge's blunt "drop everything" semantics.
+// Backends like the single-process Badger Cache don't implement it; callers
+// fall back to Purge in that case.
+type Invalidator interface {
+	// PublishInvalidation announces that entry id in lang has changed, so
+	// every subscriber (including the publisher itself) should evict any
+	// cached search results for that language.
+	PublishInvalidation(lang string, entryID int) error
+
+	// SubscribeInvalidation starts a background listener that evicts
+	// affected cache keys as invalidations arrive, additionally invoking fn
+	// (if non-nil) with the (lang, entryID) that was invalidated. It returns
+	// once the subscription is established; the listener itself runs for
+	// the lifetime of the backend.
+	SubscribeInvalidation(fn func(lang string, entryID int)) error
+}
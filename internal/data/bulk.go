@@ -0,0 +1,183 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// BulkResult reports the outcome of importing a single Entry or Relation
+// via BulkInsert/BulkRelate. GUID echoes back the caller-supplied identifier
+// (an entry's own GUID, or a relation's to_guid) so a caller streaming
+// thousands of rows can match a result back to its input without keeping
+// the whole batch in memory.
+type BulkResult struct {
+	GUID  string `json:"guid,omitempty"`
+	ID    int    `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// BulkRelation links an entry to another, already-committed entry addressed
+// by ID where the caller knows it (eg: an entry inserted earlier in the same
+// BulkInsert batch) or by GUID otherwise (eg: a pre-existing entry from an
+// earlier import, or one from an earlier batch of the same import). Exactly
+// one of FromID/FromGUID and one of ToID/ToGUID should be set.
+type BulkRelation struct {
+	FromID   int    `json:"from_id,omitempty"`
+	FromGUID string `json:"from_guid,omitempty"`
+	ToID     int    `json:"to_id,omitempty"`
+	ToGUID   string `json:"to_guid,omitempty"`
+	Relation
+}
+
+// BulkInsert inserts a batch of entries in a single DB transaction, with a
+// savepoint around each row so that one bad row (eg: an unknown language)
+// doesn't roll back its siblings. Results are returned in the same order as
+// entries, one per row, so a caller can line BulkRelate's from/to IDs up
+// against them without a round trip.
+//
+// If dryRun is true, every row is validated and tokenized exactly as normal,
+// but the transaction is rolled back instead of committed, so an import can
+// be checked end-to-end before it writes anything.
+func (d *Data) BulkInsert(entries []Entry, dryRun bool) ([]BulkResult, error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt := tx.Stmtx(d.queries.InsertEntry)
+	out := make([]BulkResult, len(entries))
+
+	for i, e := range entries {
+		res := BulkResult{GUID: e.GUID}
+
+		if _, err := tx.Exec("SAVEPOINT bulk_insert_row"); err != nil {
+			return nil, fmt.Errorf("error creating savepoint: %v", err)
+		}
+
+		id, err := d.insertEntry(e, stmt)
+		if err != nil {
+			res.Error = err.Error()
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT bulk_insert_row"); err != nil {
+				return nil, fmt.Errorf("error rolling back to savepoint: %v", err)
+			}
+		} else {
+			res.ID = id
+			if _, err := tx.Exec("RELEASE SAVEPOINT bulk_insert_row"); err != nil {
+				return nil, fmt.Errorf("error releasing savepoint: %v", err)
+			}
+		}
+
+		out[i] = res
+	}
+
+	if dryRun {
+		return out, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing batch: %v", err)
+	}
+
+	for lang := range bulkInsertedLangs(entries, out) {
+		d.bumpGeneration(lang)
+	}
+
+	return out, nil
+}
+
+// bulkInsertedLangs returns the distinct languages of the rows in entries
+// that actually committed (out[i].Error == ""), so a caller can bump just
+// the generations a batch touched instead of every one.
+func bulkInsertedLangs(entries []Entry, out []BulkResult) map[string]struct{} {
+	langs := make(map[string]struct{})
+	for i, e := range entries {
+		if out[i].Error == "" {
+			langs[e.Lang] = struct{}{}
+		}
+	}
+	return langs
+}
+
+// BulkRelate inserts a batch of relations in a single DB transaction,
+// savepointed per row like BulkInsert, resolving any side addressed by GUID
+// against entries.guid. dryRun behaves as it does for BulkInsert: every
+// relation is resolved and validated, but nothing is committed.
+func (d *Data) BulkRelate(rels []BulkRelation, dryRun bool) ([]BulkResult, error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt := tx.Stmtx(d.queries.InsertRelation)
+	out := make([]BulkResult, len(rels))
+
+	for i, r := range rels {
+		res := BulkResult{GUID: r.ToGUID}
+
+		if _, err := tx.Exec("SAVEPOINT bulk_relate_row"); err != nil {
+			return nil, fmt.Errorf("error creating savepoint: %v", err)
+		}
+
+		id, err := d.bulkRelateRow(tx, r, stmt)
+		if err != nil {
+			res.Error = err.Error()
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT bulk_relate_row"); err != nil {
+				return nil, fmt.Errorf("error rolling back to savepoint: %v", err)
+			}
+		} else {
+			res.ID = id
+			if _, err := tx.Exec("RELEASE SAVEPOINT bulk_relate_row"); err != nil {
+				return nil, fmt.Errorf("error releasing savepoint: %v", err)
+			}
+		}
+
+		out[i] = res
+	}
+
+	if dryRun {
+		return out, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing batch: %v", err)
+	}
+
+	// A relation doesn't carry its entries' lang, so -- same as
+	// InsertRelation -- bump every generation rather than try to resolve
+	// one, but only if the batch actually inserted something.
+	for _, res := range out {
+		if res.Error == "" {
+			d.bumpGeneration("")
+			break
+		}
+	}
+
+	return out, nil
+}
+
+// bulkRelateRow resolves r's from/to entries and inserts the relation using
+// tx-bound stmt, inside the caller's savepoint.
+func (d *Data) bulkRelateRow(tx *sqlx.Tx, r BulkRelation, stmt *sqlx.Stmt) (int, error) {
+	fromID := r.FromID
+	if fromID < 1 {
+		if r.FromGUID == "" {
+			return 0, fmt.Errorf("invalid `from_id`/`from_guid` in relation")
+		}
+		if err := tx.Get(&fromID, `SELECT id FROM entries WHERE guid = $1`, r.FromGUID); err != nil {
+			return 0, fmt.Errorf("error resolving from_guid %q: %v", r.FromGUID, err)
+		}
+	}
+
+	toID := r.ToID
+	if toID < 1 {
+		if r.ToGUID == "" {
+			return 0, fmt.Errorf("invalid `to_id`/`to_guid` in relation")
+		}
+		if err := tx.Get(&toID, `SELECT id FROM entries WHERE guid = $1`, r.ToGUID); err != nil {
+			return 0, fmt.Errorf("error resolving to_guid %q: %v", r.ToGUID, err)
+		}
+	}
+
+	return d.insertRelation(fromID, toID, r.Relation, stmt)
+}
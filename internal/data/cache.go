@@ -0,0 +1,263 @@
+package data
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/knadh/dictpress/internal/cache"
+)
+
+// relationsCacheKey returns a stable key for SearchAndLoadRelations' result
+// on the given entry IDs and q, scoped to the same from/to-lang generation
+// as cacheKey so it's invalidated by the same mutations.
+func (d *Data) relationsCacheKey(ids []int64, q Query) string {
+	sorted := append([]int64{}, ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	idStrs := make([]string, len(sorted))
+	for i, id := range sorted {
+		idStrs[i] = fmt.Sprintf("%d", id)
+	}
+
+	types := append([]string{}, q.Types...)
+	sort.Strings(types)
+	tags := append([]string{}, q.Tags...)
+	sort.Strings(tags)
+
+	raw := fmt.Sprintf("rel:%d:%s:%s:%s:%s:%s",
+		d.generation(q.FromLang, q.ToLang),
+		q.ToLang,
+		strings.Join(idStrs, ","),
+		strings.Join(types, ","),
+		strings.Join(tags, ","),
+		q.Status,
+	)
+
+	h := md5.Sum([]byte(raw))
+	return "d:rel:" + hex.EncodeToString(h[:])
+}
+
+// cacheGetRelations and cachePutRelations store SearchAndLoadRelations'
+// result: a map of entry ID to its loaded Relations, gob-encoded.
+func (d *Data) cacheGetRelations(key string) (map[int][]Entry, bool) {
+	if d.cache == nil {
+		return nil, false
+	}
+
+	b, err := d.cache.Get(key)
+	if err != nil || b == nil {
+		atomic.AddInt64(&d.cacheStats.misses, 1)
+		return nil, false
+	}
+
+	var out map[int][]Entry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&out); err != nil {
+		atomic.AddInt64(&d.cacheStats.misses, 1)
+		return nil, false
+	}
+
+	atomic.AddInt64(&d.cacheStats.hits, 1)
+	return out, true
+}
+
+func (d *Data) cachePutRelations(key string, v map[int][]Entry, ttl *time.Duration) {
+	if d.cache == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return
+	}
+	d.cache.Put(key, buf.Bytes(), ttl)
+}
+
+// CacheConfig configures Data's optional result cache, installed with
+// SetCache. It sits underneath cmd/dictpress's own handler-level results
+// cache (see cmd/dictpress/cache.go) rather than replacing it: that one
+// only covers public, unauthenticated HTTP search requests, whereas this
+// one memoizes Search/SearchAndLoadRelations for every caller (authed
+// requests, the admin API, CLI tooling) that goes through *Data directly.
+type CacheConfig struct {
+	// DefaultTTL is used for a language not listed in LangTTL.
+	DefaultTTL time.Duration
+
+	// LangTTL overrides DefaultTTL for specific Query.FromLang values, so a
+	// slow-moving language can be cached longer than one under active
+	// editing.
+	LangTTL map[string]time.Duration
+
+	// NegativeTTL, if set, is used instead of the above for a query that
+	// matched zero entries -- usually worth caching for a shorter time,
+	// since an empty result is cheap to recompute and more likely to
+	// change (eg: the entry just hasn't been added yet).
+	NegativeTTL time.Duration
+}
+
+// cacheStats holds the process-local hit/miss counters surfaced through
+// GetStats. They're reset on restart; they exist to help an operator tune
+// cache.max_memory_mb and TTLs, not as a durable metric.
+type cacheStats struct {
+	hits   int64
+	misses int64
+}
+
+// SetCache installs store as Data's result cache. Data works fine with no
+// cache set, it just always queries Postgres. Separate from New, like
+// SetNotifier, so the cache backend (which itself may need Data's Langs to
+// size itself, or simply be constructed later) doesn't have to exist before
+// Data does.
+func (d *Data) SetCache(store cache.Store, cfg CacheConfig) {
+	d.cache = store
+	d.cacheCfg = cfg
+	if d.cacheGens == nil {
+		d.cacheGens = map[string]uint64{}
+	}
+}
+
+// cacheTTL returns the TTL to cache q's result under, preferring a
+// negative-result TTL for an empty match, then a per-language override, then
+// DefaultTTL.
+func (d *Data) cacheTTL(q Query, total int) *time.Duration {
+	if total == 0 && d.cacheCfg.NegativeTTL > 0 {
+		ttl := d.cacheCfg.NegativeTTL
+		return &ttl
+	}
+	if ttl, ok := d.cacheCfg.LangTTL[q.FromLang]; ok {
+		return &ttl
+	}
+	if d.cacheCfg.DefaultTTL > 0 {
+		ttl := d.cacheCfg.DefaultTTL
+		return &ttl
+	}
+	return nil
+}
+
+// bumpGeneration invalidates every cached result for the from-lang/to-lang
+// pair by advancing its generation counter, so every key derived from it
+// (via cacheKey) stops matching instead of having to be individually
+// deleted. lang == "" bumps every generation -- used where a mutation
+// (eg: a relation change that only has an entry ID on hand, not its lang)
+// can't cheaply be scoped to one language pair.
+func (d *Data) bumpGeneration(lang string) {
+	if d.cache == nil {
+		return
+	}
+
+	d.cacheGenMu.Lock()
+	defer d.cacheGenMu.Unlock()
+
+	if lang == "" {
+		for k := range d.cacheGens {
+			d.cacheGens[k]++
+		}
+		return
+	}
+
+	for k := range d.cacheGens {
+		// Keys are "fromLang|toLang"; a mutation to lang invalidates any
+		// pair with lang on either side.
+		parts := strings.SplitN(k, "|", 2)
+		if parts[0] == lang || (len(parts) > 1 && parts[1] == lang) {
+			d.cacheGens[k]++
+		}
+	}
+}
+
+// generation returns the current generation counter for the from/to lang
+// pair, defaulting to 0 (and implicitly registering the pair) the first
+// time it's seen -- registering it is what lets a later bumpGeneration,
+// which only iterates keys already in cacheGens, actually find and advance
+// it.
+func (d *Data) generation(fromLang, toLang string) uint64 {
+	key := fromLang + "|" + toLang
+
+	d.cacheGenMu.Lock()
+	defer d.cacheGenMu.Unlock()
+
+	if d.cacheGens == nil {
+		d.cacheGens = map[string]uint64{}
+	}
+	if _, ok := d.cacheGens[key]; !ok {
+		d.cacheGens[key] = 0
+	}
+	return d.cacheGens[key]
+}
+
+// cacheKey returns a stable key for q, scoped to its current
+// from-lang/to-lang generation so a bumpGeneration call retires it without
+// needing to touch the cache backend.
+func (d *Data) cacheKey(q Query) string {
+	types := append([]string{}, q.Types...)
+	sort.Strings(types)
+
+	tags := append([]string{}, q.Tags...)
+	sort.Strings(tags)
+
+	raw := fmt.Sprintf("search:%d:%s:%s:%s:%s:%s:%s:%d:%d:%v:%.3f",
+		d.generation(q.FromLang, q.ToLang),
+		q.FromLang,
+		q.ToLang,
+		strings.ToLower(strings.TrimSpace(q.Query)),
+		strings.Join(types, ","),
+		strings.Join(tags, ","),
+		q.Status,
+		q.Offset,
+		q.Limit,
+		q.Fuzzy,
+		q.MinSimilarity,
+	)
+
+	h := md5.Sum([]byte(raw))
+	return "d:" + hex.EncodeToString(h[:])
+}
+
+// cachedSearch is the gob-serialized unit stored for a cacheKey(q).
+type cachedSearch struct {
+	Entries []Entry
+	Total   int
+}
+
+// cacheGet unmarshals a cachedSearch stored under key, returning ok=false on
+// a miss or a decode error (treated the same as a miss -- the caller just
+// falls through to Postgres).
+func (d *Data) cacheGet(key string) (cachedSearch, bool) {
+	if d.cache == nil {
+		return cachedSearch{}, false
+	}
+
+	b, err := d.cache.Get(key)
+	if err != nil || b == nil {
+		atomic.AddInt64(&d.cacheStats.misses, 1)
+		return cachedSearch{}, false
+	}
+
+	var out cachedSearch
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&out); err != nil {
+		atomic.AddInt64(&d.cacheStats.misses, 1)
+		return cachedSearch{}, false
+	}
+
+	atomic.AddInt64(&d.cacheStats.hits, 1)
+	return out, true
+}
+
+// cachePut stores v under key with ttl (nil uses the backend's default).
+func (d *Data) cachePut(key string, v cachedSearch, ttl *time.Duration) {
+	if d.cache == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return
+	}
+	d.cache.Put(key, buf.Bytes(), ttl)
+}
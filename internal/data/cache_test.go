@@ -0,0 +1,93 @@
+package data
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/knadh/dictpress/internal/cache"
+	"gotest.tools/v3/assert"
+)
+
+var lo = log.New(os.Stdout, "data: ", log.LstdFlags)
+
+func newTestDataCache(t *testing.T) *Data {
+	t.Helper()
+
+	c, err := cache.New(cache.Config{TTL: time.Minute, Mode: cache.CacheTypeMemory}, lo)
+	assert.NilError(t, err)
+	t.Cleanup(func() { c.Close() })
+
+	d := &Data{}
+	d.SetCache(c, CacheConfig{DefaultTTL: time.Minute})
+
+	return d
+}
+
+func TestGenerationDefaultsToZero(t *testing.T) {
+	d := newTestDataCache(t)
+
+	assert.Equal(t, d.generation("en", "ta"), uint64(0))
+}
+
+func TestBumpGenerationScopedToLang(t *testing.T) {
+	d := newTestDataCache(t)
+
+	q := Query{FromLang: "en", ToLang: "ta"}
+	before := d.cacheKey(q)
+
+	// A mutation to an unrelated lang pair shouldn't change en|ta's key.
+	d.bumpGeneration("hi")
+	assert.Equal(t, d.cacheKey(q), before)
+
+	// A mutation naming either side of en|ta invalidates it.
+	d.bumpGeneration("en")
+	assert.Assert(t, d.cacheKey(q) != before)
+}
+
+func TestBumpGenerationEmptyLangBumpsEverything(t *testing.T) {
+	d := newTestDataCache(t)
+
+	enTa := Query{FromLang: "en", ToLang: "ta"}
+	hiEn := Query{FromLang: "hi", ToLang: "en"}
+	beforeEnTa := d.cacheKey(enTa)
+	beforeHiEn := d.cacheKey(hiEn)
+
+	d.bumpGeneration("")
+
+	assert.Assert(t, d.cacheKey(enTa) != beforeEnTa)
+	assert.Assert(t, d.cacheKey(hiEn) != beforeHiEn)
+}
+
+func TestCacheGetPutRoundtrip(t *testing.T) {
+	d := newTestDataCache(t)
+
+	q := Query{FromLang: "en", ToLang: "ta"}
+	key := d.cacheKey(q)
+
+	_, ok := d.cacheGet(key)
+	assert.Assert(t, !ok)
+
+	want := cachedSearch{Entries: []Entry{{ID: 1, Content: "hello"}}, Total: 1}
+	d.cachePut(key, want, nil)
+
+	got, ok := d.cacheGet(key)
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, got, want)
+
+	// Bumping the generation retires the key even though the backend still
+	// holds the stale value under it.
+	d.bumpGeneration("en")
+	_, ok = d.cacheGet(d.cacheKey(q))
+	assert.Assert(t, !ok)
+}
+
+func TestBumpGenerationNoopWithoutCache(t *testing.T) {
+	d := &Data{}
+
+	// No cache installed -- bumpGeneration/generation must be safe no-ops
+	// rather than panicking on a nil cacheGens map.
+	d.bumpGeneration("en")
+	assert.Equal(t, d.generation("en", "ta"), uint64(0))
+}
@@ -0,0 +1,325 @@
+package data
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// copyTokenizeWorkers is the number of goroutines tokenizing entries
+// concurrently ahead of a *Copy bulk insert, so a language backed by an
+// external (eg: wasm, HTTP) tokenizer doesn't serialize hundreds of
+// thousands of round trips onto one goroutine before the COPY even starts.
+const copyTokenizeWorkers = 8
+
+// preparedEntry is an Entry with its tokens/tsVectorLang already resolved
+// by tokenizeConcurrently, ready to stage without touching a tokenizer
+// again, or the error tokenizing it hit.
+type preparedEntry struct {
+	entry        Entry
+	tokens       string
+	tsVectorLang string
+	err          error
+}
+
+// tokenizeConcurrently resolves tokenizeForInsert for every entry across
+// copyTokenizeWorkers goroutines, preserving entries' order in the result so
+// callers can still report a BulkResult per row by index.
+func (d *Data) tokenizeConcurrently(entries []Entry) []preparedEntry {
+	out := make([]preparedEntry, len(entries))
+
+	type job struct {
+		i int
+		e Entry
+	}
+	jobs := make(chan job)
+
+	var wg sync.WaitGroup
+	wg.Add(copyTokenizeWorkers)
+	for w := 0; w < copyTokenizeWorkers; w++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				tokens, tsVectorLang, err := d.tokenizeForInsert(j.e)
+				out[j.i] = preparedEntry{entry: j.e, tokens: tokens, tsVectorLang: tsVectorLang, err: err}
+			}
+		}()
+	}
+
+	for i, e := range entries {
+		jobs <- job{i: i, e: e}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return out
+}
+
+// BulkInsertEntriesCopy inserts entries the same way BulkInsert does --
+// one DB transaction, a BulkResult per row in the same order as entries,
+// and a bad row reported without discarding its siblings -- but loads rows
+// through a `COPY FROM STDIN`-backed staging table instead of one prepared
+// INSERT per row, and tokenizes every row concurrently up front instead of
+// serially during the insert. It exists for the same entries BulkInsert
+// handles, just at the scale (hundreds of thousands of rows, eg: a
+// Wiktionary dump) where one INSERT per row is the bottleneck.
+//
+// COPY itself can't skip a single bad row -- one rejected row aborts the
+// whole copy -- so staged rows are still merged into entries one at a time,
+// each inside its own savepoint, via the same prepared statement BulkInsert
+// uses. The saving is in how they get onto that connection in the first
+// place: COPY's binary wire protocol instead of N round trips of bind +
+// execute.
+//
+// If dryRun is true, rows are staged and merged exactly as normal, but the
+// transaction is rolled back instead of committed.
+func (d *Data) BulkInsertEntriesCopy(entries []Entry, dryRun bool) ([]BulkResult, error) {
+	prepared := d.tokenizeConcurrently(entries)
+	out := make([]BulkResult, len(entries))
+
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`CREATE TEMP TABLE bulk_stage_entries (
+		row_num int, content text, initial text, weight double precision,
+		tokens text, ts_vector_lang text, lang text, tags text[], phones text[],
+		notes text, status text, spam_score double precision
+	) ON COMMIT DROP`); err != nil {
+		return nil, fmt.Errorf("error creating staging table: %v", err)
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("bulk_stage_entries",
+		"row_num", "content", "initial", "weight", "tokens", "ts_vector_lang",
+		"lang", "tags", "phones", "notes", "status", "spam_score"))
+	if err != nil {
+		return nil, fmt.Errorf("error preparing COPY: %v", err)
+	}
+
+	for i, p := range prepared {
+		out[i] = BulkResult{GUID: p.entry.GUID}
+		if p.err != nil {
+			out[i].Error = p.err.Error()
+			continue
+		}
+
+		status := p.entry.Status
+		if status == "" {
+			status = StatusEnabled
+		}
+
+		if _, err := stmt.Exec(i, p.entry.Content, p.entry.Initial, p.entry.Weight,
+			p.tokens, p.tsVectorLang, p.entry.Lang, p.entry.Tags, p.entry.Phones,
+			p.entry.Notes, status, p.entry.SpamScore); err != nil {
+			return nil, fmt.Errorf("error staging row %d: %v", i, err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return nil, fmt.Errorf("error flushing COPY: %v", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return nil, fmt.Errorf("error closing COPY: %v", err)
+	}
+
+	type stagedEntry struct {
+		rowNum                    int
+		content, initial, tokens  string
+		tsVectorLang, lang, notes string
+		status                    string
+		weight, spamScore         float64
+		tags, phones              pq.StringArray
+	}
+
+	// Read every staged row back before issuing any other statement on tx:
+	// Postgres's wire protocol can't interleave a running query with a
+	// fresh Exec on the same connection.
+	var staged []stagedEntry
+	rows, err := tx.Queryx(`SELECT row_num, content, initial, weight, tokens,
+		ts_vector_lang, lang, tags, phones, notes, status, spam_score
+		FROM bulk_stage_entries ORDER BY row_num`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading staged rows: %v", err)
+	}
+	for rows.Next() {
+		var s stagedEntry
+		if err := rows.Scan(&s.rowNum, &s.content, &s.initial, &s.weight, &s.tokens,
+			&s.tsVectorLang, &s.lang, &s.tags, &s.phones, &s.notes, &s.status, &s.spamScore); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning staged row: %v", err)
+		}
+		staged = append(staged, s)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("error iterating staged rows: %v", err)
+	}
+	rows.Close()
+
+	insStmt := tx.Stmtx(d.queries.InsertEntry)
+	touchedLangs := make(map[string]struct{})
+	for _, s := range staged {
+		e := Entry{
+			Content: s.content, Initial: s.initial, Weight: s.weight, Lang: s.lang,
+			Tags: s.tags, Phones: s.phones, Notes: s.notes, Status: s.status, SpamScore: s.spamScore,
+		}
+
+		if _, err := tx.Exec("SAVEPOINT bulk_copy_row"); err != nil {
+			return nil, fmt.Errorf("error creating savepoint: %v", err)
+		}
+
+		id, err := d.insertEntryTokenized(e, s.tokens, s.tsVectorLang, insStmt)
+		if err != nil {
+			out[s.rowNum].Error = err.Error()
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT bulk_copy_row"); err != nil {
+				return nil, fmt.Errorf("error rolling back to savepoint: %v", err)
+			}
+		} else {
+			out[s.rowNum].ID = id
+			touchedLangs[s.lang] = struct{}{}
+			if _, err := tx.Exec("RELEASE SAVEPOINT bulk_copy_row"); err != nil {
+				return nil, fmt.Errorf("error releasing savepoint: %v", err)
+			}
+		}
+	}
+
+	if dryRun {
+		return out, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing batch: %v", err)
+	}
+
+	for lang := range touchedLangs {
+		d.bumpGeneration(lang)
+	}
+
+	return out, nil
+}
+
+// BulkInsertRelationsCopy relates entries the same way BulkRelate does, but
+// loads rows through a `COPY FROM STDIN`-backed staging table first, same
+// tradeoff as BulkInsertEntriesCopy: the expensive part (getting rows onto
+// the connection) is batched, the row-at-a-time part (resolving
+// from_guid/to_guid, the per-row savepoint) still runs serially because it
+// depends on data only Postgres has.
+//
+// If dryRun is true, relations are staged and merged exactly as normal, but
+// the transaction is rolled back instead of committed.
+func (d *Data) BulkInsertRelationsCopy(rels []BulkRelation, dryRun bool) ([]BulkResult, error) {
+	out := make([]BulkResult, len(rels))
+
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`CREATE TEMP TABLE bulk_stage_relations (
+		row_num int, from_id int, from_guid text, to_id int, to_guid text,
+		types text[], tags text[], notes text, weight double precision, status text
+	) ON COMMIT DROP`); err != nil {
+		return nil, fmt.Errorf("error creating staging table: %v", err)
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("bulk_stage_relations",
+		"row_num", "from_id", "from_guid", "to_id", "to_guid",
+		"types", "tags", "notes", "weight", "status"))
+	if err != nil {
+		return nil, fmt.Errorf("error preparing COPY: %v", err)
+	}
+
+	for i, r := range rels {
+		out[i] = BulkResult{GUID: r.ToGUID}
+
+		status := r.Status
+		if status == "" {
+			status = StatusEnabled
+		}
+
+		if _, err := stmt.Exec(i, r.FromID, r.FromGUID, r.ToID, r.ToGUID,
+			r.Types, r.Tags, r.Notes, r.Weight, status); err != nil {
+			return nil, fmt.Errorf("error staging row %d: %v", i, err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return nil, fmt.Errorf("error flushing COPY: %v", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return nil, fmt.Errorf("error closing COPY: %v", err)
+	}
+
+	type stagedRelation struct {
+		rowNum           int
+		fromID, toID     int
+		fromGUID, toGUID string
+		types, tags      pq.StringArray
+		notes, status    string
+		weight           float64
+	}
+
+	var staged []stagedRelation
+	rows, err := tx.Queryx(`SELECT row_num, from_id, from_guid, to_id, to_guid,
+		types, tags, notes, weight, status
+		FROM bulk_stage_relations ORDER BY row_num`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading staged rows: %v", err)
+	}
+	for rows.Next() {
+		var s stagedRelation
+		if err := rows.Scan(&s.rowNum, &s.fromID, &s.fromGUID, &s.toID, &s.toGUID,
+			&s.types, &s.tags, &s.notes, &s.weight, &s.status); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning staged row: %v", err)
+		}
+		staged = append(staged, s)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("error iterating staged rows: %v", err)
+	}
+	rows.Close()
+
+	insStmt := tx.Stmtx(d.queries.InsertRelation)
+	for _, s := range staged {
+		r := BulkRelation{
+			FromID: s.fromID, FromGUID: s.fromGUID, ToID: s.toID, ToGUID: s.toGUID,
+			Relation: Relation{Types: s.types, Tags: s.tags, Notes: s.notes, Weight: s.weight, Status: s.status},
+		}
+
+		if _, err := tx.Exec("SAVEPOINT bulk_copy_row"); err != nil {
+			return nil, fmt.Errorf("error creating savepoint: %v", err)
+		}
+
+		id, err := d.bulkRelateRow(tx, r, insStmt)
+		if err != nil {
+			out[s.rowNum].Error = err.Error()
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT bulk_copy_row"); err != nil {
+				return nil, fmt.Errorf("error rolling back to savepoint: %v", err)
+			}
+		} else {
+			out[s.rowNum].ID = id
+			if _, err := tx.Exec("RELEASE SAVEPOINT bulk_copy_row"); err != nil {
+				return nil, fmt.Errorf("error releasing savepoint: %v", err)
+			}
+		}
+	}
+
+	if dryRun {
+		return out, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing batch: %v", err)
+	}
+
+	for _, res := range out {
+		if res.Error == "" {
+			d.bumpGeneration("")
+			break
+		}
+	}
+
+	return out, nil
+}
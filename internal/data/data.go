@@ -1,12 +1,16 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/knadh/dictpress/internal/cache"
 	"github.com/lib/pq"
 )
 
@@ -16,6 +20,20 @@ const (
 	StatusDisabled = "disabled"
 )
 
+const (
+	// MatchTypeExact marks a result that matched the tokenized tsquery.
+	MatchTypeExact = "exact"
+	// MatchTypeFuzzy marks a result that only matched the pg_trgm similarity fallback.
+	MatchTypeFuzzy = "fuzzy"
+
+	// defaultMinSimilarity is used when Query.Fuzzy is set but
+	// Query.MinSimilarity isn't.
+	defaultMinSimilarity = 0.3
+
+	// suggestLimit caps the number of "did you mean ...?" terms Suggest returns.
+	suggestLimit = 5
+)
+
 // Lang represents a language's configuration.
 type Lang struct {
 	Name          string            `json:"name"`
@@ -23,11 +41,51 @@ type Lang struct {
 	TokenizerName string            `json:"tokenizer"`
 	TokenizerType string            `json:"tokenizer_type"`
 	Tokenizer     Tokenizer         `json:"-"`
+
+	// TokenizerConfigs optionally names more than one tokenizer to run
+	// in sequence for this language, each tagged with the tsvector weight
+	// its tokens are merged in with (see ResolvedTokenizer). When this is
+	// set, it takes precedence over the single TokenizerName/Tokenizer
+	// above, which stay in place so existing single-tokenizer configs and
+	// callers keep working unchanged.
+	TokenizerConfigs []TokenizerConfig `json:"tokenizers"`
+
+	// Tokenizers is the pipeline resolved from TokenizerConfigs by
+	// initLangs(); nil unless TokenizerConfigs is set.
+	Tokenizers []ResolvedTokenizer `json:"-"`
+
+	// NotesMarkdown enables rendering Entry.Notes/Relation.Notes as
+	// Markdown for this language. Off by default so existing plain-text
+	// notes keep rendering verbatim.
+	NotesMarkdown bool `json:"notes_markdown"`
+
+	// NotesAutoLink turns on auto-linking of bare URLs in rendered notes.
+	// Some scripts shouldn't auto-link, so it's opt-in independently of
+	// NotesMarkdown.
+	NotesAutoLink bool `json:"notes_autolink"`
 }
 
 // LangMap represents a map of language controllers indexed by the language key.
 type LangMap map[string]Lang
 
+// Dicts is the set of from-lang/to-lang pairs (app.dicts in config.toml)
+// the site offers for translation, in configured order.
+type Dicts [][2]Lang
+
+// Notifier receives a notification after Data commits an entry or relation
+// mutation, so that callers (eg: a public SSE change stream, a static-site
+// rebuilder, a search-index mirror) can react to dictionary changes instead
+// of polling. It's optional: Data works fine with no Notifier set, it just
+// skips notifying.
+type Notifier interface {
+	// Notify is called after a mutation succeeds. op identifies what
+	// happened (eg: "entry_inserted", "relation_deleted"), id is the
+	// affected entry id (or, for a relation op, the from-entry id), and
+	// lang is its language when Data has it on hand without an extra
+	// query -- empty otherwise.
+	Notify(op string, id int, lang string)
+}
+
 // Tokenizer represents a function that takes a string
 // and returns a list of Postgres tsvector tokens.
 type Tokenizer interface {
@@ -40,6 +98,22 @@ type Tokenizer interface {
 	ToQuery(s string, lang string) (string, error)
 }
 
+// TokenizerConfig names one tokenizer in a language's TokenizerConfigs
+// pipeline and the tsvector weight (1 highest priority .. 4 lowest,
+// matching Postgres' 'A'..'D' weight labels) its tokens are tagged with
+// once merged with tokens from the language's other configured tokenizers.
+type TokenizerConfig struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// ResolvedTokenizer pairs a loaded Tokenizer with the weight its tokens
+// are tagged with in a multi-tokenizer Lang.Tokenizers pipeline.
+type ResolvedTokenizer struct {
+	Tokenizer Tokenizer
+	Weight    int
+}
+
 // Token represents a Postgres tsvector token.
 type Token struct {
 	Token  string
@@ -62,6 +136,8 @@ type Queries struct {
 	DeleteEntry        *sqlx.Stmt `query:"delete-entry"`
 	DeleteRelation     *sqlx.Stmt `query:"delete-relation"`
 	GetStats           *sqlx.Stmt `query:"get-stats"`
+	SearchFuzzy        *sqlx.Stmt `query:"search-fuzzy"`
+	SearchSuggestions  *sqlx.Stmt `query:"search-suggestions"`
 
 	GetPendingEntries        *sqlx.Stmt `query:"get-pending-entries"`
 	InsertSubmissionEntry    *sqlx.Stmt `query:"insert-submission-entry"`
@@ -71,12 +147,35 @@ type Queries struct {
 	DeleteComments           *sqlx.Stmt `query:"delete-comments"`
 	ApproveSubmission        *sqlx.Stmt `query:"approve-submission"`
 	RejectSubmission         *sqlx.Stmt `query:"reject-submission"`
+	DeleteAllPending         *sqlx.Stmt `query:"delete-all-pending"`
+	InsertSubmissionAttempt  *sqlx.Stmt `query:"insert-submission-attempt"`
+
+	GetEntriesForSitemap *sqlx.Stmt `query:"get-entries-for-sitemap"`
 }
 
 // Data represents the dictionary search interface.
 type Data struct {
 	queries *Queries
 	Langs   LangMap
+	Dicts   Dicts
+	db      *sqlx.DB
+
+	notifier Notifier
+
+	// cache, installed via SetCache, memoizes Search/SearchAndLoadRelations.
+	// Nil unless SetCache was called, in which case every lookup below is a
+	// no-op and callers go straight to Postgres.
+	cache    cache.Store
+	cacheCfg CacheConfig
+
+	// cacheGenMu guards cacheGens, the per-(from_lang,to_lang) generation
+	// counters that bumpGeneration advances and cacheKey reads, so a
+	// mutation invalidates every key derived from the pair without the
+	// cache backend supporting surgical deletes.
+	cacheGenMu sync.Mutex
+	cacheGens  map[string]uint64
+
+	cacheStats cacheStats
 }
 
 // Query represents the parameters of a single search query.
@@ -89,20 +188,85 @@ type Query struct {
 	Status   string   `json:"status"`
 	Offset   int      `json:"offset"`
 	Limit    int      `json:"limit"`
+
+	// Page and PerPage are the raw pagination inputs a handler feeds to its
+	// *paginator.Paginator to compute Offset/Limit above; query:"" tags let
+	// them bind straight off ?page=&per_page= on GET requests.
+	Page    int `json:"page" query:"page"`
+	PerPage int `json:"per_page" query:"per_page"`
+
+	// MaxRelations and MaxContentItems cap, per entry, how many relations of
+	// each type and content items a theme template renders on a results
+	// page, set from the site's consts rather than the request.
+	MaxRelations    int `json:"-"`
+	MaxContentItems int `json:"-"`
+
+	// Fuzzy enables the pg_trgm similarity fallback search when the
+	// tokenized tsquery yields no rows.
+	Fuzzy bool `json:"fuzzy"`
+
+	// MinSimilarity is the minimum pg_trgm similarity() score (0-1) a row
+	// must have to be considered a fuzzy match. Defaults to
+	// defaultMinSimilarity when unset.
+	MinSimilarity float32 `json:"min_similarity"`
 }
 
 // New returns an instance of the search interface.
-func New(q *Queries, langs LangMap) *Data {
+func New(q *Queries, langs LangMap, dicts Dicts, db *sqlx.DB) *Data {
 	return &Data{
 		queries: q,
 		Langs:   langs,
+		Dicts:   dicts,
+		db:      db,
+	}
+}
+
+// SetNotifier installs n as the Notifier that's called after every entry/
+// relation mutation. Separate from New so the Notifier (eg: an SSE hub) can
+// be constructed after Data, and so tests can skip it entirely.
+func (d *Data) SetNotifier(n Notifier) {
+	d.notifier = n
+}
+
+// notify calls the installed Notifier, if any.
+func (d *Data) notify(op string, id int, lang string) {
+	if d.notifier != nil {
+		d.notifier.Notify(op, id, lang)
 	}
 }
 
-// Search returns the entries filtered and paginated by a
-// given Query along with the total number of matches in the
-// database.
-func (d *Data) Search(q Query) ([]Entry, int, error) {
+// Search returns the entries filtered and paginated by a given Query along
+// with the total number of matches in the database. ctx bounds the query
+// and is cancelled by the caller, for instance when the HTTP client
+// disconnects or a configured query timeout elapses.
+//
+// When a cache was installed with SetCache, a hit for q's current
+// generation (see cacheKey) is served without touching Postgres at all; a
+// miss falls through to doSearch and caches its result before returning.
+func (d *Data) Search(ctx context.Context, q Query) ([]Entry, int, error) {
+	if d.cache == nil {
+		return d.doSearch(ctx, q)
+	}
+
+	key := d.cacheKey(q)
+	if c, ok := d.cacheGet(key); ok {
+		return c.Entries, c.Total, nil
+	}
+
+	out, total, err := d.doSearch(ctx, q)
+	if err != nil {
+		return out, total, err
+	}
+
+	d.cachePut(key, cachedSearch{Entries: out, Total: total}, d.cacheTTL(q, total))
+	return out, total, nil
+}
+
+// doSearch runs q against Postgres, bypassing the cache. Split out of
+// Search so the cache wrapper above has a single place to fall through to
+// regardless of which of Search's several early-return paths (exact match,
+// fuzzy fallback, empty result) was taken.
+func (d *Data) doSearch(ctx context.Context, q Query) ([]Entry, int, error) {
 	// Is there a Tokenizer?
 	var (
 		tsVectorLang  = ""
@@ -120,10 +284,24 @@ func (d *Data) Search(q Query) ([]Entry, int, error) {
 		tk     = lang.Tokenizer
 	)
 
-	if tk == nil {
+	switch {
+	case len(lang.Tokenizers) > 0:
+		// A multi-tokenizer pipeline is configured. Run the query through
+		// every tokenizer and OR-combine their tsqueries.
+		var err error
+		tsVectorQuery, err = pipelineQuery(lang.Tokenizers, q.Query, q.FromLang)
+		if err != nil {
+			return nil, 0, err
+		}
+		if tsVectorQuery == "" {
+			tsVectorLang = "simple"
+		}
+
+	case tk == nil:
 		// No external tokenizer. Use the Postgres tokenizer name.
 		tsVectorLang = tkName
-	} else {
+
+	default:
 		// If there's an external tokenizer loaded, run it to get the tokens
 		// and pass it to the DB directly instructing the DB not to tokenize internally.
 		var err error
@@ -131,6 +309,14 @@ func (d *Data) Search(q Query) ([]Entry, int, error) {
 		if err != nil {
 			return nil, 0, err
 		}
+
+		// The tokenizer produced no tokens for this query (eg: a script or
+		// word it doesn't recognize). Fall back to Postgres' "simple"
+		// dictionary, which just normalizes and matches words as-is, instead
+		// of searching on an empty tsquery and always yielding zero rows.
+		if tsVectorQuery == "" {
+			tsVectorLang = "simple"
+		}
 	}
 
 	// Filters ($1 to $3)
@@ -143,7 +329,7 @@ func (d *Data) Search(q Query) ([]Entry, int, error) {
 	// $7 - offset
 	// $8 - limit
 
-	if err := d.queries.Search.Select(&out,
+	if err := d.queries.Search.SelectContext(ctx, &out,
 		q.Query,
 		tsVectorLang,
 		tsVectorQuery,
@@ -160,6 +346,9 @@ func (d *Data) Search(q Query) ([]Entry, int, error) {
 	}
 
 	if len(out) == 0 {
+		if q.Fuzzy {
+			return d.searchFuzzy(ctx, q)
+		}
 		return []Entry{}, 0, nil
 	}
 
@@ -168,11 +357,89 @@ func (d *Data) Search(q Query) ([]Entry, int, error) {
 		if out[i].Relations == nil {
 			out[i].Relations = []Entry{}
 		}
+		out[i].MatchType = MatchTypeExact
+	}
+
+	return out, out[0].Total, nil
+}
+
+// searchFuzzy falls back to a pg_trgm similarity search on entries.content
+// when the tokenized tsquery yields no exact matches, so that a single typo
+// doesn't produce an empty result page. Results are tagged MatchTypeFuzzy so
+// themes can render a "did you mean ...?" hint.
+func (d *Data) searchFuzzy(ctx context.Context, q Query) ([]Entry, int, error) {
+	minSim := q.MinSimilarity
+	if minSim <= 0 {
+		minSim = defaultMinSimilarity
+	}
+
+	var out []Entry
+	if err := d.queries.SearchFuzzy.SelectContext(ctx, &out,
+		q.Query,
+		minSim,
+		q.FromLang,
+		pq.StringArray(q.Tags),
+		q.Status,
+		q.Offset, q.Limit,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return []Entry{}, 0, nil
+		}
+
+		return nil, 0, err
+	}
+
+	if len(out) == 0 {
+		return []Entry{}, 0, nil
+	}
+
+	for i := range out {
+		if out[i].Relations == nil {
+			out[i].Relations = []Entry{}
+		}
+		out[i].MatchType = MatchTypeFuzzy
 	}
 
 	return out, out[0].Total, nil
 }
 
+// Suggest returns up to suggestLimit "did you mean ...?" terms for q.Query,
+// meant to be called after Search (and, if enabled, searchFuzzy) return no
+// rows so a typo doesn't leave the caller facing a bare empty result page.
+// Candidates are ranked by a blend of pg_trgm trigram similarity on
+// entries.content and, when the language has a phonetic tokenizer, the
+// Levenshtein edit distance between the query's and each candidate's
+// phonetic tokens.
+func (d *Data) Suggest(ctx context.Context, q Query) ([]Suggestion, error) {
+	lang, ok := d.Langs[q.FromLang]
+	if !ok {
+		return nil, fmt.Errorf("unknown language %s", q.FromLang)
+	}
+
+	// If there's an external phonetic tokenizer, tokenize the query so the
+	// DB can rank candidates by phonetic distance alongside trigram
+	// similarity. Without one, phonetic ranking is skipped and $2 is empty.
+	var phonetic string
+	if lang.Tokenizer != nil {
+		toks, err := lang.Tokenizer.ToTokens(q.Query, q.FromLang)
+		if err != nil {
+			return nil, fmt.Errorf("error tokenizing query: %v", err)
+		}
+		phonetic = strings.Join(toks, " ")
+	}
+
+	var out []Suggestion
+	if err := d.queries.SearchSuggestions.SelectContext(ctx, &out,
+		q.Query, phonetic, q.FromLang, q.Status, suggestLimit); err != nil {
+		if err == sql.ErrNoRows {
+			return []Suggestion{}, nil
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
+
 // GetPendingEntries fetches entries based on the given condition.
 func (d *Data) GetPendingEntries(lang string, tags pq.StringArray, offset, limit int) ([]Entry, int, error) {
 	var out []Entry
@@ -235,10 +502,10 @@ func (d *Data) GetGlossaryWords(lang, initial string, offset, limit int) ([]Glos
 	return out, out[0].Total, nil
 }
 
-// GetEntry returns an entry by its id.
-func (d *Data) GetEntry(id int) (Entry, error) {
+// GetEntry returns an entry by its id, or by its guid when id is 0.
+func (d *Data) GetEntry(id int, guid string) (Entry, error) {
 	var out Entry
-	if err := d.queries.GetEntry.Get(&out, id); err != nil {
+	if err := d.queries.GetEntry.Get(&out, id, guid); err != nil {
 		return out, err
 	}
 
@@ -258,6 +525,10 @@ func (d *Data) GetParentEntries(id int) ([]Entry, error) {
 // InsertEntry inserts a new non-unique (content+lang) dictionary entry and returns its id.
 func (d *Data) InsertEntry(e Entry) (int, error) {
 	id, err := d.insertEntry(e, d.queries.InsertEntry)
+	if err == nil {
+		d.notify("entry_inserted", id, e.Lang)
+		d.bumpGeneration(e.Lang)
+	}
 	return id, err
 }
 
@@ -285,12 +556,23 @@ func (d *Data) UpdateEntry(id int, e Entry) error {
 		e.Phones,
 		e.Notes,
 		e.Status)
+	if err == nil {
+		d.notify("entry_updated", id, e.Lang)
+		d.bumpGeneration(e.Lang)
+	}
 	return err
 }
 
 // InsertRelation adds a non-unique relation between to entries.
 func (d *Data) InsertRelation(fromID, toID int, r Relation) (int, error) {
 	id, err := d.insertRelation(fromID, toID, r, d.queries.InsertRelation)
+	if err == nil {
+		d.notify("relation_inserted", fromID, "")
+		// A relation doesn't carry its entries' languages at this layer, so
+		// invalidate every generation rather than guess which pair it
+		// affects.
+		d.bumpGeneration("")
+	}
 	return id, err
 }
 
@@ -308,24 +590,42 @@ func (d *Data) UpdateRelation(id int, r Relation) error {
 		r.Tags,
 		r.Notes,
 		r.Weight)
+	if err == nil {
+		d.notify("relation_updated", id, "")
+		d.bumpGeneration("")
+	}
 	return err
 }
 
 // ReorderRelations updates the weights of the given relation IDs in the given order.
 func (d *Data) ReorderRelations(ids []int) error {
 	_, err := d.queries.ReorderRelations.Exec(pq.Array(ids))
+	if err == nil && len(ids) > 0 {
+		d.notify("relation_reordered", ids[0], "")
+		d.bumpGeneration("")
+	}
 	return err
 }
 
 // DeleteEntry deletes a dictionary entry by its id.
 func (d *Data) DeleteEntry(id int) error {
 	_, err := d.queries.DeleteEntry.Exec(id)
+	if err == nil {
+		d.notify("entry_deleted", id, "")
+		// The language isn't known at this layer without an extra lookup;
+		// invalidate everything rather than serve stale results for it.
+		d.bumpGeneration("")
+	}
 	return err
 }
 
 // DeleteRelation deletes a dictionary entry by its id.
 func (s *Data) DeleteRelation(fromID, toID int) error {
 	_, err := s.queries.DeleteRelation.Exec(fromID, toID)
+	if err == nil {
+		s.notify("relation_deleted", fromID, "")
+		s.bumpGeneration("")
+	}
 	return err
 }
 
@@ -352,7 +652,18 @@ func (d *Data) DeleteComments(id int) error {
 	return err
 }
 
-// GetStats returns DB stats.
+// InsertSubmissionAttempt records a throttled or rejected public submission
+// attempt (rate limit, CAPTCHA, blocklist ...) for admin audit. lang may be
+// empty for submissions (eg: comments) that aren't tied to a language.
+func (d *Data) InsertSubmissionAttempt(ip, lang, reason string) error {
+	_, err := d.queries.InsertSubmissionAttempt.Exec(ip, lang, reason)
+	return err
+}
+
+// GetStats returns DB stats, plus the result-cache hit/miss counters
+// accumulated since boot when a cache is installed (see SetCache) -- an
+// operator comparing CacheHits against CacheMisses is the signal for
+// whether it's worth raising cache.max_memory_mb or a language's TTL.
 func (d *Data) GetStats() (Stats, error) {
 	var (
 		out Stats
@@ -362,58 +673,121 @@ func (d *Data) GetStats() (Stats, error) {
 		return out, err
 	}
 
-	err := json.Unmarshal(b, &out)
+	if err := json.Unmarshal(b, &out); err != nil {
+		return out, err
+	}
+
+	if d.cache != nil {
+		out.CacheHits = atomic.LoadInt64(&d.cacheStats.hits)
+		out.CacheMisses = atomic.LoadInt64(&d.cacheStats.misses)
+	}
 
-	return out, err
+	return out, nil
 }
 
 // ApproveSubmission approves a pending submission (entry, relations, related entries).
 func (d *Data) ApproveSubmission(id int) error {
 	_, err := d.queries.ApproveSubmission.Exec(id)
+	if err == nil {
+		// A submission can bring in entries/relations for any language, and
+		// the affected set isn't known without re-querying it, so
+		// invalidate the whole cache rather than under-invalidate.
+		d.bumpGeneration("")
+	}
 	return err
 }
 
 // RejectSubmission rejects a pending submission and deletes related pending entries.
 func (d *Data) RejectSubmission(id int) error {
 	_, err := d.queries.RejectSubmission.Exec(id)
+	if err == nil {
+		d.bumpGeneration("")
+	}
+	return err
+}
+
+// DeleteAllPending deletes every entry and relation still in the pending
+// state, eg: after a moderator bulk-rejects a stale backlog of submissions.
+func (d *Data) DeleteAllPending() error {
+	_, err := d.queries.DeleteAllPending.Exec()
+	if err == nil {
+		d.bumpGeneration("")
+	}
 	return err
 }
 
 func (d *Data) insertEntry(e Entry, stmt *sqlx.Stmt) (int, error) {
+	tokens, tsVectorLang, err := d.tokenizeForInsert(e)
+	if err != nil {
+		return 0, err
+	}
+
+	return d.insertEntryTokenized(e, tokens, tsVectorLang, stmt)
+}
+
+// tokenizeForInsert computes the tokens/tsVectorLang pair insertEntry passes
+// to stmt, unless e already carries its own pre-computed e.Tokens (eg: a
+// caller that already ran tokenization itself, as BulkInsertEntries does in
+// a worker pool ahead of the COPY, so the transaction that follows never
+// blocks on an external tokenizer call).
+func (d *Data) tokenizeForInsert(e Entry) (tokens string, tsVectorLang string, err error) {
 	lang, ok := d.Langs[e.Lang]
 	if !ok {
-		return 0, fmt.Errorf("unknown language %s", e.Lang)
+		return "", "", fmt.Errorf("unknown language %s", e.Lang)
 	}
 
-	// No tokens. Automatically generate.
-	var (
-		tsVectorLang = ""
-		tokens       = e.Tokens
-	)
-	if len(e.Tokens) == 0 {
-		if lang.Tokenizer == nil {
-			// No external tokenizer. Use the Postgres tokenizer name.
-			tsVectorLang = lang.TokenizerName
-		} else {
-			// If there's an external tokenizer loaded, run it to get the tokens
-			// and pass it to the DB directly instructing the DB not to tokenize internally.
-			t, err := lang.Tokenizer.ToTokens(e.Content, e.Lang)
-			if err != nil {
-				return 0, nil
-			}
-			tokens = strings.Join(t, " ")
+	tokens = e.Tokens
+	if len(e.Tokens) > 0 {
+		return tokens, "", nil
+	}
+
+	switch {
+	case len(lang.Tokenizers) > 0:
+		// A multi-tokenizer pipeline is configured. Run every tokenizer
+		// and merge their outputs, keeping each token's highest weight.
+		t, err := pipelineTokens(lang.Tokenizers, e.Content, e.Lang)
+		if err != nil {
+			return "", "", err
+		}
+		return strings.Join(t, " "), "", nil
+
+	case lang.Tokenizer == nil:
+		// No external tokenizer. Use the Postgres tokenizer name.
+		return "", lang.TokenizerName, nil
+
+	default:
+		// If there's an external tokenizer loaded, run it to get the tokens
+		// and pass it to the DB directly instructing the DB not to tokenize internally.
+		t, err := lang.Tokenizer.ToTokens(e.Content, e.Lang)
+		if err != nil {
+			return "", "", nil
 		}
+		return strings.Join(t, " "), "", nil
 	}
+}
 
+// insertEntryTokenized inserts e using already-computed tokens/tsVectorLang,
+// skipping tokenizeForInsert entirely.
+func (d *Data) insertEntryTokenized(e Entry, tokens, tsVectorLang string, stmt *sqlx.Stmt) (int, error) {
 	if e.Status == "" {
 		e.Status = StatusEnabled
 	}
 
 	var id int
-	err := stmt.Get(&id, e.Content, e.Initial, e.Weight, tokens, tsVectorLang, e.Lang, e.Tags, e.Phones, e.Notes, e.Status)
+	err := stmt.Get(&id, e.Content, e.Initial, e.Weight, tokens, tsVectorLang, e.Lang, e.Tags, e.Phones, e.Notes, e.Status, e.SpamScore)
 	return id, err
 }
 
+// HasDuplicateTokens reports whether an enabled or pending entry with the
+// exact same tsvector tokens already exists for lang. It's used by the spam
+// scorer to flag phonetic resubmissions.
+func (d *Data) HasDuplicateTokens(lang, tokens string) (bool, error) {
+	var exists bool
+	err := d.db.Get(&exists, `SELECT EXISTS(SELECT 1 FROM entries WHERE lang = $1 AND tokens = $2 AND status != $3)`,
+		lang, tokens, StatusDisabled)
+	return exists, err
+}
+
 func (d *Data) insertRelation(fromID, toID int, r Relation, stmt *sqlx.Stmt) (int, error) {
 	if r.Status == "" {
 		r.Status = StatusEnabled
@@ -424,8 +798,14 @@ func (d *Data) insertRelation(fromID, toID int, r Relation, stmt *sqlx.Stmt) (in
 	return id, err
 }
 
-// SearchAndLoadRelations loads related entries into the given Entries.
-func (d *Data) SearchAndLoadRelations(e []Entry, q Query) error {
+// SearchAndLoadRelations loads related entries into the given Entries. ctx
+// bounds the relations query independently of the parent search's deadline.
+//
+// When a cache is installed, the result is memoized per (the IDs in e, q's
+// to-lang/types/tags/status, q's current generation), since that's exactly
+// what determines the relations query below -- e itself is normally just
+// whatever Search(q) returned.
+func (d *Data) SearchAndLoadRelations(ctx context.Context, e []Entry, q Query) error {
 	var (
 		IDs = make([]int64, len(e))
 
@@ -440,8 +820,21 @@ func (d *Data) SearchAndLoadRelations(e []Entry, q Query) error {
 		idMap[e[i].ID] = i
 	}
 
+	var cacheKey string
+	if d.cache != nil {
+		cacheKey = d.relationsCacheKey(IDs, q)
+		if rels, ok := d.cacheGetRelations(cacheKey); ok {
+			for id, idx := range idMap {
+				if r, ok := rels[id]; ok {
+					e[idx].Relations = r
+				}
+			}
+			return nil
+		}
+	}
+
 	var relEntries []Entry
-	if err := d.queries.SearchRelations.Select(&relEntries,
+	if err := d.queries.SearchRelations.SelectContext(ctx, &relEntries,
 		q.ToLang,
 		pq.StringArray(q.Types),
 		pq.StringArray(q.Tags),
@@ -471,21 +864,80 @@ func (d *Data) SearchAndLoadRelations(e []Entry, q Query) error {
 		e[idx].Relations = append(e[idx].Relations, r)
 	}
 
+	if d.cache != nil {
+		rels := make(map[int][]Entry, len(idMap))
+		for id, idx := range idMap {
+			rels[id] = e[idx].Relations
+		}
+		d.cachePutRelations(cacheKey, rels, d.cacheTTL(q, len(relEntries)))
+	}
+
 	return nil
 }
 
-// TokensToTSVector takes a list of tokens, de-duplicates them, and returns a
-// Postgres tsvector string.
+// TokensToTSVector takes a list of tokens, de-duplicates them keeping the
+// highest Weight seen for each token, and returns a Postgres tsvector
+// string. De-duplication by highest weight, rather than first occurrence,
+// is what lets a multi-tokenizer pipeline (see Lang.Tokenizers) merge
+// tokens from several sources without a lower-weighted source ever
+// shadowing a higher-weighted one.
 func TokensToTSVector(tokens []Token) []string {
 	var (
-		keys = make(map[string]bool)
-		out  = []string{}
+		order   = []string{}
+		weights = make(map[string]int)
 	)
 	for _, t := range tokens {
-		if _, ok := keys[t.Token]; !ok {
-			keys[t.Token] = true
-			out = append(out, fmt.Sprintf("%s:%d", t.Token, t.Weight))
+		if w, ok := weights[t.Token]; !ok {
+			weights[t.Token] = t.Weight
+			order = append(order, t.Token)
+		} else if t.Weight > w {
+			weights[t.Token] = t.Weight
 		}
 	}
+
+	out := make([]string, 0, len(order))
+	for _, tok := range order {
+		out = append(out, fmt.Sprintf("%s:%d", tok, weights[tok]))
+	}
 	return out
 }
+
+// pipelineTokens runs s through every tokenizer in pipeline in order,
+// retags each tokenizer's output tokens with that tokenizer's configured
+// weight (discarding whatever weight the tokenizer itself encoded), and
+// merges the results with TokensToTSVector so that, when the same token
+// comes out of more than one tokenizer, the highest-weighted source wins.
+func pipelineTokens(pipeline []ResolvedTokenizer, s, lang string) ([]string, error) {
+	var tokens []Token
+	for _, rt := range pipeline {
+		toks, err := rt.Tokenizer.ToTokens(s, lang)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range toks {
+			tok := t
+			if i := strings.LastIndex(t, ":"); i != -1 {
+				tok = t[:i]
+			}
+			tokens = append(tokens, Token{Token: tok, Weight: rt.Weight})
+		}
+	}
+	return TokensToTSVector(tokens), nil
+}
+
+// pipelineQuery runs s through every tokenizer in pipeline and OR-combines
+// their individual tsquery strings, so a match via any one of the
+// language's configured tokenizers is enough to surface a result.
+func pipelineQuery(pipeline []ResolvedTokenizer, s, lang string) (string, error) {
+	var parts []string
+	for _, rt := range pipeline {
+		q, err := rt.Tokenizer.ToQuery(s, lang)
+		if err != nil {
+			return "", err
+		}
+		if q != "" {
+			parts = append(parts, "("+q+")")
+		}
+	}
+	return strings.Join(parts, " | "), nil
+}
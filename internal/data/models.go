@@ -26,11 +26,21 @@ type Entry struct {
 	Notes     string         `json:"notes" db:"notes"`
 	Meta      JSON           `json:"meta" db:"meta"`
 	Status    string         `json:"status" db:"status"`
+	SpamScore float64        `json:"spam_score,omitempty" db:"spam_score"`
 	Relations []Entry        `json:"relations,omitempty" db:"relations"`
 	Total     int            `json:"-" db:"total"`
 	CreatedAt null.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt null.Time      `json:"updated_at" db:"updated_at"`
 
+	// MatchType is set by Data.Search to MatchTypeExact or MatchTypeFuzzy so
+	// that a theme template can render a "did you mean ...?" hint for
+	// results that only matched via the pg_trgm fuzzy fallback.
+	MatchType string `json:"match_type,omitempty" db:"match_type"`
+
+	// Source identifies the origin of an entry merged in from a federated
+	// external search provider (eg: "wiktionary"). Empty for local results.
+	Source string `json:"source,omitempty" db:"-"`
+
 	// Non-public fields for scanning relationship data and populating Relation.
 	FromID            int            `json:"-" db:"from_id"`
 	RelationID        int            `json:"-" db:"relation_id"`
@@ -60,6 +70,14 @@ type Relation struct {
 	UpdatedAt null.Time      `json:"updated_at"`
 }
 
+// Suggestion is a single "did you mean ...?" term surfaced by Data.Suggest
+// when a search yields no matches, ranked by a blend of trigram similarity
+// and phonetic edit distance against the original query.
+type Suggestion struct {
+	Content string  `json:"content" db:"content"`
+	Score   float64 `json:"score" db:"score"`
+}
+
 // GlossaryWord to read glosary content from db.
 type GlossaryWord struct {
 	ID      int    `json:"id,omitempty" db:"id"`
@@ -72,6 +90,12 @@ type Stats struct {
 	Entries   int            `json:"entries"`
 	Relations int            `json:"relations"`
 	Languages map[string]int `json:"languages"`
+
+	// CacheHits and CacheMisses count Search/SearchAndLoadRelations calls
+	// served from and missed against Data's result cache since boot. Both
+	// are always 0 when no cache was installed via Data.SetCache.
+	CacheHits   int64 `json:"cache_hits"`
+	CacheMisses int64 `json:"cache_misses"`
 }
 
 type Comments struct {
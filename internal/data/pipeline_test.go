@@ -0,0 +1,105 @@
+package data
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// mockTokenizer is a Tokenizer stub that returns canned tokens/queries per
+// language, letting tests drive pipelineTokens/pipelineQuery without a real
+// Postgres or WASM tokenizer backing them.
+type mockTokenizer struct {
+	tokens map[string][]string
+	query  map[string]string
+}
+
+func (m mockTokenizer) ToTokens(s, lang string) ([]string, error) {
+	return m.tokens[lang], nil
+}
+
+func (m mockTokenizer) ToQuery(s, lang string) (string, error) {
+	return m.query[lang], nil
+}
+
+func TestTokensToTSVectorDedupeKeepsHighestWeight(t *testing.T) {
+	got := TokensToTSVector([]Token{
+		{Token: "cat", Weight: 3},
+		{Token: "dog", Weight: 2},
+		{Token: "cat", Weight: 1},
+	})
+
+	assert.DeepEqual(t, got, []string{"cat:3", "dog:2"})
+}
+
+func TestTokensToTSVectorEmpty(t *testing.T) {
+	got := TokensToTSVector(nil)
+	assert.Equal(t, len(got), 0)
+}
+
+func TestPipelineTokensMergesAcrossTokenizers(t *testing.T) {
+	pipeline := []ResolvedTokenizer{
+		{Tokenizer: mockTokenizer{tokens: map[string][]string{"en": {"cat:1", "dog:1"}}}, Weight: 1},
+		{Tokenizer: mockTokenizer{tokens: map[string][]string{"en": {"cat:1"}}}, Weight: 3},
+	}
+
+	got, err := pipelineTokens(pipeline, "a cat and a dog", "en")
+	assert.NilError(t, err)
+
+	// "cat" comes out of both tokenizers; the second, higher-weighted one
+	// (weight 3) must win over the first (weight 1). "dog" only comes from
+	// the first tokenizer and keeps its weight.
+	assert.DeepEqual(t, got, []string{"cat:3", "dog:1"})
+}
+
+func TestPipelineTokensEmptyPipeline(t *testing.T) {
+	got, err := pipelineTokens(nil, "a cat", "en")
+	assert.NilError(t, err)
+	assert.Equal(t, len(got), 0)
+}
+
+func TestPipelineTokensOneTokenizerEmptyForOneLang(t *testing.T) {
+	pipeline := []ResolvedTokenizer{
+		{Tokenizer: mockTokenizer{tokens: map[string][]string{"en": {"cat:1"}, "ta": nil}}, Weight: 1},
+		{Tokenizer: mockTokenizer{tokens: map[string][]string{"en": {"cat:1"}, "ta": {"பூனை:1"}}}, Weight: 2},
+	}
+
+	// The first tokenizer returns no tokens at all for "ta" even though it
+	// does for "en" -- the pipeline should still merge in whatever the
+	// second tokenizer produced for "ta" instead of erroring or dropping it.
+	got, err := pipelineTokens(pipeline, "பூனை", "ta")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, got, []string{"பூனை:2"})
+
+	got, err = pipelineTokens(pipeline, "cat", "en")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, got, []string{"cat:2"})
+}
+
+func TestPipelineQueryORCombinesTokenizers(t *testing.T) {
+	pipeline := []ResolvedTokenizer{
+		{Tokenizer: mockTokenizer{query: map[string]string{"en": "cat & dog"}}, Weight: 1},
+		{Tokenizer: mockTokenizer{query: map[string]string{"en": "cats"}}, Weight: 2},
+	}
+
+	got, err := pipelineQuery(pipeline, "cats and dogs", "en")
+	assert.NilError(t, err)
+	assert.Equal(t, got, "(cat & dog) | (cats)")
+}
+
+func TestPipelineQuerySkipsEmptyResults(t *testing.T) {
+	pipeline := []ResolvedTokenizer{
+		{Tokenizer: mockTokenizer{query: map[string]string{"en": ""}}, Weight: 1},
+		{Tokenizer: mockTokenizer{query: map[string]string{"en": "cats"}}, Weight: 2},
+	}
+
+	got, err := pipelineQuery(pipeline, "cats", "en")
+	assert.NilError(t, err)
+	assert.Equal(t, got, "(cats)")
+}
+
+func TestPipelineQueryEmptyPipeline(t *testing.T) {
+	got, err := pipelineQuery(nil, "cats", "en")
+	assert.NilError(t, err)
+	assert.Equal(t, got, "")
+}
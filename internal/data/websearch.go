@@ -0,0 +1,63 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// SearchWebsearch runs q through Postgres' websearch_to_tsquery() instead of
+// plainto_tsquery()/to_tsquery(), giving callers "quoted phrases", -exclusion
+// and OR support (the same syntax websearch_to_tsquery exposes) instead of
+// the plain AND-of-terms query Search() builds. It reuses Search's existing
+// external-tokenizer bypass (tsVectorLang="", tsVectorQuery=<precomputed>) to
+// hand the DB an already-built tsquery without touching the prepared
+// statement or its column projection.
+func (d *Data) SearchWebsearch(ctx context.Context, q Query) ([]Entry, int, error) {
+	lang, ok := d.Langs[q.FromLang]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown language %s", q.FromLang)
+	}
+
+	tsLang := lang.TokenizerName
+	if tsLang == "" {
+		tsLang = "simple"
+	}
+
+	var tsQuery string
+	if err := d.db.QueryRowContext(ctx, `SELECT websearch_to_tsquery($1, $2)::text`,
+		tsLang, q.Query).Scan(&tsQuery); err != nil {
+		return nil, 0, err
+	}
+
+	var out []Entry
+	if err := d.queries.Search.SelectContext(ctx, &out,
+		q.Query,
+		"",
+		tsQuery,
+		q.FromLang,
+		pq.StringArray(q.Tags),
+		q.Status,
+		q.Offset, q.Limit,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return []Entry{}, 0, nil
+		}
+		return nil, 0, err
+	}
+
+	if len(out) == 0 {
+		return []Entry{}, 0, nil
+	}
+
+	for i := range out {
+		if out[i].Relations == nil {
+			out[i].Relations = []Entry{}
+		}
+		out[i].MatchType = MatchTypeExact
+	}
+
+	return out, out[0].Total, nil
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// importCheckpoint persists, as a plain integer in a sidecar file next to
+// the source file, how many main entries (each with its nested defs) have
+// been successfully committed, so ImportOptions.Resume can skip forward
+// instead of reimporting a file from scratch after a killed or failed run.
+type importCheckpoint struct {
+	path string
+}
+
+func newImportCheckpoint(filePath string) *importCheckpoint {
+	return &importCheckpoint{path: filePath + ".checkpoint"}
+}
+
+// load returns the last checkpointed entry count, or 0 if no checkpoint
+// file exists yet.
+func (c *importCheckpoint) load() (int, error) {
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid checkpoint file %s: %v", c.path, err)
+	}
+
+	return n, nil
+}
+
+// save overwrites the checkpoint file with n, the count of main entries
+// successfully committed so far.
+func (c *importCheckpoint) save(n int) error {
+	return os.WriteFile(c.path, []byte(strconv.Itoa(n)), 0644)
+}
+
+// remove deletes the checkpoint file once a file has imported cleanly
+// end-to-end, so re-running the same file starts over instead of silently
+// skipping rows via a stale resume point.
+func (c *importCheckpoint) remove() error {
+	err := os.Remove(c.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
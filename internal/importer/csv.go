@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const colCount = 11
+
+const (
+	typeEntry = "-"
+	typeDef   = "^"
+)
+
+// formatCSV reads the dictpress-native CSV format:
+//
+// entry_type, word, initial, language, notes, tsvector_language, [tsvector_tokens], [tags], [phones], definition_type, meta
+//
+// entry_type = - represents a main entry and subsequent ^ represents definitions.
+// definition_type (last field) should only be set in definition (^) entries.
+// It represents the part of speech types defined in the config. Eg: noun, verb etc.
+//
+// tsvector_language = Name of the Postgres language tokenizer if it's a built in one.
+// If this is set, content is automatically tokenized using this language in Postgres and [tsvector_tokens] can be left empty.
+// If the language does not have a Postgres tokenizer, leave tsvector_language empty and manually set [tsvector_tokens]
+type formatCSV struct {
+	im  *Importer
+	rd  *csv.Reader
+	n   int
+	buf []string // one row of lookahead, read but not yet consumed
+}
+
+func detectCSV(path string) bool {
+	return extIs(path, ".csv")
+}
+
+func openCSV(fp *os.File, im *Importer) (Format, error) {
+	rd := csv.NewReader(fp)
+	rd.FieldsPerRecord = -1
+	return &formatCSV{im: im, rd: rd}, nil
+}
+
+// Next reads and returns the next main entry from the CSV, along with any
+// of its definitions (rows of type '^' immediately following it).
+func (f *formatCSV) Next() (entry, error) {
+	row, err := f.readRow()
+	if err != nil {
+		return entry{}, err
+	}
+
+	if f.n == 1 && row[0] != typeEntry {
+		return entry{}, fmt.Errorf("line %d: first row in the file should be of type '-'", f.n)
+	}
+
+	e, err := f.im.readCSVEntry(row)
+	if err != nil {
+		return entry{}, fmt.Errorf("error reading line %d: %v", f.n, err)
+	}
+
+	// Collect subsequent definition (^) rows into e.defs until the next
+	// main entry or EOF.
+	for {
+		row, err := f.readRow()
+		if err != nil {
+			if err == io.EOF {
+				return e, nil
+			}
+			return entry{}, err
+		}
+
+		d, err := f.im.readCSVEntry(row)
+		if err != nil {
+			return entry{}, fmt.Errorf("error reading line %d: %v", f.n, err)
+		}
+
+		if d.Type != typeDef {
+			// Not a definition of the current entry. Buffer it so the next
+			// Next() call picks it up as a new main entry.
+			f.buf = row
+			return e, nil
+		}
+		e.defs = append(e.defs, d)
+	}
+}
+
+// readRow returns the next CSV row, preferring one buffered by a previous
+// lookahead over reading a fresh one.
+func (f *formatCSV) readRow() ([]string, error) {
+	if f.buf != nil {
+		row := f.buf
+		f.buf = nil
+		f.n++
+		return row, nil
+	}
+
+	row, err := f.rd.Read()
+	if err != nil {
+		return nil, err
+	}
+	f.n++
+	return row, nil
+}
+
+// readCSVEntry parses a single CSV row into an entry.
+func (im *Importer) readCSVEntry(r []string) (entry, error) {
+	typ := cleanString(r[0])
+	if typ != typeEntry && typ != typeDef {
+		return entry{}, fmt.Errorf("unknown type '%s' in column 0. Should be '-' (entry), or '^' for definition", typ)
+	}
+
+	e := entry{
+		Type:           typ,
+		Initial:        cleanString(r[1]),
+		Content:        cleanString(r[2]),
+		Lang:           cleanString(r[3]),
+		Notes:          cleanString(r[4]),
+		TSVectorLang:   cleanString(r[5]),
+		TSVectorTokens: cleanString(r[6]),
+		Tags:           splitString(cleanString(r[7])),
+		Phones:         splitString(cleanString(r[8])),
+	}
+
+	if len(r) != colCount {
+		return e, fmt.Errorf("every line should have exactly %d columns. Found %d", colCount, len(r))
+	}
+
+	lang, ok := im.langs[e.Lang]
+	if !ok {
+		return e, fmt.Errorf("unknown language '%s' at column 2", e.Lang)
+	}
+
+	if e.Content == "" {
+		return e, fmt.Errorf("empty content (word) at column 1")
+	}
+
+	if e.Initial == "" {
+		e.Initial = strings.ToUpper(string(e.Content[0]))
+	}
+
+	defTypeStr := cleanString(r[9])
+	if typ == typeDef {
+		defTypes := splitString(defTypeStr)
+		for _, t := range defTypes {
+			if _, ok := lang.Types[t]; !ok {
+				return e, fmt.Errorf("unknown type '%s' for language '%s'", t, e.Lang)
+			}
+		}
+		e.DefTypes = defTypes
+	} else if defTypeStr != "" {
+		return e, fmt.Errorf("column 10, definition type (part of speech) should only be set of definition entries (^)")
+	}
+
+	e.Meta = strings.TrimSpace(e.Meta)
+	if e.Meta == "" {
+		e.Meta = "{}"
+	} else if e.Meta[0:1] != "{" {
+		return e, fmt.Errorf("column 11, meta JSON should begin with `{`")
+	}
+
+	return e, nil
+}
+
+func cleanString(s string) string {
+	return reSpaces.ReplaceAllString(strings.TrimSpace(s), " ")
+}
+
+func splitString(s string) []string {
+	out := strings.Split(s, "|")
+	for n, v := range out {
+		out[n] = strings.TrimSpace(v)
+	}
+
+	return out
+}
@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ExportOptions filters which entries Export streams out. An empty field
+// means "don't filter on this".
+type ExportOptions struct {
+	Lang   string
+	Tag    string
+	Status string
+}
+
+// Exporter streams dictionary entries, and the relations each is the `from`
+// side of, out of the database -- the counterpart to Importer. Rows are
+// read off a sqlx cursor one at a time so exporting a dictionary with
+// millions of entries never buffers the full result set in memory.
+type Exporter struct {
+	db *sqlx.DB
+}
+
+// NewExporter returns a new instance of the exporter.
+func NewExporter(db *sqlx.DB) *Exporter {
+	return &Exporter{db: db}
+}
+
+// exportRow is a single entries row plus its outgoing relations (aggregated
+// DB-side into a JSON array so the query stays a single cursor), scanned
+// off Export's query.
+type exportRow struct {
+	GUID    string         `db:"guid"`
+	Content string         `db:"content"`
+	Initial string         `db:"initial"`
+	Lang    string         `db:"lang"`
+	Tags    pq.StringArray `db:"tags"`
+	Phones  pq.StringArray `db:"phones"`
+	Notes   string         `db:"notes"`
+	Status  string         `db:"status"`
+	RelJSON string         `db:"relations"`
+}
+
+// exportRelation is one element of exportRow.RelJSON, shaped to match
+// jsonlRelation so Export's JSONL output is re-readable by Import(...,
+// "jsonl") unchanged.
+type exportRelation struct {
+	ToGUID string   `json:"to_guid"`
+	Types  []string `json:"types"`
+	Tags   []string `json:"tags"`
+	Notes  string   `json:"notes"`
+}
+
+// Export streams every entry matching opts to w in format ("jsonl", the
+// default, or "csv"), along with the relations it's the `from` side of.
+func (ex *Exporter) Export(w io.Writer, format string, opts ExportOptions) error {
+	rows, err := ex.db.Queryx(`
+		SELECT e.guid, e.content, e.initial, e.lang, e.tags, e.phones, e.notes, e.status,
+			COALESCE((
+				SELECT json_agg(json_build_object(
+					'to_guid', e2.guid, 'types', r.types, 'tags', r.tags, 'notes', r.notes
+				) ORDER BY r.weight)
+				FROM relations r JOIN entries e2 ON e2.id = r.to_id
+				WHERE r.from_id = e.id
+			), '[]') AS relations
+		FROM entries e
+		WHERE ($1 = '' OR e.lang = $1)
+			AND ($2 = '' OR e.tags @> ARRAY[$2::VARCHAR])
+			AND ($3 = '' OR e.status = $3)
+		ORDER BY e.id`, opts.Lang, opts.Tag, opts.Status)
+	if err != nil {
+		return fmt.Errorf("error querying entries: %v", err)
+	}
+	defer rows.Close()
+
+	if strings.ToLower(format) == "csv" {
+		return ex.writeCSV(w, rows)
+	}
+	return ex.writeJSONL(w, rows)
+}
+
+// writeJSONL writes rows as newline-delimited JSON, one jsonlEntry per line,
+// in the same shape formatJSONL reads.
+func (ex *Exporter) writeJSONL(w io.Writer, rows *sqlx.Rows) error {
+	enc := json.NewEncoder(w)
+	for rows.Next() {
+		var r exportRow
+		if err := rows.StructScan(&r); err != nil {
+			return err
+		}
+
+		rels, err := parseExportRelations(r)
+		if err != nil {
+			return err
+		}
+
+		je := jsonlEntry{
+			GUID: r.GUID, Content: r.Content, Initial: r.Initial, Lang: r.Lang,
+			Tags: r.Tags, Phones: r.Phones, Notes: r.Notes,
+		}
+		for _, rel := range rels {
+			je.Relations = append(je.Relations, jsonlRelation{
+				ToGUID: rel.ToGUID, Types: rel.Types, Tags: rel.Tags, Notes: rel.Notes,
+			})
+		}
+
+		if err := enc.Encode(je); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// writeCSV writes the dictpress-native export CSV layout: one row per
+// entry, guid, content, initial, lang, tags, phones, notes, status,
+// relations -- tags and phones pipe (|) separated as in the import CSV
+// format, and relations a `;`-separated list of to_guid:type1,type2 pairs.
+// A relation's own tags/notes aren't representable in this column; export
+// as JSONL instead to round-trip those.
+func (ex *Exporter) writeCSV(w io.Writer, rows *sqlx.Rows) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{
+		"guid", "content", "initial", "lang", "tags", "phones", "notes", "status", "relations",
+	}); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var r exportRow
+		if err := rows.StructScan(&r); err != nil {
+			return err
+		}
+
+		rels, err := parseExportRelations(r)
+		if err != nil {
+			return err
+		}
+
+		relCol := make([]string, 0, len(rels))
+		for _, rel := range rels {
+			relCol = append(relCol, rel.ToGUID+":"+strings.Join(rel.Types, ","))
+		}
+
+		if err := cw.Write([]string{
+			r.GUID, r.Content, r.Initial, r.Lang,
+			strings.Join(r.Tags, "|"), strings.Join(r.Phones, "|"), r.Notes, r.Status,
+			strings.Join(relCol, ";"),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return rows.Err()
+}
+
+func parseExportRelations(r exportRow) ([]exportRelation, error) {
+	var rels []exportRelation
+	if err := json.Unmarshal([]byte(r.RelJSON), &rels); err != nil {
+		return nil, fmt.Errorf("error parsing relations for guid %q: %v", r.GUID, err)
+	}
+	return rels, nil
+}
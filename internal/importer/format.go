@@ -0,0 +1,106 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format reads one bulk-import source (CSV, Stardict, TEI, JSON-Lines ...)
+// and streams it as a series of top-level entries, each carrying its
+// definitions/translations nested in .defs, in the same shape the rest of
+// the importer already works with. Next returns io.EOF once the source is
+// exhausted.
+type Format interface {
+	Next() (entry, error)
+}
+
+// formatOpener constructs a Format reader for an already-open file.
+type formatOpener func(fp *os.File, im *Importer) (Format, error)
+
+// formats is the registry of every bulk-import format this build knows how
+// to read, keyed by the name used with --format.
+var formats = map[string]struct {
+	// detect reports whether a file with no explicit --format override
+	// looks like this format, based on its extension.
+	detect func(path string) bool
+	open   formatOpener
+}{
+	"csv":      {detectCSV, openCSV},
+	"jsonl":    {detectJSONL, openJSONL},
+	"tei":      {detectTEI, openTEI},
+	"stardict": {detectStardict, openStardict},
+	"wordnet":  {detectWordnet, openWordnet},
+}
+
+// detectFormat returns the name of the bulk-import format for the file at
+// path. override, when non-empty (eg: from --format), always wins.
+func detectFormat(path, override string) (string, error) {
+	if override != "" {
+		if _, ok := formats[override]; !ok {
+			return "", fmt.Errorf("unknown format '%s'", override)
+		}
+		return override, nil
+	}
+
+	for name, f := range formats {
+		if f.detect(path) {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("could not detect the format of '%s', specify one explicitly with --format", path)
+}
+
+// newFormat opens filePath and returns the Format reader for it.
+func newFormat(name, filePath string, im *Importer) (Format, func() error, error) {
+	f, ok := formats[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown format '%s'", name)
+	}
+
+	fp, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening file %s: %v", filePath, err)
+	}
+
+	rd, err := f.open(fp, im)
+	if err != nil {
+		fp.Close()
+		return nil, nil, err
+	}
+
+	return rd, fp.Close, nil
+}
+
+func extIs(path string, exts ...string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// readAllLines reads fp fully as newline-delimited records, ignoring blank
+// lines. It's used by the line-oriented formats (JSON-Lines).
+func readAllLines(fp *os.File) ([]string, error) {
+	b, err := io.ReadAll(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, l := range strings.Split(string(b), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		out = append(out, l)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,111 @@
+package importer
+
+import (
+	"io"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// readAll drains a Format into a flat slice of entries, counting relations
+// (defs) along the way.
+func readAllEntries(t *testing.T, f Format) ([]entry, int) {
+	var (
+		out   []entry
+		nDefs int
+	)
+	for {
+		e, err := f.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+		out = append(out, e)
+		nDefs += len(e.defs)
+	}
+	return out, nDefs
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"mock/case_1.csv", "csv"},
+		{"mock/case_1.jsonl", "jsonl"},
+		{"mock/case_1.xml", "tei"},
+		{"mock/case_1.ifo", "stardict"},
+		{"mock/case_1.wn-lmf", "wordnet"},
+	}
+	for _, tt := range tests {
+		got, err := detectFormat(tt.path, "")
+		assert.NilError(t, err)
+		assert.Equal(t, got, tt.want)
+	}
+
+	if _, err := detectFormat("mock/case_1.jsonl", "bogus"); err == nil {
+		t.Fatal("expected error for unknown --format override")
+	}
+}
+
+func TestFormatJSONL(t *testing.T) {
+	populateLangs()
+	im := &Importer{langs: langs}
+
+	rd, closeFn, err := newFormat("jsonl", "mock/case_1.jsonl", im)
+	assert.NilError(t, err)
+	defer closeFn()
+
+	entries, nDefs := readAllEntries(t, rd)
+	assert.Equal(t, len(entries), 2)
+	assert.Equal(t, nDefs, 2)
+	assert.Equal(t, entries[0].Content, "casa")
+	assert.Equal(t, entries[0].defs[0].Content, "house")
+}
+
+func TestFormatTEI(t *testing.T) {
+	populateLangs()
+	im := &Importer{langs: langs}
+
+	rd, closeFn, err := newFormat("tei", "mock/case_1.xml", im)
+	assert.NilError(t, err)
+	defer closeFn()
+
+	entries, nDefs := readAllEntries(t, rd)
+	assert.Equal(t, len(entries), 2)
+	assert.Equal(t, nDefs, 2)
+	assert.Equal(t, entries[1].Content, "gatto")
+	assert.Equal(t, entries[1].defs[0].Content, "cat")
+	assert.Equal(t, entries[1].defs[0].Lang, "english")
+}
+
+func TestFormatWordnet(t *testing.T) {
+	populateLangs()
+	im := &Importer{langs: langs}
+
+	rd, closeFn, err := newFormat("wordnet", "mock/case_1.wn-lmf", im)
+	assert.NilError(t, err)
+	defer closeFn()
+
+	entries, nDefs := readAllEntries(t, rd)
+	assert.Equal(t, len(entries), 2)
+	assert.Equal(t, nDefs, 2)
+	assert.Equal(t, entries[0].Content, "house")
+	assert.Equal(t, entries[0].defs[0].Content, "a building for human habitation")
+	assert.Equal(t, entries[0].defs[0].Lang, "english")
+}
+
+func TestFormatStardict(t *testing.T) {
+	populateLangs()
+	im := &Importer{langs: langs}
+
+	rd, closeFn, err := newFormat("stardict", "mock/case_1.ifo", im)
+	assert.NilError(t, err)
+	defer closeFn()
+
+	entries, nDefs := readAllEntries(t, rd)
+	assert.Equal(t, len(entries), 2)
+	assert.Equal(t, nDefs, 2)
+	assert.Equal(t, entries[0].Content, "casa")
+	assert.Equal(t, entries[0].defs[0].Content, "house")
+}
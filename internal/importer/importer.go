@@ -1,131 +1,142 @@
-// package importer imports a dictionary CSV into the database.
+// package importer bulk imports dictionary data into the database from a
+// variety of source formats (CSV, JSON-Lines, TEI Lex-0 XML, Stardict,
+// WN-LMF WordNet XML), and, via Exporter, streams it back out as JSONL or
+// CSV.
 package importer
 
 import (
-	"encoding/csv"
+	"database/sql"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/dictpress/internal/data"
 	"github.com/lib/pq"
 )
 
-const (
-	insertBatchSize = 5000
-	colCount        = 11
+const insertBatchSize = 5000
 
-	typeEntry = "-"
-	typeDef   = "^"
-)
-
-// entry represents a single row read from the CSV. The CSV columns are:
-// Array columns like tokens, tags etc. are pipe (|) separated.
-// entry_type, word, initial, language, notes, tsvector_language, [tsvector_tokens], [tags], [phones], definition_type, meta
-//
-// entry_type = - represents a main entry and subsequent ^ represents definitions.
-// definition_type (last field) should only be set in definition (^) entries.
-// It represents the part of speech types defined in the config. Eg: noun, verb etc.
-//
-// tsvector_language = Name of the Postgres language tokenizer if it's a built in one.
-// If this is set, content is automatically tokenized using this language in Postgres and [tsvector_tokens] can be left empty.
-// If the language does not have a Postgres tokenizer, leave tsvector_language empty and manually set [tsvector_tokens]
+// entry represents a single entry read from a source file, regardless of
+// its format. Array fields like tags, phones etc. are populated by each
+// Format implementation from whatever representation the source format
+// uses for them.
 type entry struct {
-	// Comments show CSV column positions.
-	Type           string   // 0
-	Initial        string   // 1
-	Content        string   // 2
-	Lang           string   // 3
-	Notes          string   // 4
-	TSVectorLang   string   // 4
-	TSVectorTokens string   // 6
-	Tags           []string // 7
-	Phones         []string // 8
-	DefTypes       []string // 9 - Only read in definition entries (0=^)
-	Meta           string   // 10
+	Type           string
+	GUID           string
+	Initial        string
+	Content        string
+	Lang           string
+	Notes          string
+	TSVectorLang   string
+	TSVectorTokens string
+	Tags           []string
+	Phones         []string
+	DefTypes       []string // Only read in definition/relation entries.
+	Meta           string
+	Relations      []relRef // GUID-addressed relations. Only read by formatJSONL.
 
 	defs []entry
 }
 
-// Importer imports CSV entries into the database.
+// relRef is a single `relations` entry read off a JSONL row's `to_guid`
+// reference. It's resolved against entries.guid by flushRelations() once
+// every entry in the import (not just its own batch) has been committed, so
+// a relation can point at a GUID defined anywhere else in the same file,
+// including later in the stream.
+type relRef struct {
+	ToGUID string
+	Types  []string
+	Tags   []string
+	Notes  string
+}
+
+// pendingRelation is a relRef that still needs its ToGUID resolved, tagged
+// with the line number and already-committed entry ID it came from so a
+// resolution failure can be reported back against the source row.
+type pendingRelation struct {
+	Line   int
+	FromID int
+	Ref    relRef
+}
+
+// Importer imports entries from a bulk source file into the database.
 type Importer struct {
 	langs data.LangMap
 
 	db              *sqlx.DB
+	dt              *data.Data
 	stmtInsertEntry *sqlx.Stmt
 	stmtInsertRel   *sqlx.Stmt
 	lo              *log.Logger
+
+	// upsert makes a JSONL row carrying a `guid` that matches an existing
+	// entry update that entry in place instead of inserting a duplicate,
+	// so the same file can be re-run against a dictionary that already has
+	// it (eg: a round trip through an Exporter's output) without erroring
+	// or duplicating content.
+	upsert bool
+
+	pendingRelsMu sync.Mutex
+	pendingRels   []pendingRelation
 }
 
 var (
 	reSpaces, _ = regexp.Compile("\\s+")
 )
 
-// New returns a new instance of the CSV importer.
-func New(langs data.LangMap, stmtInsertEntry *sqlx.Stmt, stmtInsertRel *sqlx.Stmt, db *sqlx.DB, lo *log.Logger) *Importer {
+// New returns a new instance of the importer. dt is used to upsert existing
+// entries by GUID when upsert is true; it may be nil if upsert is false.
+func New(langs data.LangMap, stmtInsertEntry *sqlx.Stmt, stmtInsertRel *sqlx.Stmt, db *sqlx.DB, dt *data.Data, upsert bool, lo *log.Logger) *Importer {
 	return &Importer{
 		langs:           langs,
 		stmtInsertEntry: stmtInsertEntry,
 		stmtInsertRel:   stmtInsertRel,
 		db:              db,
+		dt:              dt,
+		upsert:          upsert,
 		lo:              lo,
 	}
 }
 
-// Import imports a CSV file into the DB.
-func (im *Importer) Import(filePath string) error {
-	fp, err := os.Open(filePath)
+// Import reads filePath and imports it into the DB. format selects the
+// source format explicitly (eg: from the --format CLI flag); if empty, the
+// format is auto-detected from the file's extension.
+func (im *Importer) Import(filePath, format string) error {
+	name, err := detectFormat(filePath, format)
+	if err != nil {
+		return err
+	}
+
+	rd, closeFn, err := newFormat(name, filePath, im)
 	if err != nil {
-		return fmt.Errorf("error opening file %s: %v", filePath, err)
+		return err
 	}
+	defer closeFn()
 
 	var (
 		// Holds all main entries.
 		entries []entry
-		n       = 0
 		numMain = 0
 		numDefs = 0
 	)
 
-	rd := csv.NewReader(fp)
-	rd.FieldsPerRecord = -1
 	for {
-		row, err := rd.Read()
+		e, err := rd.Next()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 
-			return fmt.Errorf("error reading CSV file %s: %v", filePath, err)
+			return fmt.Errorf("error reading %s file %s: %v", name, filePath, err)
 		}
 
-		if n == 0 && row[0] != "-" {
-			return fmt.Errorf("line %d: first row in the file should be of type '-'", n)
-		}
-		n++
-
-		e, err := im.readEntry(row)
-		if err != nil {
-			return fmt.Errorf("error reading line %d: %v", n, err)
-		}
-
-		// First entry is always a main entry.
-		if len(entries) == 0 {
-			entries = append(entries, e)
-			continue
-		}
-
-		// Add all definitions to the last main entry in the list.
-		if e.Type == typeDef {
-			i := len(entries) - 1
-			entries[i].defs = append(entries[i].defs, e)
-			numDefs++
-			continue
-		}
+		numDefs += len(e.defs)
+		entries = append(entries, e)
 
 		// On hitting the batchsize, insert to DB.
 		if len(entries)%insertBatchSize == 0 {
@@ -138,89 +149,19 @@ func (im *Importer) Import(filePath string) error {
 
 			im.lo.Printf("imported %d entries and %d definitions", numMain, numDefs)
 		}
-
-		// New main entry.
-		entries = append(entries, e)
 	}
 
 	if len(entries) > 0 {
 		if err := im.insertEntries(entries, numMain); err != nil {
 			return fmt.Errorf("error inserting entries to DB: %v", err)
 		}
+		numMain += len(entries)
 	}
 
-	im.lo.Printf("finished. imported %d entries and %d definitions", numMain+len(entries), numDefs)
-	return nil
-}
-
-// initial, content, lang, notes, tsvector_language, [tokens|], [tags|], [pronunciations|]
-func (im *Importer) readEntry(r []string) (entry, error) {
-	typ := cleanString(r[0])
-	if typ != typeEntry && typ != typeDef {
-		return entry{}, fmt.Errorf("unknown type '%s' in column 0. Should be '-' (entry), or '^' for definition", typ)
-	}
-
-	e := entry{
-		Type:           typ,
-		Initial:        cleanString(r[1]),
-		Content:        cleanString(r[2]),
-		Lang:           cleanString(r[3]),
-		Notes:          cleanString(r[4]),
-		TSVectorLang:   cleanString(r[5]),
-		TSVectorTokens: cleanString(r[6]),
-		Tags:           splitString(cleanString(r[7])),
-		Phones:         splitString(cleanString(r[8])),
-	}
-
-	if len(r) != colCount {
-		return e, fmt.Errorf("every line should have exactly %d columns. Found %d", colCount, len(r))
-	}
-
-	lang, ok := im.langs[e.Lang]
-	if !ok {
-		return e, fmt.Errorf("unknown language '%s' at column 2", e.Lang)
-	}
-
-	if e.Content == "" {
-		return e, fmt.Errorf("empty content (word) at column 1")
-	}
-
-	if e.Initial == "" {
-		e.Initial = strings.ToUpper(string(e.Content[0]))
-	}
-
-	// If the Postgres tokenizer is not set, and there are no tokens supplied,
-	// see if the language has a custom one and use it.
-	if lang.Tokenizer != nil && e.TSVectorLang == "" && e.TSVectorTokens == "" {
-		tks, err := lang.Tokenizer.ToTokens(e.Content, lang.ID)
-		if err != nil {
-			return e, fmt.Errorf("error tokenizing content (word) at column 1: %v", err)
-		}
-
-		e.TSVectorTokens = strings.Join(tks, " ")
-	}
-
-	defTypeStr := cleanString(r[9])
-	if typ == typeDef {
-		defTypes := splitString(defTypeStr)
-		for _, t := range defTypes {
-			if _, ok := lang.Types[t]; !ok {
-				return e, fmt.Errorf("unknown type '%s' for language '%s'", t, e.Lang)
-			}
-		}
-		e.DefTypes = defTypes
-	} else if defTypeStr != "" {
-		return e, fmt.Errorf("column 10, definition type (part of speech) should only be set of definition entries (^)")
-	}
-
-	e.Meta = strings.TrimSpace(e.Meta)
-	if e.Meta == "" {
-		e.Meta = "{}"
-	} else if e.Meta[0:1] != "{" {
-		return e, fmt.Errorf("column 11, meta JSON should begin with `{`")
-	}
+	im.lo.Printf("finished. imported %d entries and %d definitions", numMain, numDefs)
 
-	return e, nil
+	im.flushRelations()
+	return nil
 }
 
 func (im *Importer) insertEntries(entries []entry, lineStart int) error {
@@ -237,7 +178,18 @@ func (im *Importer) insertEntries(entries []entry, lineStart int) error {
 	}
 	stmt = tx.Stmtx(im.stmtInsertEntry)
 	for i, e := range entries {
-		if err := stmt.Get(&entryIDs[i],
+		e, err := im.tokenizeEntry(e)
+		if err != nil {
+			return err
+		}
+
+		id, existed, err := im.upsertByGUID(tx, e)
+		if err != nil {
+			return err
+		}
+		if existed {
+			entryIDs[i] = id
+		} else if err := stmt.Get(&entryIDs[i],
 			pq.StringArray([]string{e.Content}),
 			e.Initial,
 			lineStart,
@@ -252,6 +204,10 @@ func (im *Importer) insertEntries(entries []entry, lineStart int) error {
 			log.Printf("error inserting entry: %v", err)
 			return err
 		}
+
+		if len(e.Relations) > 0 {
+			im.queueRelations(entryIDs[i], lineStart+1, e.Relations)
+		}
 		lineStart++
 	}
 
@@ -272,6 +228,11 @@ func (im *Importer) insertEntries(entries []entry, lineStart int) error {
 		relIDs[i] = make([]int, len(mainEntry.defs))
 
 		for j, e := range mainEntry.defs {
+			e, err := im.tokenizeEntry(e)
+			if err != nil {
+				return err
+			}
+
 			// Insert the definition entry and record the resulting ID
 			// against the parent ID.
 			if err := stmt.Get(&relIDs[i][j],
@@ -315,15 +276,111 @@ func (im *Importer) insertEntries(entries []entry, lineStart int) error {
 	return nil
 }
 
-func cleanString(s string) string {
-	return reSpaces.ReplaceAllString(strings.TrimSpace(s), " ")
+// upsertByGUID looks up e by its GUID (if it has one and im.upsert is set)
+// and, if a matching entry already exists, updates it in place via
+// data.Data.UpdateEntry and returns its ID with existed=true so the caller
+// skips the normal insert. A row without a GUID, or one whose GUID isn't
+// found, is left for the caller to insert as usual.
+func (im *Importer) upsertByGUID(tx *sqlx.Tx, e entry) (id int, existed bool, err error) {
+	if !im.upsert || e.GUID == "" {
+		return 0, false, nil
+	}
+
+	if err := tx.Get(&id, `SELECT id FROM entries WHERE guid = $1`, e.GUID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("error looking up guid %q: %v", e.GUID, err)
+	}
+
+	if err := im.dt.UpdateEntry(id, data.Entry{
+		Content: e.Content,
+		Initial: e.Initial,
+		Lang:    e.Lang,
+		Tags:    e.Tags,
+		Phones:  e.Phones,
+		Notes:   e.Notes,
+		Status:  data.StatusEnabled,
+	}); err != nil {
+		return 0, false, fmt.Errorf("error upserting guid %q: %v", e.GUID, err)
+	}
+
+	return id, true, nil
+}
+
+// queueRelations buffers refs, read off fromLine's `relations` array,
+// against the already-committed fromID for resolution once the whole
+// import has finished -- a relation may point at a GUID that appears later
+// in the same file.
+func (im *Importer) queueRelations(fromID, fromLine int, refs []relRef) {
+	im.pendingRelsMu.Lock()
+	defer im.pendingRelsMu.Unlock()
+
+	for _, r := range refs {
+		im.pendingRels = append(im.pendingRels, pendingRelation{Line: fromLine, FromID: fromID, Ref: r})
+	}
 }
 
-func splitString(s string) []string {
-	out := strings.Split(s, "|")
-	for n, v := range out {
-		out[n] = strings.TrimSpace(v)
+// flushRelations resolves every GUID-addressed relation queued by
+// queueRelations against entries.guid and inserts it via stmtInsertRel. A
+// relation whose to_guid doesn't resolve is reported to stderr with the
+// line number of the row that referenced it and skipped, rather than
+// failing the whole import -- the rest of a large file is still worth
+// having.
+func (im *Importer) flushRelations() {
+	if len(im.pendingRels) == 0 {
+		return
 	}
 
-	return out
+	im.lo.Printf("resolving %d guid-addressed relation(s)", len(im.pendingRels))
+
+	tx, err := im.db.Beginx()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error starting relations transaction: %v\n", err)
+		return
+	}
+	defer tx.Rollback()
+
+	stmt := tx.Stmtx(im.stmtInsertRel)
+	for _, p := range im.pendingRels {
+		var toID int
+		if err := tx.Get(&toID, `SELECT id FROM entries WHERE guid = $1`, p.Ref.ToGUID); err != nil {
+			fmt.Fprintf(os.Stderr, "line %d: error resolving to_guid %q: %v\n", p.Line, p.Ref.ToGUID, err)
+			continue
+		}
+
+		if _, err := stmt.Exec(p.FromID, toID, pq.StringArray(p.Ref.Types), pq.StringArray(p.Ref.Tags), p.Ref.Notes, 0, data.StatusEnabled); err != nil {
+			fmt.Fprintf(os.Stderr, "line %d: error inserting relation to %q: %v\n", p.Line, p.Ref.ToGUID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		fmt.Fprintf(os.Stderr, "error committing relations: %v\n", err)
+	}
+}
+
+// tokenizeEntry fills in e.TSVectorTokens via e.Lang's configured Tokenizer
+// when a source row didn't already supply a Postgres tsvector_language or
+// its own pre-computed tokens. It's called from insertEntries rather than a
+// Format's Next() so that, under ImportWithOptions, tokenizing -- the
+// CPU-heavy part of importing a row -- runs on the worker goroutines
+// alongside the insert instead of the single goroutine parsing the source
+// file.
+func (im *Importer) tokenizeEntry(e entry) (entry, error) {
+	if e.TSVectorLang != "" || e.TSVectorTokens != "" {
+		return e, nil
+	}
+
+	lang, ok := im.langs[e.Lang]
+	if !ok || lang.Tokenizer == nil {
+		return e, nil
+	}
+
+	tks, err := lang.Tokenizer.ToTokens(e.Content, e.Lang)
+	if err != nil {
+		return e, fmt.Errorf("error tokenizing content (word) '%s': %v", e.Content, err)
+	}
+
+	e.TSVectorTokens = strings.Join(tks, " ")
+	return e, nil
 }
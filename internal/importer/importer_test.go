@@ -108,14 +108,12 @@ func populateLangs() {
 	engLangTypeMap["noun"] = "Noun"
 	italianLangTypeMap["sost"] = "Sostantivo"
 	langs["italian"] = data.Lang{
-		ID:            "italian",
 		Name:          "Italian",
 		Types:         italianLangTypeMap,
 		TokenizerName: "",
 		TokenizerType: tokenizerType,
 	}
 	langs["english"] = data.Lang{
-		ID:            "english",
 		Name:          "English",
 		Types:         engLangTypeMap,
 		TokenizerName: "",
@@ -223,7 +221,7 @@ func TestImporter_Import(t *testing.T) {
 				stmtInsertRel:   tt.fields.stmtInsertRel,
 				lo:              tt.fields.lo,
 			}
-			if err := im.Import(tt.args.filePath); (err != nil) != tt.wantErr {
+			if err := im.Import(tt.args.filePath, ""); (err != nil) != tt.wantErr {
 				t.Errorf("Import() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if tt.verifyFunc != nil {
@@ -0,0 +1,162 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// formatJSONL reads newline-delimited JSON exports, one top-level entry
+// per line:
+//
+//	{"content": "word", "lang": "english", "notes": "...", "tags": ["a"],
+//	 "defs": [{"content": "parola", "lang": "italian", "types": ["noun"]}]}
+type formatJSONL struct {
+	im    *Importer
+	lines []string
+	i     int
+}
+
+// jsonlEntry mirrors the on-disk JSON-Lines record shape.
+type jsonlEntry struct {
+	GUID      string          `json:"guid"`
+	Content   string          `json:"content"`
+	Lang      string          `json:"lang"`
+	Initial   string          `json:"initial"`
+	Notes     string          `json:"notes"`
+	Tags      []string        `json:"tags"`
+	Phones    []string        `json:"phones"`
+	Meta      interface{}     `json:"meta"`
+	Defs      []jsonlEntry    `json:"defs"`
+	Types     []string        `json:"types"`
+	Relations []jsonlRelation `json:"relations"`
+}
+
+// jsonlRelation is a GUID-addressed relation nested under a top-level
+// jsonlEntry's `relations` array, eg:
+//
+//	{"content": "casa", "lang": "italian", "relations": [
+//	    {"to_guid": "3f3b...", "types": ["noun"], "tags": ["informal"]}]}
+//
+// Unlike `defs`, which creates and inserts a brand new entry, a relation
+// always points at an entry that either already exists or appears
+// elsewhere in the same file -- it's resolved by GUID after every row has
+// been read, not nested/created inline.
+type jsonlRelation struct {
+	ToGUID string   `json:"to_guid"`
+	Types  []string `json:"types"`
+	Tags   []string `json:"tags"`
+	Notes  string   `json:"notes"`
+}
+
+func detectJSONL(path string) bool {
+	return extIs(path, ".jsonl", ".ndjson")
+}
+
+func openJSONL(fp *os.File, im *Importer) (Format, error) {
+	lines, err := readAllLines(fp)
+	if err != nil {
+		return nil, err
+	}
+	return &formatJSONL{im: im, lines: lines}, nil
+}
+
+func (f *formatJSONL) Next() (entry, error) {
+	if f.i >= len(f.lines) {
+		return entry{}, io.EOF
+	}
+
+	var je jsonlEntry
+	if err := json.Unmarshal([]byte(f.lines[f.i]), &je); err != nil {
+		return entry{}, fmt.Errorf("line %d: %v", f.i+1, err)
+	}
+	f.i++
+
+	e, err := f.im.toEntry(je, typeEntry)
+	if err != nil {
+		return entry{}, err
+	}
+
+	for _, jd := range je.Defs {
+		d, err := f.im.toEntry(jd, typeDef)
+		if err != nil {
+			return entry{}, err
+		}
+		e.defs = append(e.defs, d)
+	}
+
+	return e, nil
+}
+
+// toEntry validates and converts a jsonlEntry into the importer's common
+// entry representation, applying the same language/tokenizer/meta rules
+// the CSV format uses.
+func (im *Importer) toEntry(je jsonlEntry, typ string) (entry, error) {
+	lang, ok := im.langs[je.Lang]
+	if !ok {
+		return entry{}, fmt.Errorf("unknown language '%s'", je.Lang)
+	}
+
+	if je.Content == "" {
+		return entry{}, fmt.Errorf("empty content for language '%s'", je.Lang)
+	}
+
+	e := entry{
+		Type:     typ,
+		GUID:     je.GUID,
+		Content:  je.Content,
+		Lang:     je.Lang,
+		Initial:  je.Initial,
+		Notes:    je.Notes,
+		Tags:     je.Tags,
+		Phones:   je.Phones,
+		DefTypes: je.Types,
+	}
+	if e.Initial == "" {
+		e.Initial = strings.ToUpper(string(e.Content[0]))
+	}
+
+	// Relations are only meaningful on the top-level entry -- a def is
+	// always a brand new entry created and related inline by
+	// insertEntries, not addressed by GUID.
+	if typ == typeEntry {
+		for _, jr := range je.Relations {
+			e.Relations = append(e.Relations, relRef{
+				ToGUID: jr.ToGUID,
+				Types:  jr.Types,
+				Tags:   jr.Tags,
+				Notes:  jr.Notes,
+			})
+		}
+	}
+
+	if typ == typeDef {
+		for _, t := range e.DefTypes {
+			if _, ok := lang.Types[t]; !ok {
+				return entry{}, fmt.Errorf("unknown type '%s' for language '%s'", t, je.Lang)
+			}
+		}
+	}
+
+	if lang.Tokenizer != nil {
+		tks, err := lang.Tokenizer.ToTokens(e.Content, e.Lang)
+		if err != nil {
+			return entry{}, fmt.Errorf("error tokenizing content '%s': %v", e.Content, err)
+		}
+		e.TSVectorTokens = strings.Join(tks, " ")
+	}
+
+	if je.Meta != nil {
+		b, err := json.Marshal(je.Meta)
+		if err != nil {
+			return entry{}, fmt.Errorf("error marshalling meta for '%s': %v", e.Content, err)
+		}
+		e.Meta = string(b)
+	} else {
+		e.Meta = "{}"
+	}
+
+	return e, nil
+}
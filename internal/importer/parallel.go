@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ImportOptions configures ImportWithOptions' concurrency, batch size and
+// resumability.
+type ImportOptions struct {
+	// Workers is the number of goroutines committing batches to the DB
+	// concurrently, each owning its own transaction and prepared statements
+	// (via insertEntries). < 1 is treated as 1.
+	Workers int
+
+	// BatchSize overrides insertBatchSize, the number of main entries (each
+	// with its nested defs) committed per transaction. < 1 keeps the
+	// default.
+	BatchSize int
+
+	// Resume skips forward past the entry count recorded in the checkpoint
+	// file (filePath + ".checkpoint") before reading resumes, so a dropped
+	// or killed import can continue instead of starting over from entry 0.
+	Resume bool
+}
+
+// importBatch is one producer-read chunk of main entries (each carrying its
+// own nested defs), tagged with its position in the source file. Because a
+// Format's entry already nests every def row under its own parent -- the
+// exact shape insertEntries expects -- a batch never splits a def from the
+// main entry it belongs to, so workers committing batches concurrently can
+// never insert a def row before its parent.
+type importBatch struct {
+	index     int // 0-based, strictly increasing in read order
+	entries   []entry
+	lineStart int // count of main entries read strictly before this batch
+}
+
+// ImportWithOptions reads filePath like Import, but with opts.Workers
+// goroutines each committing their own batches of opts.BatchSize entries
+// concurrently, and a checkpoint file that opts.Resume can pick up from
+// after an interrupted run. A single parsing goroutine still produces
+// entries off the source file -- Format readers are inherently sequential --
+// but per-entry tokenization, previously done inline while parsing, now runs
+// on the workers alongside the insert itself, so it parallelizes across
+// opts.Workers too.
+func (im *Importer) ImportWithOptions(filePath, format string, opts ImportOptions) error {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	batchSize := opts.BatchSize
+	if batchSize < 1 {
+		batchSize = insertBatchSize
+	}
+
+	name, err := detectFormat(filePath, format)
+	if err != nil {
+		return err
+	}
+
+	rd, closeFn, err := newFormat(name, filePath, im)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	cp := newImportCheckpoint(filePath)
+
+	startAt := 0
+	if opts.Resume {
+		n, err := cp.load()
+		if err != nil {
+			return fmt.Errorf("error reading checkpoint: %v", err)
+		}
+		if n > 0 {
+			im.lo.Printf("resuming from checkpoint: skipping %d already-imported entries", n)
+		}
+		startAt = n
+	}
+
+	for i := 0; i < startAt; i++ {
+		if _, err := rd.Next(); err != nil {
+			if err == io.EOF {
+				im.lo.Printf("checkpoint (%d) is past the end of the file; nothing to do", startAt)
+				return nil
+			}
+			return fmt.Errorf("error skipping to checkpoint: %v", err)
+		}
+	}
+
+	batches := make(chan importBatch)
+
+	var readErr error
+	go func() {
+		defer close(batches)
+
+		var (
+			batch []entry
+			idx   int
+			line  = startAt
+		)
+		for {
+			e, err := rd.Next()
+			if err != nil {
+				if err != io.EOF {
+					readErr = fmt.Errorf("error reading %s file %s: %v", name, filePath, err)
+				}
+				break
+			}
+
+			batch = append(batch, e)
+			if len(batch) >= batchSize {
+				batches <- importBatch{index: idx, entries: batch, lineStart: line}
+				line += len(batch)
+				idx++
+				batch = nil
+			}
+		}
+
+		if len(batch) > 0 {
+			batches <- importBatch{index: idx, entries: batch, lineStart: line}
+		}
+	}()
+
+	type result struct {
+		index     int
+		lineStart int
+		n         int
+		err       error
+	}
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				err := im.insertEntries(b.entries, b.lineStart)
+				results <- result{index: b.index, lineStart: b.lineStart, n: len(b.entries), err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Batches can finish out of order across workers, so the checkpoint is
+	// only ever advanced through the contiguous prefix of batch indexes
+	// that have completed -- a batch past a gap might not be safe to skip
+	// on a future resume. Once that prefix hits a failed batch, the
+	// checkpoint must stop advancing entirely: later batches can still
+	// complete successfully (workers keep draining whatever was already
+	// dispatched), but their rows sit after a gap the failed batch left
+	// uncommitted, and saving past that gap would make a future --resume
+	// skip it forever.
+	var (
+		pending    = make(map[int]result)
+		next       int
+		numMain    = startAt
+		firstErr   error
+		sawFailure bool
+	)
+	for r := range results {
+		pending[r.index] = r
+
+		for {
+			done, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if done.err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("error inserting entries to DB: %v", done.err)
+				}
+				sawFailure = true
+				continue
+			}
+			if sawFailure {
+				continue
+			}
+
+			numMain = done.lineStart + done.n
+			if err := cp.save(numMain); err != nil {
+				im.lo.Printf("error writing checkpoint: %v", err)
+			}
+			im.lo.Printf("imported %d entries", numMain)
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if readErr != nil {
+		return readErr
+	}
+
+	im.lo.Printf("finished. imported %d entries", numMain)
+	im.flushRelations()
+
+	// The file imported cleanly end-to-end -- drop the checkpoint so a
+	// later re-run of the same file starts over instead of silently
+	// skipping rows via a stale resume point.
+	if err := cp.remove(); err != nil {
+		im.lo.Printf("error removing checkpoint: %v", err)
+	}
+
+	return nil
+}
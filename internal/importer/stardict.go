@@ -0,0 +1,184 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// formatStardict reads a Stardict dictionary bundle: a `.ifo` metadata
+// file plus sibling `.idx` (word -> offset/size table) and `.dict` (raw
+// definition bytes) files of the same base name.
+//
+// Because a Stardict `.ifo` file has no field that maps to a dictpress
+// language code, the bundle's `.ifo` must carry an extra
+// `dictpress_lang=<code>` line naming the language every headword in it
+// belongs to.
+//
+// Compressed `.dict.dz` (dictzip) bundles aren't supported; gunzip the
+// file to a plain `.dict` first.
+type formatStardict struct {
+	im      *Importer
+	dict    []byte
+	entries []stardictIdxEntry
+	lang    string
+	i       int
+}
+
+type stardictIdxEntry struct {
+	word   string
+	offset uint64
+	size   uint32
+}
+
+func detectStardict(path string) bool {
+	return extIs(path, ".ifo")
+}
+
+func openStardict(fp *os.File, im *Importer) (Format, error) {
+	ifo, err := parseStardictIfo(fp)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing stardict .ifo: %v", err)
+	}
+
+	lang := ifo["dictpress_lang"]
+	if lang == "" {
+		return nil, fmt.Errorf("stardict .ifo is missing a 'dictpress_lang=<code>' line")
+	}
+	if _, ok := im.langs[lang]; !ok {
+		return nil, fmt.Errorf("unknown language '%s' in dictpress_lang", lang)
+	}
+
+	offsetBits := 32
+	if v := ifo["idxoffsetbits"]; v == "64" {
+		offsetBits = 64
+	}
+
+	base := strings.TrimSuffix(fp.Name(), filepath.Ext(fp.Name()))
+
+	dictPath := base + ".dict"
+	dict, err := os.ReadFile(dictPath)
+	if err != nil {
+		if _, err2 := os.Stat(dictPath + ".dz"); err2 == nil {
+			return nil, fmt.Errorf("%s.dict.dz is dictzip-compressed, which isn't supported; gunzip it to %s.dict first", base, base)
+		}
+		return nil, fmt.Errorf("error reading %s: %v", dictPath, err)
+	}
+
+	idxPath := base + ".idx"
+	idxB, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", idxPath, err)
+	}
+
+	entries, err := parseStardictIdx(idxB, offsetBits)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", idxPath, err)
+	}
+
+	return &formatStardict{im: im, dict: dict, entries: entries, lang: lang}, nil
+}
+
+// parseStardictIfo reads the `key=value` lines of a .ifo file.
+func parseStardictIfo(r io.Reader) (map[string]string, error) {
+	out := make(map[string]string)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out, sc.Err()
+}
+
+// parseStardictIdx parses the .idx word list: each record is a
+// NUL-terminated word followed by a big-endian offset (4 or 8 bytes,
+// depending on offsetBits) and a big-endian 4-byte size into the .dict file.
+func parseStardictIdx(b []byte, offsetBits int) ([]stardictIdxEntry, error) {
+	var (
+		out    []stardictIdxEntry
+		offLen = offsetBits / 8
+	)
+
+	for len(b) > 0 {
+		nul := bytes.IndexByte(b, 0)
+		if nul < 0 {
+			return nil, fmt.Errorf("malformed .idx: missing NUL terminator")
+		}
+		word := string(b[:nul])
+		b = b[nul+1:]
+
+		if len(b) < offLen+4 {
+			return nil, fmt.Errorf("malformed .idx: truncated record for '%s'", word)
+		}
+
+		var offset uint64
+		if offLen == 8 {
+			offset = binary.BigEndian.Uint64(b[:8])
+		} else {
+			offset = uint64(binary.BigEndian.Uint32(b[:4]))
+		}
+		size := binary.BigEndian.Uint32(b[offLen : offLen+4])
+		b = b[offLen+4:]
+
+		out = append(out, stardictIdxEntry{word: word, offset: offset, size: size})
+	}
+
+	return out, nil
+}
+
+func (f *formatStardict) Next() (entry, error) {
+	if f.i >= len(f.entries) {
+		return entry{}, io.EOF
+	}
+	ie := f.entries[f.i]
+	f.i++
+
+	if ie.offset+uint64(ie.size) > uint64(len(f.dict)) {
+		return entry{}, fmt.Errorf("entry '%s': offset/size out of bounds (offset=%d size=%d, dict size=%d)",
+			ie.word, ie.offset, ie.size, len(f.dict))
+	}
+	def := strings.TrimSpace(string(f.dict[ie.offset : ie.offset+uint64(ie.size)]))
+
+	content := strings.TrimSpace(ie.word)
+	if content == "" {
+		return entry{}, fmt.Errorf("entry %d: empty headword", f.i)
+	}
+
+	e := entry{
+		Type:    typeEntry,
+		Content: content,
+		Lang:    f.lang,
+		Initial: strings.ToUpper(string(content[0])),
+		Meta:    "{}",
+	}
+
+	lang := f.im.langs[f.lang]
+	if lang.Tokenizer != nil {
+		tks, err := lang.Tokenizer.ToTokens(e.Content, e.Lang)
+		if err != nil {
+			return entry{}, fmt.Errorf("entry '%s': error tokenizing: %v", content, err)
+		}
+		e.TSVectorTokens = strings.Join(tks, " ")
+	}
+
+	if def != "" {
+		e.defs = append(e.defs, entry{
+			Type:    typeDef,
+			Content: def,
+			Lang:    f.lang,
+			Initial: strings.ToUpper(string(def[0])),
+			Meta:    "{}",
+		})
+	}
+
+	return e, nil
+}
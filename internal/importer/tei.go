@@ -0,0 +1,145 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// teiDoc is the subset of TEI Lex-0 this importer understands: a flat list
+// of <entry> elements under <text><body>.
+type teiDoc struct {
+	XMLName xml.Name   `xml:"TEI"`
+	Entries []teiEntry `xml:"text>body>entry"`
+}
+
+type teiEntry struct {
+	// xml:lang is in the XML namespace, hence the full URI below.
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Form struct {
+		Orth string `xml:"orth"`
+	} `xml:"form"`
+	GramGrp struct {
+		POS string `xml:"pos"`
+	} `xml:"gramGrp"`
+	Senses []teiSense `xml:"sense"`
+}
+
+type teiSense struct {
+	Cits []teiCit `xml:"cit"`
+}
+
+type teiCit struct {
+	Type  string `xml:"type,attr"`
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Quote string `xml:"quote"`
+}
+
+// formatTEI reads a TEI Lex-0 XML dictionary, mapping <entry>/<form>/<orth>
+// to the main Entry.Content, <sense>/<cit type="translation"> to related
+// entries in the <cit>'s own xml:lang, and <gramGrp>/<pos> to the
+// relation's Types.
+type formatTEI struct {
+	im      *Importer
+	entries []teiEntry
+	i       int
+}
+
+func detectTEI(path string) bool {
+	return extIs(path, ".xml", ".tei")
+}
+
+func openTEI(fp *os.File, im *Importer) (Format, error) {
+	b, err := io.ReadAll(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc teiDoc
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing TEI XML: %v", err)
+	}
+
+	return &formatTEI{im: im, entries: doc.Entries}, nil
+}
+
+func (f *formatTEI) Next() (entry, error) {
+	if f.i >= len(f.entries) {
+		return entry{}, io.EOF
+	}
+	te := f.entries[f.i]
+	f.i++
+
+	lang, ok := f.im.langs[te.Lang]
+	if !ok {
+		return entry{}, fmt.Errorf("entry %d: unknown language '%s' in xml:lang", f.i, te.Lang)
+	}
+
+	content := strings.TrimSpace(te.Form.Orth)
+	if content == "" {
+		return entry{}, fmt.Errorf("entry %d: empty <form><orth> content", f.i)
+	}
+
+	e := entry{
+		Type:    typeEntry,
+		Content: content,
+		Lang:    te.Lang,
+		Initial: strings.ToUpper(string(content[0])),
+		Meta:    "{}",
+	}
+	if lang.Tokenizer != nil {
+		tks, err := lang.Tokenizer.ToTokens(e.Content, e.Lang)
+		if err != nil {
+			return entry{}, fmt.Errorf("entry %d: error tokenizing '%s': %v", f.i, e.Content, err)
+		}
+		e.TSVectorTokens = strings.Join(tks, " ")
+	}
+
+	// <gramGrp>/<pos> applies to every translation nested under this entry
+	// unless the language doesn't define the POS as a type, in which case
+	// it's silently dropped rather than failing the whole import, since
+	// TEI sources commonly use POS vocabularies that don't map 1:1.
+	var types []string
+	if te.GramGrp.POS != "" {
+		types = []string{te.GramGrp.POS}
+	}
+
+	for _, sense := range te.Senses {
+		for _, cit := range sense.Cits {
+			if cit.Type != "translation" {
+				continue
+			}
+
+			quote := strings.TrimSpace(cit.Quote)
+			if quote == "" {
+				continue
+			}
+
+			toLang, ok := f.im.langs[cit.Lang]
+			if !ok {
+				return entry{}, fmt.Errorf("entry %d: unknown translation language '%s'", f.i, cit.Lang)
+			}
+
+			defTypes := types
+			for _, t := range defTypes {
+				if _, ok := toLang.Types[t]; !ok {
+					defTypes = nil
+					break
+				}
+			}
+
+			e.defs = append(e.defs, entry{
+				Type:     typeDef,
+				Content:  quote,
+				Lang:     cit.Lang,
+				Initial:  strings.ToUpper(string(quote[0])),
+				DefTypes: defTypes,
+				Meta:     "{}",
+			})
+		}
+	}
+
+	return e, nil
+}
@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// wordnetDoc is the subset of WN-LMF (WordNet Lexical Markup Framework) this
+// importer understands: a single <Lexicon> holding a flat list of
+// <LexicalEntry> headwords and the <Synset> definitions they point to.
+type wordnetDoc struct {
+	XMLName xml.Name `xml:"LexicalResource"`
+	Lexicon struct {
+		// Lang is read the same way formatTEI reads xml:lang: it must match
+		// one of this installation's configured language keys directly,
+		// not a BCP-47/ISO code.
+		Lang    string          `xml:"language,attr"`
+		Entries []wordnetEntry  `xml:"LexicalEntry"`
+		Synsets []wordnetSynset `xml:"Synset"`
+	} `xml:"Lexicon"`
+}
+
+type wordnetEntry struct {
+	Lemma struct {
+		WrittenForm string `xml:"writtenForm,attr"`
+		POS         string `xml:"partOfSpeech,attr"`
+	} `xml:"Lemma"`
+	Senses []struct {
+		Synset string `xml:"synset,attr"`
+	} `xml:"Sense"`
+}
+
+type wordnetSynset struct {
+	ID          string `xml:"id,attr"`
+	Definitions []struct {
+		Text string `xml:",chardata"`
+	} `xml:"Definition"`
+}
+
+// formatWordnet reads a WN-LMF XML lexicon, mapping each <LexicalEntry>/
+// <Lemma writtenForm="..."> to the main Entry.Content and every gloss in the
+// <Synset>s its <Sense>s point to into definition sub-entries in the same
+// language, tagged with the Lemma's partOfSpeech.
+type formatWordnet struct {
+	im      *Importer
+	lang    string
+	entries []wordnetEntry
+	synsets map[string]wordnetSynset
+	i       int
+}
+
+func detectWordnet(path string) bool {
+	return extIs(path, ".wn-lmf", ".wnlmf")
+}
+
+func openWordnet(fp *os.File, im *Importer) (Format, error) {
+	b, err := io.ReadAll(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc wordnetDoc
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing WN-LMF XML: %v", err)
+	}
+
+	if _, ok := im.langs[doc.Lexicon.Lang]; !ok {
+		return nil, fmt.Errorf("unknown language '%s' in Lexicon[language]", doc.Lexicon.Lang)
+	}
+
+	synsets := make(map[string]wordnetSynset, len(doc.Lexicon.Synsets))
+	for _, s := range doc.Lexicon.Synsets {
+		synsets[s.ID] = s
+	}
+
+	return &formatWordnet{im: im, lang: doc.Lexicon.Lang, entries: doc.Lexicon.Entries, synsets: synsets}, nil
+}
+
+func (f *formatWordnet) Next() (entry, error) {
+	if f.i >= len(f.entries) {
+		return entry{}, io.EOF
+	}
+	we := f.entries[f.i]
+	f.i++
+
+	lang := f.im.langs[f.lang]
+
+	content := strings.TrimSpace(we.Lemma.WrittenForm)
+	if content == "" {
+		return entry{}, fmt.Errorf("entry %d: empty Lemma[writtenForm]", f.i)
+	}
+
+	e := entry{
+		Type:    typeEntry,
+		Content: content,
+		Lang:    f.lang,
+		Initial: strings.ToUpper(string(content[0])),
+		Meta:    "{}",
+	}
+	if lang.Tokenizer != nil {
+		tks, err := lang.Tokenizer.ToTokens(e.Content, e.Lang)
+		if err != nil {
+			return entry{}, fmt.Errorf("entry %d: error tokenizing '%s': %v", f.i, e.Content, err)
+		}
+		e.TSVectorTokens = strings.Join(tks, " ")
+	}
+
+	// partOfSpeech applies to every gloss definition this Lemma's senses
+	// point to, dropped (rather than failing the import) if the language
+	// doesn't define it as a type, since WordNet's single-letter POS
+	// vocabulary (n, v, a, r, s) rarely matches a language's own types 1:1.
+	var types []string
+	if we.Lemma.POS != "" {
+		if _, ok := lang.Types[we.Lemma.POS]; ok {
+			types = []string{we.Lemma.POS}
+		}
+	}
+
+	for _, sense := range we.Senses {
+		syn, ok := f.synsets[sense.Synset]
+		if !ok {
+			continue
+		}
+
+		for _, def := range syn.Definitions {
+			gloss := strings.TrimSpace(def.Text)
+			if gloss == "" {
+				continue
+			}
+
+			e.defs = append(e.defs, entry{
+				Type:     typeDef,
+				Content:  gloss,
+				Lang:     f.lang,
+				Initial:  strings.ToUpper(string(gloss[0])),
+				DefTypes: types,
+				Meta:     "{}",
+			})
+		}
+	}
+
+	return e, nil
+}
@@ -0,0 +1,225 @@
+// Package jobs implements a lightweight in-process background job runner
+// for long-running admin operations (bulk import, re-tokenization, search
+// index rebuilds, ...) that don't fit the synchronous request/response of a
+// regular handler. A job is enqueued, runs on a bounded worker pool, and is
+// polled for progress instead of holding an HTTP connection open for the
+// duration of the work.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job states.
+const (
+	StatePending  = "pending"
+	StateRunning  = "running"
+	StateDone     = "done"
+	StateFailed   = "failed"
+	StateCanceled = "canceled"
+)
+
+// ErrNotFound is returned by Get/Cancel for an unknown job ID.
+var ErrNotFound = errors.New("job not found")
+
+// ErrUnknownKind is returned by Enqueue for a kind with no registered Handler.
+var ErrUnknownKind = errors.New("unknown job kind")
+
+// Job tracks the lifecycle of a single unit of background work.
+type Job struct {
+	ID         int             `json:"id" db:"id"`
+	Kind       string          `json:"kind" db:"kind"`
+	State      string          `json:"state" db:"state"`
+	Progress   int             `json:"progress" db:"progress"`
+	Total      int             `json:"total" db:"total"`
+	Error      string          `json:"error,omitempty" db:"error"`
+	Result     json.RawMessage `json:"result,omitempty" db:"result"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty" db:"finished_at"`
+
+	cancel context.CancelFunc
+}
+
+// Progress is called by a running Handler to report how far along it is.
+// total of 0 means the total is unknown (eg: a streamed input).
+type Progress func(done, total int)
+
+// Handler performs the work behind one job Kind, reporting progress as it
+// goes. ctx is canceled if the job is canceled via Runner.Cancel; a Handler
+// should check ctx.Err() between units of work to stop early.
+type Handler func(ctx context.Context, input json.RawMessage, progress Progress) (result json.RawMessage, err error)
+
+// Store persists job metadata so it survives process restarts. A nil Store
+// passed to New makes jobs in-memory only.
+type Store interface {
+	Insert(j *Job) error
+	Update(j *Job) error
+}
+
+// Runner is a bounded worker pool that runs registered job Handlers and
+// tracks every Job's lifecycle in memory, optionally mirrored to a Store.
+type Runner struct {
+	store    Store
+	handlers map[string]Handler
+	sem      chan struct{}
+
+	mu     sync.Mutex
+	jobs   map[int]*Job
+	nextID int
+}
+
+// New returns a Runner that runs at most concurrency jobs at once. store may
+// be nil to disable persistence.
+func New(concurrency int, store Store) *Runner {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &Runner{
+		store:    store,
+		handlers: make(map[string]Handler),
+		sem:      make(chan struct{}, concurrency),
+		jobs:     make(map[int]*Job),
+	}
+}
+
+// Register adds a Handler for the given job kind. It's not safe to call
+// concurrently with Enqueue; register every kind at startup before serving
+// requests.
+func (r *Runner) Register(kind string, h Handler) {
+	r.handlers[kind] = h
+}
+
+// Enqueue creates a new job of the given kind and starts h running on it in
+// the background as soon as a worker slot is free.
+func (r *Runner) Enqueue(kind string, input json.RawMessage) (*Job, error) {
+	h, ok := r.handlers[kind]
+	if !ok {
+		return nil, ErrUnknownKind
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.mu.Lock()
+	r.nextID++
+	j := &Job{ID: r.nextID, Kind: kind, State: StatePending, CreatedAt: time.Now(), cancel: cancel}
+	r.jobs[j.ID] = j
+	r.mu.Unlock()
+
+	if r.store != nil {
+		if err := r.store.Insert(j); err != nil {
+			cancel()
+			return nil, fmt.Errorf("error persisting job: %v", err)
+		}
+	}
+
+	go r.run(ctx, j, h, input)
+
+	return j.clone(), nil
+}
+
+// run executes h for j, blocking until a worker slot is available, updating
+// and persisting j's state as it progresses.
+func (r *Runner) run(ctx context.Context, j *Job, h Handler, input json.RawMessage) {
+	r.sem <- struct{}{}
+	defer func() { <-r.sem }()
+
+	r.mu.Lock()
+	j.State = StateRunning
+	r.mu.Unlock()
+	r.persist(j)
+
+	res, err := h(ctx, input, func(done, total int) {
+		r.mu.Lock()
+		j.Progress, j.Total = done, total
+		r.mu.Unlock()
+		r.persist(j)
+	})
+
+	now := time.Now()
+
+	r.mu.Lock()
+	j.FinishedAt = &now
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		j.State = StateCanceled
+	case err != nil:
+		j.State, j.Error = StateFailed, err.Error()
+	default:
+		j.State, j.Result = StateDone, res
+	}
+	r.mu.Unlock()
+
+	r.persist(j)
+}
+
+// persist mirrors j's current state to Store, if configured.
+func (r *Runner) persist(j *Job) {
+	if r.store == nil {
+		return
+	}
+
+	if err := r.store.Update(j.clone()); err != nil {
+		// Persistence is best-effort: the in-memory state Get/List serve
+		// from is already correct, so a Store hiccup doesn't lose progress
+		// a client is polling for, only the ability to recover it across a
+		// restart.
+	}
+}
+
+// Get returns the job with the given ID.
+func (r *Runner) Get(id int) (*Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return j.clone(), nil
+}
+
+// List returns every tracked job, most recently created first.
+func (r *Runner) List() []*Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]*Job, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		out = append(out, j.clone())
+	}
+
+	sort.Slice(out, func(i, k int) bool { return out[i].ID > out[k].ID })
+
+	return out
+}
+
+// Cancel signals the job's context.CancelFunc, asking its Handler to stop.
+// It's advisory: a Handler only actually stops early if it checks ctx.Err().
+func (r *Runner) Cancel(id int) error {
+	r.mu.Lock()
+	j, ok := r.jobs[id]
+	r.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+
+	j.cancel()
+
+	return nil
+}
+
+// clone returns a copy of j safe to hand to a caller outside the Runner's
+// lock.
+func (j *Job) clone() *Job {
+	cp := *j
+	cp.cancel = nil
+	return &cp
+}
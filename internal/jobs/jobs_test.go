@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func waitFinished(t *testing.T, r *Runner, id int) *Job {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		j, err := r.Get(id)
+		assert.NilError(t, err)
+
+		if j.State == StateDone || j.State == StateFailed || j.State == StateCanceled {
+			return j
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("job did not finish in time")
+	return nil
+}
+
+func TestRunnerEnqueueDone(t *testing.T) {
+	r := New(1, nil)
+	r.Register("echo", func(ctx context.Context, input json.RawMessage, progress Progress) (json.RawMessage, error) {
+		progress(1, 1)
+		return input, nil
+	})
+
+	j, err := r.Enqueue("echo", json.RawMessage(`"hi"`))
+	assert.NilError(t, err)
+
+	j = waitFinished(t, r, j.ID)
+	assert.Equal(t, j.State, StateDone)
+	assert.Equal(t, string(j.Result), `"hi"`)
+}
+
+func TestRunnerEnqueueFailed(t *testing.T) {
+	r := New(1, nil)
+	r.Register("fail", func(ctx context.Context, input json.RawMessage, progress Progress) (json.RawMessage, error) {
+		return nil, assertErr
+	})
+
+	j, err := r.Enqueue("fail", nil)
+	assert.NilError(t, err)
+
+	j = waitFinished(t, r, j.ID)
+	assert.Equal(t, j.State, StateFailed)
+	assert.Equal(t, j.Error, assertErr.Error())
+}
+
+func TestRunnerEnqueueUnknownKind(t *testing.T) {
+	r := New(1, nil)
+
+	_, err := r.Enqueue("nope", nil)
+	assert.ErrorIs(t, err, ErrUnknownKind)
+}
+
+func TestRunnerGetNotFound(t *testing.T) {
+	r := New(1, nil)
+
+	_, err := r.Get(99)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRunnerCancel(t *testing.T) {
+	r := New(1, nil)
+	started := make(chan struct{})
+	r.Register("slow", func(ctx context.Context, input json.RawMessage, progress Progress) (json.RawMessage, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	j, err := r.Enqueue("slow", nil)
+	assert.NilError(t, err)
+
+	<-started
+	assert.NilError(t, r.Cancel(j.ID))
+
+	j = waitFinished(t, r, j.ID)
+	assert.Equal(t, j.State, StateCanceled)
+}
+
+func TestRunnerList(t *testing.T) {
+	r := New(2, nil)
+	r.Register("echo", func(ctx context.Context, input json.RawMessage, progress Progress) (json.RawMessage, error) {
+		return nil, nil
+	})
+
+	a, err := r.Enqueue("echo", nil)
+	assert.NilError(t, err)
+	b, err := r.Enqueue("echo", nil)
+	assert.NilError(t, err)
+
+	waitFinished(t, r, a.ID)
+	waitFinished(t, r, b.ID)
+
+	list := r.List()
+	assert.Equal(t, len(list), 2)
+	assert.Equal(t, list[0].ID, b.ID)
+	assert.Equal(t, list[1].ID, a.ID)
+}
+
+var assertErr = jobErr("boom")
+
+type jobErr string
+
+func (e jobErr) Error() string { return string(e) }
@@ -0,0 +1,77 @@
+// Package markdown renders user-authored Markdown (dictionary entry and
+// relation notes) to sanitized HTML, and strips it back down to plain text
+// for contexts that can't render HTML (glossary listings, search snippets,
+// gob-cached API responses).
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Options controls how a single Render call behaves, mirroring the
+// per-language knobs an editor may want (data.Lang.NotesMarkdown/NotesAutoLink).
+type Options struct {
+	// AutoLink enables goldmark's linkify extension, which turns bare
+	// "https://..." URLs into links. Some scripts/languages don't want
+	// this (eg: it can misfire on certain non-Latin text), so it's opt-in
+	// per language rather than global.
+	AutoLink bool
+}
+
+var (
+	// strictPolicy allows only a small set of inline/structural formatting
+	// tags -- enough for dictionary usage notes, nothing that could carry
+	// scripts, styles or iframes.
+	strictPolicy = newPolicy()
+
+	// mdSyntax strips the common Markdown punctuation so Strip() can fall
+	// back to a plain read when no Markdown was ever rendered for a note.
+	mdSyntax = regexp.MustCompile("(?m)(^#{1,6}\\s+|^>\\s+|^[-*+]\\s+|^\\d+\\.\\s+|[*_`~]{1,3}|\\[([^\\]]*)\\]\\(([^)]*)\\))")
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "strong", "em", "ul", "ol", "li", "blockquote", "code", "pre")
+	p.AllowStandardURLs()
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Render converts Markdown source to sanitized HTML, allowlisting only
+// basic formatting (paragraphs, emphasis, lists, links, inline/block code)
+// and stripping everything else -- scripts, styles, raw HTML, images.
+func Render(src string, o Options) (string, error) {
+	if strings.TrimSpace(src) == "" {
+		return "", nil
+	}
+
+	md := goldmark.New()
+	if o.AutoLink {
+		md = goldmark.New(goldmark.WithExtensions(extension.Linkify))
+	}
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+
+	return strictPolicy.Sanitize(buf.String()), nil
+}
+
+// Strip reduces Markdown (or already-rendered HTML) source to plain text,
+// for contexts such as glossary listings and search snippets that can't
+// render HTML: Markdown punctuation is dropped and any HTML tags are
+// unwrapped rather than escaped.
+func Strip(src string) string {
+	s := bluemonday.StrictPolicy().SanitizeBytes([]byte(src))
+	out := mdSyntax.ReplaceAllString(string(s), "$2")
+	return strings.TrimSpace(out)
+}
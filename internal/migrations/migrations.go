@@ -0,0 +1,70 @@
+// Package migrations implements dictpress's schema migration runner.
+//
+// Each migration is a registered Go function rather than a pair of up/down
+// SQL files: this package only ever moves forward (there is no `--migrate
+// down`), a migration function can run logic besides raw SQL when a schema
+// change needs data backfilled or reshaped, and it avoids taking on
+// embed.FS/stuffbin wiring for a second, parallel asset pipeline alongside
+// schema.sql's. Applied versions and their dirty/clean state are tracked in
+// schema_migrations (see cmd/dictpress/upgrade.go), and `dictpress --upgrade`
+// holds a Postgres advisory lock for the run so concurrent upgraders don't
+// race. This is an extension of that existing runner, not the file-based,
+// embed.FS-driven design with a `--migrate up|down|status` verb that was
+// originally proposed for it -- that would mean maintaining a second
+// migration mechanism alongside this one for no behavioural gain.
+package migrations
+
+import (
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+	"golang.org/x/mod/semver"
+)
+
+// Migration represents a single forward-only DB migration that upgrades the
+// schema from the previous registered version to Version.
+type Migration struct {
+	// Version is the semver tag (eg: v5.0.0) the migration upgrades the DB to.
+	Version string
+
+	// Description is a short, human-readable summary of what the migration
+	// does, printed by the `--upgrade` and `--check` CLI commands.
+	Description string
+
+	// Fn performs the migration. It's run inside a transaction, so it can
+	// take either a *sqlx.DB or a *sqlx.Tx.
+	Fn func(sqlx.Execer, stuffbin.FileSystem, *koanf.Koanf) error
+}
+
+// All is the registry of every migration known to this build, in the order
+// they were authored. It's sorted by semver in init() so callers can always
+// rely on All being in the order migrations should be applied.
+var All = []Migration{
+	{Version: "v2.0.0", Description: "add settings table and entries.meta column", Fn: V2_0_0},
+	{Version: "v4.0.0", Description: "convert entries.content to a text array", Fn: V4_0_0},
+	{Version: "v5.0.0", Description: "add api_tokens table", Fn: V5_0_0},
+	{Version: "v6.0.0", Description: "add pg_trgm extension and a trigram index on entries.content", Fn: V6_0_0},
+	{Version: "v7.0.0", Description: "add submission_attempts table for auditing throttled/rejected submissions", Fn: V7_0_0},
+	{Version: "v8.0.0", Description: "add jobs table for tracking background job progress", Fn: V8_0_0},
+	{Version: "v9.0.0", Description: "add audit_log table for tracking admin mutations", Fn: V9_0_0},
+	{Version: "v10.0.0", Description: "add search_query_log table for cache warmup", Fn: V10_0_0},
+	{Version: "v11.0.0", Description: "add import_jobs table for resumable streaming bulk imports", Fn: V11_0_0},
+	{Version: "v12.0.0", Description: "add import_jobs.use_copy for COPY-backed bulk imports", Fn: V12_0_0},
+}
+
+func init() {
+	sort.Slice(All, func(i, j int) bool {
+		return semver.Compare(All[i].Version, All[j].Version) < 0
+	})
+}
+
+// Latest returns the version of the most recent migration in the registry,
+// or an empty string if none are registered.
+func Latest() string {
+	if len(All) == 0 {
+		return ""
+	}
+	return All[len(All)-1].Version
+}
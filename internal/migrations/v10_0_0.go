@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V10_0_0 performs the DB migrations.
+func V10_0_0(db sqlx.Execer, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS search_query_log (
+			id SERIAL PRIMARY KEY,
+			from_lang TEXT NOT NULL DEFAULT '',
+			to_lang TEXT NOT NULL DEFAULT '',
+			query TEXT NOT NULL DEFAULT '',
+			types TEXT[] NOT NULL DEFAULT '{}',
+			tags TEXT[] NOT NULL DEFAULT '{}',
+			status TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_search_query_log_warmup ON search_query_log(from_lang, created_at);
+	`); err != nil {
+		return err
+	}
+
+	return nil
+}
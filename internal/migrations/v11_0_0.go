@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V11_0_0 performs the DB migrations.
+func V11_0_0(db sqlx.Execer, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS import_jobs (
+			id SERIAL PRIMARY KEY,
+			job_id INT REFERENCES jobs(id) ON DELETE CASCADE,
+			format TEXT NOT NULL,
+			dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+			body BYTEA NOT NULL,
+			total INT NOT NULL DEFAULT 0,
+			cursor INT NOT NULL DEFAULT 0,
+			errors INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_import_jobs_job_id ON import_jobs(job_id);
+	`); err != nil {
+		return err
+	}
+
+	return nil
+}
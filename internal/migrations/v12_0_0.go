@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V12_0_0 performs the DB migrations.
+func V12_0_0(db sqlx.Execer, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	if _, err := db.Exec(`
+		ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS use_copy BOOLEAN NOT NULL DEFAULT FALSE;
+	`); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -7,7 +7,7 @@ import (
 )
 
 // V2_0_0 performs the DB migrations.
-func V2_0_0(db *sqlx.DB, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+func V2_0_0(db sqlx.Execer, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
 	if _, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS settings (
 			key             TEXT NOT NULL UNIQUE,
@@ -7,7 +7,7 @@ import (
 )
 
 // V4_0_0 performs the DB migrations.
-func V4_0_0(db *sqlx.DB, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+func V4_0_0(db sqlx.Execer, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
 	if _, err := db.Exec(`
 		DO $$
 		BEGIN
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V5_0_0 performs the DB migrations.
+func V5_0_0(db sqlx.Execer, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id              SERIAL PRIMARY KEY,
+			name            TEXT NOT NULL,
+			token_hash      TEXT NOT NULL UNIQUE,
+			scopes          TEXT[] NOT NULL DEFAULT '{}',
+			enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+			last_used_at    TIMESTAMP WITH TIME ZONE,
+			created_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_api_tokens_token_hash ON api_tokens(token_hash);
+	`); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V6_0_0 performs the DB migrations.
+func V6_0_0(db sqlx.Execer, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	if _, err := db.Exec(`
+		CREATE EXTENSION IF NOT EXISTS pg_trgm;
+		CREATE INDEX IF NOT EXISTS idx_entries_content_trgm ON entries
+			USING GIN ((ARRAY_TO_STRING(content, ' ')) gin_trgm_ops);
+	`); err != nil {
+		return err
+	}
+
+	return nil
+}
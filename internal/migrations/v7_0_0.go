@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V7_0_0 performs the DB migrations.
+func V7_0_0(db sqlx.Execer, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS submission_attempts (
+			id SERIAL PRIMARY KEY,
+			ip TEXT NOT NULL,
+			lang TEXT NOT NULL DEFAULT '',
+			reason TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_submission_attempts_created_at ON submission_attempts(created_at);
+	`); err != nil {
+		return err
+	}
+
+	return nil
+}
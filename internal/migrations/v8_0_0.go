@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V8_0_0 performs the DB migrations.
+func V8_0_0(db sqlx.Execer, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS jobs (
+			id SERIAL PRIMARY KEY,
+			kind TEXT NOT NULL,
+			state TEXT NOT NULL,
+			progress INT NOT NULL DEFAULT 0,
+			total INT NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			result JSONB,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			finished_at TIMESTAMPTZ
+		);
+		CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON jobs(created_at);
+	`); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V9_0_0 performs the DB migrations.
+func V9_0_0(db sqlx.Execer, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id SERIAL PRIMARY KEY,
+			actor TEXT NOT NULL DEFAULT '',
+			action TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			entity_id INT NOT NULL DEFAULT 0,
+			before JSONB,
+			after JSONB,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id);
+		CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+	`); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,339 @@
+package searchindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/dictpress/internal/data"
+	"github.com/lib/pq"
+)
+
+const (
+	// reindexBatchSize is how many rows are pulled from Postgres and bulk
+	// indexed per round-trip during Reindex.
+	reindexBatchSize = 500
+
+	osHTTPTimeout = 15 * time.Second
+)
+
+// langAnalyzers maps a dictpress language key to the OpenSearch/Elasticsearch
+// analyzer best suited to it. Languages not listed here fall back to the
+// "standard" analyzer. These can't be made fully generic: a handful of
+// scripts need a dedicated ICU/CJK analyzer plugin installed on the cluster
+// for the tokenization to be meaningful at all.
+var langAnalyzers = map[string]string{
+	"hi": "icu_analyzer",
+	"mr": "icu_analyzer",
+	"ne": "icu_analyzer",
+	"sa": "icu_analyzer",
+	"ur": "icu_analyzer",
+	"ta": "icu_analyzer",
+	"te": "icu_analyzer",
+	"kn": "icu_analyzer",
+	"ml": "icu_analyzer",
+	"bn": "icu_analyzer",
+	"gu": "icu_analyzer",
+	"pa": "icu_analyzer",
+	"ja": "kuromoji",
+	"zh": "smartcn",
+}
+
+// Config configures an OpenSearch backend.
+type Config struct {
+	// URL is the root of the OpenSearch/Elasticsearch cluster, eg:
+	// http://localhost:9200.
+	URL string
+
+	// Index is the name of the index documents are read from and written
+	// to. Created on first use if it doesn't already exist.
+	Index string
+}
+
+// OpenSearch is a Backend that indexes and searches dictionary entries on an
+// external OpenSearch or Elasticsearch cluster instead of Postgres
+// tsvector/pg_trgm, trading the zero-extra-infra simplicity of the default
+// postgres backend for BM25 scoring, per-language analyzers and
+// phrase-prefix autocomplete suggesters.
+type OpenSearch struct {
+	url    string
+	index  string
+	db     *sqlx.DB
+	client *http.Client
+	lo     *log.Logger
+}
+
+// osDoc is the document shape an Entry is mapped to in the index.
+type osDoc struct {
+	ID      int      `json:"id"`
+	GUID    string   `json:"guid"`
+	Lang    string   `json:"lang"`
+	Content string   `json:"content"`
+	Notes   string   `json:"notes"`
+	Tags    []string `json:"tags"`
+	Status  string   `json:"status"`
+	Weight  float64  `json:"weight"`
+	Suggest string   `json:"suggest"`
+}
+
+// New returns an OpenSearch backend and ensures its index exists, creating
+// it with per-language analyzer mappings and a phrase-prefix "suggest" field
+// if it doesn't.
+func New(cfg Config, db *sqlx.DB, lo *log.Logger) (*OpenSearch, error) {
+	o := &OpenSearch{
+		url:    strings.TrimSuffix(cfg.URL, "/"),
+		index:  cfg.Index,
+		db:     db,
+		client: &http.Client{Timeout: osHTTPTimeout},
+		lo:     lo,
+	}
+
+	if err := o.ensureIndex(); err != nil {
+		return nil, fmt.Errorf("error ensuring opensearch index: %v", err)
+	}
+
+	return o, nil
+}
+
+// ensureIndex creates o.index with a mapping that gives each of the known
+// langAnalyzers its own analyzed sub-field (content.<lang>) alongside a
+// "standard" analyzed default, plus a search_as_you_type "suggest" field for
+// phrase-prefix autocomplete. It's idempotent: OpenSearch returns 400 if the
+// index already exists, which is swallowed.
+func (o *OpenSearch) ensureIndex() error {
+	fields := map[string]interface{}{
+		"standard": map[string]string{"type": "text"},
+	}
+	for _, analyzer := range langAnalyzers {
+		fields[analyzer] = map[string]string{"type": "text", "analyzer": analyzer}
+	}
+
+	mapping := map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"id":      map[string]string{"type": "integer"},
+				"guid":    map[string]string{"type": "keyword"},
+				"lang":    map[string]string{"type": "keyword"},
+				"status":  map[string]string{"type": "keyword"},
+				"tags":    map[string]string{"type": "keyword"},
+				"weight":  map[string]string{"type": "float"},
+				"notes":   map[string]string{"type": "text"},
+				"content": map[string]interface{}{"type": "text", "fields": fields},
+				"suggest": map[string]string{"type": "search_as_you_type"},
+			},
+		},
+	}
+
+	_, err := o.do(http.MethodPut, "/"+o.index, mapping)
+	if err != nil && !strings.Contains(err.Error(), "resource_already_exists_exception") {
+		return err
+	}
+
+	return nil
+}
+
+// toDoc maps an Entry to its OpenSearch document.
+func toDoc(e data.Entry) osDoc {
+	return osDoc{
+		ID:      e.ID,
+		GUID:    e.GUID,
+		Lang:    e.Lang,
+		Content: e.Content,
+		Notes:   e.Notes,
+		Tags:    []string(e.Tags),
+		Status:  e.Status,
+		Weight:  e.Weight,
+		Suggest: e.Content,
+	}
+}
+
+// Index implements Backend.
+func (o *OpenSearch) Index(e data.Entry) error {
+	_, err := o.do(http.MethodPut,
+		fmt.Sprintf("/%s/_doc/%d", o.index, e.ID), toDoc(e))
+	return err
+}
+
+// Delete implements Backend.
+func (o *OpenSearch) Delete(id int) error {
+	_, err := o.do(http.MethodDelete, fmt.Sprintf("/%s/_doc/%d", o.index, id), nil)
+	return err
+}
+
+// Search implements Backend, running a multi_match query across the
+// per-language analyzed field (falling back to "standard") scored with
+// OpenSearch's default BM25 similarity.
+func (o *OpenSearch) Search(ctx context.Context, q data.Query) ([]data.Entry, int, error) {
+	field := "content.standard"
+	if analyzer, ok := langAnalyzers[q.FromLang]; ok {
+		field = "content." + analyzer
+	}
+
+	must := []map[string]interface{}{
+		{"match": map[string]interface{}{field: q.Query}},
+		{"term": map[string]interface{}{"lang": q.FromLang}},
+	}
+	if q.Status != "" {
+		must = append(must, map[string]interface{}{"term": map[string]interface{}{"status": q.Status}})
+	}
+	if len(q.Tags) > 0 {
+		must = append(must, map[string]interface{}{"terms": map[string]interface{}{"tags": q.Tags}})
+	}
+
+	body := map[string]interface{}{
+		"from":  q.Offset,
+		"size":  q.Limit,
+		"query": map[string]interface{}{"bool": map[string]interface{}{"must": must}},
+	}
+
+	resp, err := o.do(http.MethodPost, fmt.Sprintf("/%s/_search", o.index), body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var out struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source osDoc `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(resp, &out); err != nil {
+		return nil, 0, fmt.Errorf("error decoding opensearch response: %v", err)
+	}
+
+	entries := make([]data.Entry, 0, len(out.Hits.Hits))
+	for _, h := range out.Hits.Hits {
+		entries = append(entries, data.Entry{
+			ID:        h.Source.ID,
+			GUID:      h.Source.GUID,
+			Lang:      h.Source.Lang,
+			Content:   h.Source.Content,
+			Notes:     h.Source.Notes,
+			Tags:      pq.StringArray(h.Source.Tags),
+			Status:    h.Source.Status,
+			Weight:    h.Source.Weight,
+			Total:     out.Hits.Total.Value,
+			MatchType: data.MatchTypeExact,
+			Relations: []data.Entry{},
+		})
+	}
+
+	return entries, out.Hits.Total.Value, nil
+}
+
+// Reindex implements Backend by streaming every row out of the entries
+// table in batches of reindexBatchSize and bulk indexing them, logging
+// progress as it goes so `dictpress --reindex` has something to show.
+func (o *OpenSearch) Reindex(ctx context.Context) error {
+	var total int
+	if err := o.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM entries`); err != nil {
+		return fmt.Errorf("error counting entries: %v", err)
+	}
+
+	var done int
+	for offset := 0; ; offset += reindexBatchSize {
+		var rows []data.Entry
+		if err := o.db.SelectContext(ctx, &rows,
+			`SELECT id, guid, lang, content, notes, tags, weight, status
+			 FROM entries ORDER BY id LIMIT $1 OFFSET $2`,
+			reindexBatchSize, offset); err != nil {
+			return fmt.Errorf("error reading entries batch at offset %d: %v", offset, err)
+		}
+
+		if len(rows) == 0 {
+			break
+		}
+
+		if err := o.bulkIndex(rows); err != nil {
+			return fmt.Errorf("error bulk indexing batch at offset %d: %v", offset, err)
+		}
+
+		done += len(rows)
+		o.lo.Printf("reindex: %d/%d entries", done, total)
+	}
+
+	return nil
+}
+
+// bulkIndex sends a batch of entries to OpenSearch's _bulk API in one
+// round-trip instead of one request per document.
+func (o *OpenSearch) bulkIndex(entries []data.Entry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		action, _ := json.Marshal(map[string]interface{}{
+			"index": map[string]interface{}{"_index": o.index, "_id": e.ID},
+		})
+		doc, _ := json.Marshal(toDoc(e))
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(doc)
+		buf.WriteByte('\n')
+	}
+
+	req, err := http.NewRequest(http.MethodPost, o.url+"/_bulk", &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("opensearch bulk index error (%d): %s", resp.StatusCode, b)
+	}
+
+	return nil
+}
+
+// do issues an HTTP request against the OpenSearch cluster and returns the
+// raw response body, treating any >=400 status (other than the
+// already-exists case handled by ensureIndex) as an error.
+func (o *OpenSearch) do(method, path string, body interface{}) ([]byte, error) {
+	var rdr io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rdr = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, o.url+path, rdr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		return b, fmt.Errorf("opensearch request failed (%d): %s", resp.StatusCode, b)
+	}
+
+	return b, nil
+}
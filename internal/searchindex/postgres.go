@@ -0,0 +1,37 @@
+package searchindex
+
+import (
+	"context"
+
+	"github.com/knadh/dictpress/internal/data"
+)
+
+// Postgres adapts the existing data.Data tsvector/pg_trgm search to the
+// Backend interface. It does no out-of-band indexing: every entry is
+// already searchable the moment data.Data.InsertEntry/UpdateEntry writes it,
+// so Index, Delete and Reindex are no-ops kept only to satisfy Backend.
+type Postgres struct {
+	data *data.Data
+}
+
+// NewPostgres returns a Backend backed directly by Postgres tsvector/pg_trgm
+// search, the default when search.backend is unset.
+func NewPostgres(d *data.Data) *Postgres {
+	return &Postgres{data: d}
+}
+
+// Search delegates to data.Data.Search.
+func (p *Postgres) Search(ctx context.Context, q data.Query) ([]data.Entry, int, error) {
+	return p.data.Search(ctx, q)
+}
+
+// Index is a no-op: Postgres indexes an entry's tsvector column as part of
+// the same InsertEntry/UpdateEntry statement that wrote it.
+func (p *Postgres) Index(e data.Entry) error { return nil }
+
+// Delete is a no-op: deleting the row in DeleteEntry removes it from the
+// tsvector index too.
+func (p *Postgres) Delete(id int) error { return nil }
+
+// Reindex is a no-op: there's nothing to rebuild out-of-band.
+func (p *Postgres) Reindex(ctx context.Context) error { return nil }
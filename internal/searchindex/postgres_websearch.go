@@ -0,0 +1,36 @@
+package searchindex
+
+import (
+	"context"
+
+	"github.com/knadh/dictpress/internal/data"
+)
+
+// PostgresWebsearch is a Backend identical to Postgres except that it routes
+// queries through data.Data.SearchWebsearch, which understands Postgres'
+// websearch_to_tsquery() syntax ("quoted phrases", -exclusion, OR) instead of
+// the plain AND-of-terms query Postgres.Search builds. Select it with
+// search.backend = "postgres-websearch".
+type PostgresWebsearch struct {
+	data *data.Data
+}
+
+// NewPostgresWebsearch returns a Backend backed by Postgres'
+// websearch_to_tsquery() search syntax.
+func NewPostgresWebsearch(d *data.Data) *PostgresWebsearch {
+	return &PostgresWebsearch{data: d}
+}
+
+// Search delegates to data.Data.SearchWebsearch.
+func (p *PostgresWebsearch) Search(ctx context.Context, q data.Query) ([]data.Entry, int, error) {
+	return p.data.SearchWebsearch(ctx, q)
+}
+
+// Index is a no-op for the same reason as Postgres.Index.
+func (p *PostgresWebsearch) Index(e data.Entry) error { return nil }
+
+// Delete is a no-op for the same reason as Postgres.Delete.
+func (p *PostgresWebsearch) Delete(id int) error { return nil }
+
+// Reindex is a no-op for the same reason as Postgres.Reindex.
+func (p *PostgresWebsearch) Reindex(ctx context.Context) error { return nil }
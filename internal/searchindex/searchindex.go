@@ -0,0 +1,35 @@
+// Package searchindex abstracts dictpress' full-text search behind a small
+// Backend interface so that the Postgres tsvector/pg_trgm search that ships
+// by default isn't the only option. A dictpress install with a large corpus
+// or a need for richer per-language analysis (eg: ICU for Indic scripts,
+// kuromoji for Japanese) can instead point search.backend at an external
+// OpenSearch/Elasticsearch cluster without touching any caller code.
+package searchindex
+
+import (
+	"context"
+
+	"github.com/knadh/dictpress/internal/data"
+)
+
+// Backend index and queries dictionary entries for full-text search.
+// app.data remains the system of record (Postgres); a Backend is kept in
+// sync with it via Index/Delete on every write and can be rebuilt from
+// scratch with Reindex.
+type Backend interface {
+	// Search returns the entries matching q, paginated, along with the
+	// total number of matches. ctx is cancelled by the caller, for instance
+	// when the HTTP client disconnects or a configured query timeout elapses.
+	Search(ctx context.Context, q data.Query) ([]data.Entry, int, error)
+
+	// Index inserts or updates a single entry's document in the backend.
+	Index(e data.Entry) error
+
+	// Delete removes an entry's document from the backend.
+	Delete(id int) error
+
+	// Reindex rebuilds the backend's index from scratch by streaming every
+	// entry out of Postgres. It's a no-op for the postgres backend, which
+	// is always current as of the last write.
+	Reindex(ctx context.Context) error
+}
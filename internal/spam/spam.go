@@ -0,0 +1,174 @@
+// package spam provides pluggable spam-scoring for public dictionary
+// submissions.
+package spam
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/knadh/dictpress/internal/data"
+)
+
+// Scorer scores a submitted entry for its likelihood of being spam. The
+// returned score is in the [0, 1] range, where higher means more likely to
+// be spam.
+type Scorer interface {
+	Score(e data.Entry) (float64, error)
+}
+
+// DuplicateChecker looks up whether an entry with an equivalent phonetic key
+// (tsvector tokens) already exists for a language, used to flag near-exact
+// resubmissions as spam.
+type DuplicateChecker interface {
+	HasDuplicateTokens(lang, tokens string) (bool, error)
+}
+
+// Heuristic score weights. These add up to at most 1.
+const (
+	weightURLs      = 0.4
+	weightNonScript = 0.3
+	weightDuplicate = 0.3
+	maxScoredURLs   = 3
+)
+
+var reURL = regexp.MustCompile(`https?://\S+`)
+
+// HeuristicScorer scores entries using local heuristics that need no
+// external service: the number of URLs in the content, the ratio of
+// characters that don't belong to any Unicode script (typical of
+// obfuscated/junk spam text), and phonetic duplicate detection against
+// existing entries.
+type HeuristicScorer struct {
+	langs data.LangMap
+	dupes DuplicateChecker
+}
+
+// NewHeuristicScorer returns a HeuristicScorer. dupes may be nil, in which
+// case duplicate detection is skipped.
+func NewHeuristicScorer(langs data.LangMap, dupes DuplicateChecker) *HeuristicScorer {
+	return &HeuristicScorer{langs: langs, dupes: dupes}
+}
+
+// Score implements Scorer.
+func (s *HeuristicScorer) Score(e data.Entry) (float64, error) {
+	var score float64
+
+	if n := len(reURL.FindAllString(e.Content, -1)); n > 0 {
+		if n > maxScoredURLs {
+			n = maxScoredURLs
+		}
+		score += weightURLs * float64(n) / maxScoredURLs
+	}
+
+	score += weightNonScript * nonScriptRatio(e.Content)
+
+	if s.dupes != nil {
+		tokens := e.Tokens
+		if tokens == "" {
+			if lang, ok := s.langs[e.Lang]; ok && lang.Tokenizer != nil {
+				if toks, err := lang.Tokenizer.ToTokens(e.Content, e.Lang); err == nil {
+					tokens = strings.Join(toks, " ")
+				}
+			}
+		}
+
+		if tokens != "" {
+			dup, err := s.dupes.HasDuplicateTokens(e.Lang, tokens)
+			if err != nil {
+				return score, fmt.Errorf("error checking for duplicates: %v", err)
+			}
+			if dup {
+				score += weightDuplicate
+			}
+		}
+	}
+
+	if score > 1 {
+		score = 1
+	}
+
+	return score, nil
+}
+
+// nonScriptRatio returns the fraction of non-space characters in s that are
+// neither letters nor digits in any script. A high ratio is typical of
+// obfuscated spam (runs of punctuation, symbols, control characters).
+func nonScriptRatio(s string) float64 {
+	var total, junk int
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			continue
+		}
+
+		total++
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			junk++
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return float64(junk) / float64(total)
+}
+
+// AkismetScorer scores entries by calling an Akismet-compatible comment-check
+// HTTP API (https://akismet.com/developers/comment-check/). A "true" response
+// body is treated as spam (score 1), anything else as clean (score 0).
+type AkismetScorer struct {
+	endpoint string
+	apiKey   string
+	site     string
+	client   *http.Client
+}
+
+// NewAkismetScorer returns an AkismetScorer that posts to endpoint (eg:
+// "https://<api-key>.rest.akismet.com/1.1/comment-check") using apiKey and
+// site (the "blog" URL Akismet associates the check with).
+func NewAkismetScorer(endpoint, apiKey, site string) *AkismetScorer {
+	return &AkismetScorer{
+		endpoint: endpoint,
+		apiKey:   apiKey,
+		site:     site,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Score implements Scorer.
+func (s *AkismetScorer) Score(e data.Entry) (float64, error) {
+	form := url.Values{}
+	form.Set("api_key", s.apiKey)
+	form.Set("blog", s.site)
+	form.Set("comment_type", "forum-post")
+	form.Set("comment_content", e.Content)
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error calling akismet: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("error reading akismet response: %v", err)
+	}
+
+	if strings.TrimSpace(string(body)) == "true" {
+		return 1, nil
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,144 @@
+package tokenizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+)
+
+// jsonCodecName is registered as a grpc.CallContentSubtype so tokenizer
+// calls are framed as plain gRPC/HTTP2 but payloads are marshalled as JSON
+// instead of protobuf. This keeps out-of-process tokenizers writable in any
+// language with a gRPC + JSON stack, without a protoc/codegen step.
+const jsonCodecName = "json"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return jsonCodecName }
+
+// TokensRequest is the request payload for the Tokenizer/ToTokens gRPC method.
+type TokensRequest struct {
+	Text string `json:"text"`
+	Lang string `json:"lang"`
+}
+
+// TokensReply is the response payload for the Tokenizer/ToTokens gRPC method.
+type TokensReply struct {
+	Tokens []string `json:"tokens"`
+}
+
+// QueryRequest is the request payload for the Tokenizer/ToQuery gRPC method.
+type QueryRequest struct {
+	Text string `json:"text"`
+	Lang string `json:"lang"`
+}
+
+// QueryReply is the response payload for the Tokenizer/ToQuery gRPC method.
+type QueryReply struct {
+	Query string `json:"query"`
+}
+
+// idReply is the response payload for the Tokenizer/ID and Tokenizer/Name
+// gRPC methods.
+type idReply struct {
+	Value string `json:"value"`
+}
+
+// defaultTimeout bounds a tokenizer call when the config doesn't set one, so
+// a wedged external tokenizer can't hang a search request indefinitely.
+const defaultTimeout = 2 * time.Second
+
+// grpcClient implements data.Tokenizer by calling a gRPC service (registered
+// as "tokenizer.Tokenizer") exposing ToTokens/ToQuery methods.
+type grpcClient struct {
+	name    string
+	id      string
+	timeout time.Duration
+	conn    *grpc.ClientConn
+}
+
+// loadGRPC dials the tokenizer gRPC service at address and health-checks it
+// with an ID()/Name() call. grpc.ClientConn manages reconnection on its own
+// (it keeps retrying with backoff and transparently re-establishes the
+// stream on the next call), so no manual reconnect loop is needed here -
+// only a bounded per-call timeout, read from cfg.Timeout.
+func loadGRPC(name, address string, timeout time.Duration) (*grpcClient, error) {
+	if address == "" {
+		return nil, fmt.Errorf("tokenizer.%s.address is required for type = 'grpc'", name)
+	}
+
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	conn, err := grpc.Dial(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(jsonCodecName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing tokenizer gRPC '%s': %v", address, err)
+	}
+
+	c := &grpcClient{name: name, timeout: timeout, conn: conn}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var nameRep idReply
+	if err := conn.Invoke(ctx, "/tokenizer.Tokenizer/Name", struct{}{}, &nameRep); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error health-checking tokenizer gRPC '%s': %v", address, err)
+	}
+
+	var idRep idReply
+	if err := conn.Invoke(ctx, "/tokenizer.Tokenizer/ID", struct{}{}, &idRep); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error health-checking tokenizer gRPC '%s': %v", address, err)
+	}
+
+	c.name = nameRep.Value
+	c.id = idRep.Value
+
+	return c, nil
+}
+
+// ToTokens implements data.Tokenizer.
+func (c *grpcClient) ToTokens(s, lang string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var reply TokensReply
+	if err := c.conn.Invoke(ctx, "/tokenizer.Tokenizer/ToTokens", TokensRequest{Text: s, Lang: lang}, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Tokens, nil
+}
+
+// ToQuery implements data.Tokenizer.
+func (c *grpcClient) ToQuery(s, lang string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var reply QueryReply
+	if err := c.conn.Invoke(ctx, "/tokenizer.Tokenizer/ToQuery", QueryRequest{Text: s, Lang: lang}, &reply); err != nil {
+		return "", err
+	}
+	return reply.Query, nil
+}
+
+// ID implements Identifiable.
+func (c *grpcClient) ID() string { return c.id }
+
+// Name implements Identifiable.
+func (c *grpcClient) Name() string { return c.name }
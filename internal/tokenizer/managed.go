@@ -0,0 +1,226 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// CoreProtocolVersion is the handshake protocol version this dictpress
+// build speaks. A type = "managed" plugin binary that reports a different
+// version on its handshake line is refused at load time rather than
+// dialed, so a plugin built against a since-changed RPC contract fails
+// loudly at startup instead of misbehaving at query time.
+const CoreProtocolVersion = 1
+
+const (
+	// managedRestartBackoff is how long supervise() waits before relaunching
+	// a plugin binary that exited, doubling on each consecutive failed
+	// restart up to managedMaxBackoff.
+	managedRestartBackoff = 500 * time.Millisecond
+	managedMaxBackoff     = 30 * time.Second
+
+	// managedHandshakeTimeout bounds how long a freshly launched plugin
+	// binary has to print its handshake line before it's killed and the
+	// launch is given up as failed.
+	managedHandshakeTimeout = 10 * time.Second
+)
+
+// managedHandshake is the single line of JSON a type = "managed" plugin
+// binary must write to its stdout once it's ready to serve, eg:
+//
+//	{"protocol_version": 1, "address": "unix:///tmp/dictpress-en.sock"}
+//
+// address is then dialed exactly like type = "grpc".
+type managedHandshake struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	Address         string `json:"address"`
+}
+
+// managedClient implements data.Tokenizer by launching and supervising a
+// plugin binary over dictpress's lifetime: starting it, handshaking over
+// its stdout, dialing the gRPC service it reports (reusing grpcClient), and
+// relaunching it with backoff if it crashes. A call in flight when the
+// process dies simply fails; there's no call-level failover mid-request,
+// only recovery before the next one.
+type managedClient struct {
+	name    string
+	command string
+	args    []string
+	timeout time.Duration
+
+	mu      sync.RWMutex
+	current *grpcClient
+	cmd     *exec.Cmd
+}
+
+// loadManaged starts the configured plugin binary, performs its handshake,
+// and launches a supervisor goroutine that restarts it on crash.
+func loadManaged(name, command string, args []string, timeout time.Duration) (*managedClient, error) {
+	if command == "" {
+		return nil, fmt.Errorf("tokenizer.%s.command is required for type = 'managed'", name)
+	}
+
+	c := &managedClient{name: name, command: command, args: args, timeout: timeout}
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+
+	go c.supervise()
+	return c, nil
+}
+
+// start launches the plugin binary, reads its handshake line off stdout,
+// verifies its protocol version, and dials the gRPC service it reports.
+func (c *managedClient) start() error {
+	cmd := exec.Command(c.command, c.args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("error opening stdout for tokenizer plugin '%s': %v", c.command, err)
+	}
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting tokenizer plugin '%s': %v", c.command, err)
+	}
+
+	type hsResult struct {
+		hs  managedHandshake
+		err error
+	}
+	hsCh := make(chan hsResult, 1)
+	go func() {
+		sc := bufio.NewScanner(stdout)
+		if !sc.Scan() {
+			hsCh <- hsResult{err: fmt.Errorf("plugin exited before handshaking: %v", sc.Err())}
+			return
+		}
+
+		var hs managedHandshake
+		if err := json.Unmarshal(sc.Bytes(), &hs); err != nil {
+			hsCh <- hsResult{err: fmt.Errorf("error parsing handshake: %v", err)}
+			return
+		}
+		hsCh <- hsResult{hs: hs}
+	}()
+
+	select {
+	case res := <-hsCh:
+		if res.err != nil {
+			cmd.Process.Kill()
+			return fmt.Errorf("error handshaking with tokenizer plugin '%s': %v", c.command, res.err)
+		}
+		if res.hs.ProtocolVersion != CoreProtocolVersion {
+			cmd.Process.Kill()
+			return fmt.Errorf("tokenizer plugin '%s' speaks protocol version %d, this dictpress build requires %d",
+				c.command, res.hs.ProtocolVersion, CoreProtocolVersion)
+		}
+
+		gc, err := loadGRPC(c.name, res.hs.Address, c.timeout)
+		if err != nil {
+			cmd.Process.Kill()
+			return fmt.Errorf("error dialing tokenizer plugin '%s' at %s: %v", c.command, res.hs.Address, err)
+		}
+
+		c.mu.Lock()
+		c.cmd = cmd
+		c.current = gc
+		c.mu.Unlock()
+		return nil
+
+	case <-time.After(managedHandshakeTimeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("tokenizer plugin '%s' did not handshake within %s", c.command, managedHandshakeTimeout)
+	}
+}
+
+// supervise waits on the current plugin process and relaunches it with
+// exponential backoff for as long as it keeps crashing, so a plugin that
+// dies mid-way through a long-running dictpress process is brought back up
+// automatically instead of permanently breaking search for its language.
+func (c *managedClient) supervise() {
+	backoff := managedRestartBackoff
+	for {
+		c.mu.RLock()
+		cmd := c.cmd
+		c.mu.RUnlock()
+		if cmd == nil {
+			return
+		}
+
+		waitErr := cmd.Wait()
+
+		c.mu.Lock()
+		if c.current != nil {
+			c.current.conn.Close()
+			c.current = nil
+		}
+		c.cmd = nil
+		c.mu.Unlock()
+
+		log.Printf("tokenizer plugin '%s' exited (%v), restarting in %s", c.command, waitErr, backoff)
+		time.Sleep(backoff)
+
+		if err := c.start(); err != nil {
+			log.Printf("error restarting tokenizer plugin '%s': %v", c.command, err)
+			backoff *= 2
+			if backoff > managedMaxBackoff {
+				backoff = managedMaxBackoff
+			}
+			continue
+		}
+		backoff = managedRestartBackoff
+	}
+}
+
+// ToTokens implements data.Tokenizer.
+func (c *managedClient) ToTokens(s, lang string) ([]string, error) {
+	cur, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	return cur.ToTokens(s, lang)
+}
+
+// ToQuery implements data.Tokenizer.
+func (c *managedClient) ToQuery(s, lang string) (string, error) {
+	cur, err := c.get()
+	if err != nil {
+		return "", err
+	}
+	return cur.ToQuery(s, lang)
+}
+
+// get returns the currently connected grpcClient, or an error while the
+// plugin is down between a crash and its next successful restart.
+func (c *managedClient) get() (*grpcClient, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.current == nil {
+		return nil, fmt.Errorf("tokenizer plugin '%s' is not connected", c.command)
+	}
+	return c.current, nil
+}
+
+// ID implements Identifiable.
+func (c *managedClient) ID() string {
+	cur, err := c.get()
+	if err != nil {
+		return ""
+	}
+	return cur.ID()
+}
+
+// Name implements Identifiable.
+func (c *managedClient) Name() string {
+	cur, err := c.get()
+	if err != nil {
+		return c.name
+	}
+	return cur.Name()
+}
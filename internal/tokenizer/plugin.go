@@ -0,0 +1,41 @@
+package tokenizer
+
+import (
+	"fmt"
+	"plugin"
+
+	"github.com/knadh/dictpress/internal/data"
+)
+
+// loadPlugin dlopens the .so at path and looks up its exported
+// `New(cfg map[string]interface{}) (data.Tokenizer, error)` constructor,
+// passing it the tokenizer's `[tokenizer.<name>.config]` block verbatim so
+// plugins can carry their own settings (dictionary paths, model files, etc.)
+// without dictpress knowing about them.
+func loadPlugin(name, path string, cfg map[string]interface{}) (data.Tokenizer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("tokenizer.%s.path is required for type = 'plugin'", name)
+	}
+
+	plg, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening tokenizer plugin '%s': %v", path, err)
+	}
+
+	sym, err := plg.Lookup("New")
+	if err != nil {
+		return nil, fmt.Errorf("New() not found in tokenizer plugin '%s': %v", path, err)
+	}
+
+	newFunc, ok := sym.(func(map[string]interface{}) (data.Tokenizer, error))
+	if !ok {
+		return nil, fmt.Errorf("New() in tokenizer plugin '%s' has the wrong signature, expected func(map[string]interface{}) (data.Tokenizer, error)", path)
+	}
+
+	tk, err := newFunc(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing tokenizer plugin '%s': %v", path, err)
+	}
+
+	return tk, nil
+}
@@ -0,0 +1,109 @@
+package tokenizer
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+)
+
+// ToTokensArgs is the request payload for the RPCServer.ToTokens method.
+type ToTokensArgs struct {
+	Text string
+	Lang string
+}
+
+// ToTokensReply is the response payload for the RPCServer.ToTokens method.
+type ToTokensReply struct {
+	Tokens []string
+}
+
+// ToQueryArgs is the request payload for the RPCServer.ToQuery method.
+type ToQueryArgs struct {
+	Text string
+	Lang string
+}
+
+// ToQueryReply is the response payload for the RPCServer.ToQuery method.
+type ToQueryReply struct {
+	Query string
+}
+
+// IDReply is the response payload for the RPCServer.ID and RPCServer.Name
+// methods.
+type IDReply struct {
+	Value string
+}
+
+// RPCServer is the contract an out-of-process tokenizer implements and
+// registers with net/rpc (under the name "Tokenizer") to be reachable over
+// a Unix domain socket.
+type RPCServer interface {
+	ToTokens(args ToTokensArgs, reply *ToTokensReply) error
+	ToQuery(args ToQueryArgs, reply *ToQueryReply) error
+	ID(args struct{}, reply *IDReply) error
+	Name(args struct{}, reply *IDReply) error
+}
+
+// rpcClient implements data.Tokenizer by calling an RPCServer dialed over a
+// Unix domain socket.
+type rpcClient struct {
+	name string
+	id   string
+	cli  *rpc.Client
+}
+
+// loadRPC dials the tokenizer RPC service listening on the given Unix
+// domain socket address and health-checks it with an ID()/Name() call.
+func loadRPC(name, address string) (*rpcClient, error) {
+	if address == "" {
+		return nil, fmt.Errorf("tokenizer.%s.address is required for type = 'rpc'", name)
+	}
+
+	conn, err := net.Dial("unix", address)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing tokenizer RPC socket '%s': %v", address, err)
+	}
+
+	c := &rpcClient{name: name, cli: rpc.NewClient(conn)}
+
+	var nameRep IDReply
+	if err := c.cli.Call("Tokenizer.Name", struct{}{}, &nameRep); err != nil {
+		c.cli.Close()
+		return nil, fmt.Errorf("error health-checking tokenizer RPC '%s': %v", address, err)
+	}
+
+	var idRep IDReply
+	if err := c.cli.Call("Tokenizer.ID", struct{}{}, &idRep); err != nil {
+		c.cli.Close()
+		return nil, fmt.Errorf("error health-checking tokenizer RPC '%s': %v", address, err)
+	}
+
+	c.name = nameRep.Value
+	c.id = idRep.Value
+
+	return c, nil
+}
+
+// ToTokens implements data.Tokenizer.
+func (c *rpcClient) ToTokens(s, lang string) ([]string, error) {
+	var reply ToTokensReply
+	if err := c.cli.Call("Tokenizer.ToTokens", ToTokensArgs{Text: s, Lang: lang}, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Tokens, nil
+}
+
+// ToQuery implements data.Tokenizer.
+func (c *rpcClient) ToQuery(s, lang string) (string, error) {
+	var reply ToQueryReply
+	if err := c.cli.Call("Tokenizer.ToQuery", ToQueryArgs{Text: s, Lang: lang}, &reply); err != nil {
+		return "", err
+	}
+	return reply.Query, nil
+}
+
+// ID implements Identifiable.
+func (c *rpcClient) ID() string { return c.id }
+
+// Name implements Identifiable.
+func (c *rpcClient) Name() string { return c.name }
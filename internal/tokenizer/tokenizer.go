@@ -0,0 +1,116 @@
+// Package tokenizer loads out-of-process tokenizers configured under
+// [tokenizer.<name>] in config.toml, so that a new language/script can be
+// wired up without forking and rebuilding dictpress.
+//
+// Five transports are supported:
+//
+//   - type = "plugin": a Go 1.8 plugin (.so) built with `go build
+//     -buildmode=plugin` that exports a
+//     `New(cfg map[string]interface{}) (data.Tokenizer, error)` function,
+//     handed the tokenizer's `[tokenizer.<name>.config]` block.
+//   - type = "rpc": a separate process implementing the RPCServer contract
+//     below (net/rpc, registered as "Tokenizer"), reachable over a Unix
+//     domain socket.
+//   - type = "grpc": a separate process implementing the Tokenizer gRPC
+//     service (ToTokens/ToQuery/ID/Name, JSON-framed, see grpc.go), reachable
+//     over `tokenizer.<name>.address`. This is the preferred transport for
+//     tokenizers written outside Go (Python, Rust, ...): dictpress dials it
+//     with grpc-go, which reconnects on its own, and bounds every call with
+//     `tokenizer.<name>.timeout`.
+//   - type = "managed": like "grpc", but dictpress launches and supervises
+//     the plugin binary itself (`tokenizer.<name>.command`/`.args`) instead
+//     of dialing an address the operator started separately. The binary
+//     handshakes by writing a `{"protocol_version", "address"}` JSON line to
+//     its stdout (see managed.go); a version mismatch against
+//     CoreProtocolVersion is refused, and a crash is restarted with
+//     exponential backoff for as long as dictpress runs.
+//   - type = "wasm": a .wasm module (see wasm.go for its ABI) loaded from
+//     `tokenizer.<name>.path`, or fetched from `tokenizer.<name>.url` and
+//     verified against `tokenizer.<name>.checksum` then cached under
+//     `tokenizer.<name>.cache_dir`. It runs in a sandboxed, WASI-less wazero
+//     runtime (pure Go, no cgo), so unlike "plugin" it isn't restricted to
+//     Linux or to dictpress's exact build toolchain.
+package tokenizer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/knadh/dictpress/internal/data"
+)
+
+// Identifiable is optionally implemented by an external tokenizer so that
+// Load can health-check it and log which tokenizer actually came up, as
+// opposed to just the name it was configured under.
+type Identifiable interface {
+	ID() string
+	Name() string
+}
+
+// Config describes a single tokenizer configured under [tokenizer.<name>].
+type Config struct {
+	// Type is "plugin", "rpc", "grpc", "managed", or "wasm".
+	Type string
+
+	// Path is the .so path for type = "plugin".
+	Path string
+
+	// Options is the [tokenizer.<name>.config] block, passed verbatim to a
+	// type = "plugin" tokenizer's New() constructor.
+	Options map[string]interface{}
+
+	// Address is the dial address for type = "rpc" (Unix domain socket path)
+	// or type = "grpc" (host:port or unix:// socket).
+	Address string
+
+	// Command and Args launch the plugin binary for type = "managed".
+	Command string
+	Args    []string
+
+	// Timeout bounds each ToTokens/ToQuery call for type = "grpc" and
+	// type = "managed". Defaults to defaultTimeout when unset.
+	Timeout time.Duration
+
+	// URL, if set, fetches a type = "wasm" module over HTTP(S) instead of
+	// loading it from Path, caching it locally under CacheDir once its
+	// checksum has been verified against Checksum.
+	URL string
+
+	// Checksum is the expected "sha256:<hex>" digest of the type = "wasm"
+	// module fetched from URL. Required whenever URL is set; a mismatch is
+	// refused rather than loaded.
+	Checksum string
+
+	// CacheDir is where a type = "wasm" module fetched from URL is cached.
+	// Defaults to a dictpress-wasm-tokenizers directory under the OS temp
+	// dir when unset.
+	CacheDir string
+}
+
+// Load dials, dlopens or instantiates the tokenizer described by cfg and
+// health-checks it before returning.
+func Load(name string, cfg Config) (data.Tokenizer, error) {
+	switch cfg.Type {
+	case "plugin":
+		return loadPlugin(name, cfg.Path, cfg.Options)
+	case "rpc":
+		return loadRPC(name, cfg.Address)
+	case "grpc":
+		return loadGRPC(name, cfg.Address, cfg.Timeout)
+	case "managed":
+		return loadManaged(name, cfg.Command, cfg.Args, cfg.Timeout)
+	case "wasm":
+		return loadWasm(name, cfg)
+	default:
+		return nil, fmt.Errorf("unknown type '%s' (expected 'plugin', 'rpc', 'grpc', 'managed' or 'wasm')", cfg.Type)
+	}
+}
+
+// Identify returns a human-readable name for a loaded tokenizer, preferring
+// the ID()/Name() it reports itself over the name it was configured under.
+func Identify(configuredName string, tk data.Tokenizer) string {
+	if id, ok := tk.(Identifiable); ok {
+		return fmt.Sprintf("%s (%s)", id.Name(), id.ID())
+	}
+	return configuredName
+}
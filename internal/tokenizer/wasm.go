@@ -0,0 +1,289 @@
+package tokenizer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// wasmDownloadTimeout bounds fetching a tokenizer module over cfg.URL.
+const wasmDownloadTimeout = 30 * time.Second
+
+// wasmTokenizer implements data.Tokenizer by calling exported functions on a
+// .wasm module instantiated in a sandboxed wazero runtime, over a stable ABI:
+//
+//   - alloc(len uint32) uint32 -- allocates len bytes in the module's linear
+//     memory and returns a pointer, for the host to write an input string
+//     into before a to_tokens/to_query call.
+//   - dealloc(ptr, len uint32) -- frees memory the module allocated for a
+//     return value, once the host has copied it out.
+//   - id(), name() uint64 -- each packs (ptr<<32 | len) of a static,
+//     module-owned string identifying the tokenizer.
+//   - to_tokens(textPtr, textLen, langPtr, langLen uint32) uint64 and
+//     to_query(...) uint64 -- same packed-pointer return, holding a
+//     module-allocated output string. to_tokens' output is a single
+//     space-joined token string, the same representation dictpress already
+//     stores tokens in (see internal/importer's TSVectorTokens), so the ABI
+//     never needs to cross an array of strings, only ever one in and one out.
+//
+// No WASI imports are wired up: the module gets no filesystem, clock, env or
+// network access, only pure computation over the strings it's handed -- the
+// "sandboxed" in this package's job.
+type wasmTokenizer struct {
+	name string
+
+	runtime wazero.Runtime
+	mod     api.Module
+
+	alloc, dealloc    api.Function
+	idFn, nameFn      api.Function
+	toTokens, toQuery api.Function
+
+	id, modName string
+}
+
+// loadWasm resolves, verifies and instantiates the .wasm module described by
+// cfg (see ensureWasmCached) and health-checks it with an id()/name() call.
+func loadWasm(name string, cfg Config) (*wasmTokenizer, error) {
+	path, err := ensureWasmCached(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tokenizer wasm '%s': %v", path, err)
+	}
+
+	ctx := context.Background()
+	rt := wazero.NewRuntime(ctx)
+
+	compiled, err := rt.CompileModule(ctx, b)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("error compiling tokenizer wasm '%s': %v", path, err)
+	}
+
+	mod, err := rt.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(name))
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("error instantiating tokenizer wasm '%s': %v", path, err)
+	}
+
+	t := &wasmTokenizer{name: name, runtime: rt, mod: mod}
+
+	get := func(fnName string) (api.Function, error) {
+		f := mod.ExportedFunction(fnName)
+		if f == nil {
+			return nil, fmt.Errorf("tokenizer wasm '%s' does not export '%s'", path, fnName)
+		}
+		return f, nil
+	}
+
+	for fnName, dst := range map[string]*api.Function{
+		"alloc": &t.alloc, "dealloc": &t.dealloc,
+		"id": &t.idFn, "name": &t.nameFn,
+		"to_tokens": &t.toTokens, "to_query": &t.toQuery,
+	} {
+		f, err := get(fnName)
+		if err != nil {
+			rt.Close(ctx)
+			return nil, err
+		}
+		*dst = f
+	}
+
+	id, err := t.callString(ctx, t.idFn)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("error health-checking tokenizer wasm '%s' (id): %v", path, err)
+	}
+	modName, err := t.callString(ctx, t.nameFn)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("error health-checking tokenizer wasm '%s' (name): %v", path, err)
+	}
+	t.id, t.modName = id, modName
+
+	return t, nil
+}
+
+// ensureWasmCached resolves a tokenizer's .wasm module to a local file path.
+// If cfg.URL is unset, cfg.Path is used as-is (a module already on disk). If
+// cfg.URL is set, the module is downloaded into cfg.CacheDir (or the OS temp
+// dir, if unset) keyed by its expected checksum, and verified against
+// cfg.Checksum -- a cached file that still matches is reused without
+// refetching; a download that doesn't match is rejected outright.
+func ensureWasmCached(name string, cfg Config) (string, error) {
+	if cfg.URL == "" {
+		if cfg.Path == "" {
+			return "", fmt.Errorf("tokenizer.%s.path or .url is required for type = 'wasm'", name)
+		}
+		return cfg.Path, nil
+	}
+
+	if cfg.Checksum == "" {
+		return "", fmt.Errorf("tokenizer.%s.checksum is required when .url is set for type = 'wasm'", name)
+	}
+
+	algo, want, ok := strings.Cut(cfg.Checksum, ":")
+	if !ok || algo != "sha256" || want == "" {
+		return "", fmt.Errorf("tokenizer.%s.checksum must be of the form 'sha256:<hex>'", name)
+	}
+
+	dir := cfg.CacheDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "dictpress-wasm-tokenizers")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating tokenizer wasm cache dir '%s': %v", dir, err)
+	}
+
+	dest := filepath.Join(dir, name+"-"+want+".wasm")
+	if b, err := os.ReadFile(dest); err == nil && checksumMatches(b, want) {
+		return dest, nil
+	}
+
+	b, err := downloadWasm(cfg.URL)
+	if err != nil {
+		return "", fmt.Errorf("error downloading tokenizer wasm '%s': %v", cfg.URL, err)
+	}
+	if !checksumMatches(b, want) {
+		return "", fmt.Errorf("tokenizer.%s: checksum mismatch for %s, refusing to load (expected sha256:%s)", name, cfg.URL, want)
+	}
+
+	if err := os.WriteFile(dest, b, 0o644); err != nil {
+		return "", fmt.Errorf("error caching tokenizer wasm to '%s': %v", dest, err)
+	}
+
+	return dest, nil
+}
+
+func checksumMatches(b []byte, wantHex string) bool {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]) == wantHex
+}
+
+func downloadWasm(url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wasmDownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// writeString allocates len(s) bytes in the module's linear memory via its
+// exported alloc() and writes s into it, returning the pointer and length.
+func (t *wasmTokenizer) writeString(ctx context.Context, s string) (uint32, uint32, error) {
+	b := []byte(s)
+
+	res, err := t.alloc.Call(ctx, uint64(len(b)))
+	if err != nil {
+		return 0, 0, err
+	}
+	ptr := uint32(res[0])
+
+	if len(b) > 0 && !t.mod.Memory().Write(ptr, b) {
+		return 0, 0, fmt.Errorf("tokenizer wasm '%s': failed writing %d bytes at offset %d", t.name, len(b), ptr)
+	}
+
+	return ptr, uint32(len(b)), nil
+}
+
+// readString reads the string a module function packed into its uint64
+// return value as (ptr<<32 | len), copies it out, then frees it via the
+// module's exported dealloc() -- Memory().Read returns a view into linear
+// memory that dealloc (or a later alloc) may reuse, so the copy must happen
+// first.
+func (t *wasmTokenizer) readString(ctx context.Context, packed uint64) (string, error) {
+	ptr, ln := uint32(packed>>32), uint32(packed)
+
+	b, ok := t.mod.Memory().Read(ptr, ln)
+	if !ok {
+		return "", fmt.Errorf("tokenizer wasm '%s': failed reading %d bytes at offset %d", t.name, ln, ptr)
+	}
+	out := string(append([]byte(nil), b...))
+
+	if _, err := t.dealloc.Call(ctx, uint64(ptr), uint64(ln)); err != nil {
+		return "", fmt.Errorf("tokenizer wasm '%s': error freeing return value: %v", t.name, err)
+	}
+
+	return out, nil
+}
+
+// callString calls a no-argument module function returning a packed
+// (ptr<<32 | len) string, eg: id()/name().
+func (t *wasmTokenizer) callString(ctx context.Context, fn api.Function) (string, error) {
+	res, err := fn.Call(ctx)
+	if err != nil {
+		return "", err
+	}
+	return t.readString(ctx, res[0])
+}
+
+// callTextFn calls a module function shaped like to_tokens/to_query:
+// (textPtr, textLen, langPtr, langLen uint32) -> packed (ptr<<32 | len) string.
+func (t *wasmTokenizer) callTextFn(ctx context.Context, fn api.Function, text, lang string) (string, error) {
+	textPtr, textLen, err := t.writeString(ctx, text)
+	if err != nil {
+		return "", err
+	}
+	langPtr, langLen, err := t.writeString(ctx, lang)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := fn.Call(ctx, uint64(textPtr), uint64(textLen), uint64(langPtr), uint64(langLen))
+	if err != nil {
+		return "", err
+	}
+
+	return t.readString(ctx, res[0])
+}
+
+// ToTokens implements data.Tokenizer.
+func (t *wasmTokenizer) ToTokens(s, lang string) ([]string, error) {
+	out, err := t.callTextFn(context.Background(), t.toTokens, s, lang)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Fields(out), nil
+}
+
+// ToQuery implements data.Tokenizer.
+func (t *wasmTokenizer) ToQuery(s, lang string) (string, error) {
+	return t.callTextFn(context.Background(), t.toQuery, s, lang)
+}
+
+// ID implements Identifiable.
+func (t *wasmTokenizer) ID() string { return t.id }
+
+// Name implements Identifiable.
+func (t *wasmTokenizer) Name() string { return t.modName }
@@ -2,7 +2,6 @@ package indicphone
 
 import (
 	"errors"
-	"fmt"
 	"slices"
 	"strings"
 
@@ -11,56 +10,85 @@ import (
 	"gitlab.com/joice/mlphone-go"
 )
 
+// PhoneticEncoder is implemented by a per-language phonetic encoder that
+// reduces a word to up to three Romanized keys of decreasing specificity,
+// similar to Metaphone for English.
+type PhoneticEncoder interface {
+	Encode(string) (string, string, string)
+}
+
+// Config is the tokenizer configuration.
 type Config struct {
-	NumMLKeys int
+	// NumKeys is the number of keys (1-3) to use per language when building
+	// tsquery strings in ToQuery(), keyed by language name.
+	NumKeys map[string]int
+
+	// Deprecated: use NumKeys["kannada"] instead.
 	NumKNKeys int
+	// Deprecated: use NumKeys["malayalam"] instead.
+	NumMLKeys int
 }
 
 // IndicPhone is a phonetic tokenizer that generates phonetic tokens for
-// Indian languages. It is similar to Metaphone for English.
+// Indian languages. It is similar to Metaphone for English. It dispatches
+// to a registry of per-language PhoneticEncoder implementations.
 type IndicPhone struct {
-	config Config
-	kn     *knphone.KNphone
-	ml     *mlphone.MLPhone
+	config   Config
+	encoders map[string]PhoneticEncoder
 }
 
-// New returns a new instance of the Kannada tokenizer.
+// New returns a new instance of the IndicPhone tokenizer with the bundled
+// Kannada and Malayalam encoders registered.
 func New(config Config) *IndicPhone {
-	if config.NumKNKeys < 0 {
-		config.NumKNKeys = 2
+	if config.NumKeys == nil {
+		config.NumKeys = make(map[string]int)
 	}
-	if config.NumMLKeys < 0 {
-		config.NumMLKeys = 2
+
+	// Absorb the deprecated fixed fields into the NumKeys map if they're set
+	// and the map doesn't already have an entry for that language.
+	if config.NumKNKeys != 0 {
+		if _, ok := config.NumKeys["kannada"]; !ok {
+			config.NumKeys["kannada"] = config.NumKNKeys
+		}
+	}
+	if config.NumMLKeys != 0 {
+		if _, ok := config.NumKeys["malayalam"]; !ok {
+			config.NumKeys["malayalam"] = config.NumMLKeys
+		}
 	}
 
-	return &IndicPhone{
-		config: config,
-		kn:     knphone.New(),
-		ml:     mlphone.New(),
+	ip := &IndicPhone{
+		config:   config,
+		encoders: make(map[string]PhoneticEncoder),
 	}
+
+	ip.Register("kannada", knphone.New())
+	ip.Register("malayalam", mlphone.New())
+
+	return ip
+}
+
+// Register adds (or replaces) a PhoneticEncoder for the given language.
+// It allows additional scripts (eg: Devanagari/Hindi, Tamil, Telugu, Bengali,
+// Gurmukhi) to be plugged in without modifying IndicPhone itself.
+func (ip *IndicPhone) Register(lang string, enc PhoneticEncoder) {
+	ip.encoders[lang] = enc
 }
 
 // ToTokens tokenizes a string and a language returns an array of tsvector tokens.
 // eg: [KRM0 KRM] or [KRM:2 KRM:1] with weights.
 func (ip *IndicPhone) ToTokens(s string, lang string) ([]string, error) {
-	if lang != "kannada" && lang != "malayalam" {
+	enc, ok := ip.encoders[lang]
+	if !ok {
 		return nil, errors.New("unknown language to tokenize")
 	}
 
 	var (
 		chunks = strings.Split(s, " ")
 		tokens = make([]data.Token, 0, len(chunks)*3)
-
-		key0, key1, key2 string
 	)
 	for _, c := range chunks {
-		switch lang {
-		case "kannada":
-			key0, key1, key2 = ip.kn.Encode(c)
-		case "malayalam":
-			key0, key1, key2 = ip.ml.Encode(c)
-		}
-
+		key0, key1, key2 := enc.Encode(c)
 		if key0 == "" {
 			continue
 		}
@@ -74,32 +102,24 @@ func (ip *IndicPhone) ToTokens(s string, lang string) ([]string, error) {
 	return data.TokensToTSVector(tokens), nil
 }
 
-// ToQuery tokenizes a Kannada string into Romanized (knphone) Postgres
-// tsquery string.
+// ToQuery tokenizes a string into a Romanized Postgres tsquery string using
+// the registered encoder for the given language.
 func (ip *IndicPhone) ToQuery(s string, lang string) (string, error) {
-	var (
-		key0, key1, key2 string
-		numKeys          = 0
-	)
-
-	switch lang {
-	case "kannada":
-		key0, key1, key2 = ip.kn.Encode(s)
-		numKeys = ip.config.NumKNKeys
-	case "malayalam":
-		key0, key1, key2 = ip.ml.Encode(s)
-		numKeys = ip.config.NumMLKeys
+	enc, ok := ip.encoders[lang]
+	if !ok {
+		return "", errors.New("unknown language to tokenize")
 	}
 
+	key0, key1, key2 := enc.Encode(s)
 	if key0 == "" {
 		return "", nil
 	}
+
+	numKeys := ip.config.NumKeys[lang]
 	if numKeys == 0 {
 		numKeys = 1
 	}
 
-	fmt.Println(numKeys)
-
 	// De-duplicate tokens.
 	tokens := slices.Compact([]string{key2, key1, key0})
 